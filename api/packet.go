@@ -1,5 +1,8 @@
 package api
 
+// AccessTokenKey module access token key, carried by grpc metadata or http header
+const AccessTokenKey = "qelog-access-token"
+
 type JSONPacket struct {
 	Id     string   `json:"id"`
 	Module string   `json:"module"`
@@ -0,0 +1,81 @@
+package receiverpb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MaxDecompressSize limit packet data size after decompress, avoid decompression bomb
+const MaxDecompressSize = 64 << 20
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(MaxDecompressSize)))
+)
+
+// Compress data by compression algorithm
+func Compress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case Compression_NONE:
+		return data, nil
+	case Compression_GZIP:
+		buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+		gz := gzip.NewWriter(buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Compression_SNAPPY:
+		return snappy.Encode(nil, data), nil
+	case Compression_ZSTD:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %d", c)
+}
+
+// Decompress data by compression algorithm
+func Decompress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case Compression_NONE:
+		return data, nil
+	case Compression_GZIP:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return readLimit(gz)
+	case Compression_SNAPPY:
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if n > MaxDecompressSize {
+			return nil, fmt.Errorf("decompress size exceeded %d", MaxDecompressSize)
+		}
+		return snappy.Decode(nil, data)
+	case Compression_ZSTD:
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+	return nil, fmt.Errorf("unsupported compression %d", c)
+}
+
+func readLimit(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(MaxDecompressSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxDecompressSize {
+		return nil, fmt.Errorf("decompress size exceeded %d", MaxDecompressSize)
+	}
+	return b, nil
+}
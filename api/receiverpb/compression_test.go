@@ -0,0 +1,30 @@
+package receiverpb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"_level":"INFO","_short":"hello compression"}`+"\n"), 100)
+	for _, c := range []Compression{Compression_NONE, Compression_GZIP, Compression_SNAPPY, Compression_ZSTD} {
+		b, err := Compress(c, data)
+		if err != nil {
+			t.Fatalf("%s: compress %v", c, err)
+		}
+		if c != Compression_NONE && len(b) >= len(data) {
+			t.Fatalf("%s: compressed size %d not less than %d", c, len(b), len(data))
+		}
+		raw, err := Decompress(c, b)
+		if err != nil {
+			t.Fatalf("%s: decompress %v", c, err)
+		}
+		if !bytes.Equal(raw, data) {
+			t.Fatalf("%s: data not equal", c)
+		}
+	}
+
+	if _, err := Decompress(Compression(100), data); err == nil {
+		t.Fatal("unsupported compression should error")
+	}
+}
@@ -23,6 +23,8 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ReceiverClient interface {
 	PushPacket(ctx context.Context, in *Packet, opts ...grpc.CallOption) (*BaseResp, error)
+	// PushStream keep one stream open, send packets continuously, ack every packet by packet id
+	PushStream(ctx context.Context, opts ...grpc.CallOption) (Receiver_PushStreamClient, error)
 }
 
 type receiverClient struct {
@@ -42,11 +44,44 @@ func (c *receiverClient) PushPacket(ctx context.Context, in *Packet, opts ...grp
 	return out, nil
 }
 
+func (c *receiverClient) PushStream(ctx context.Context, opts ...grpc.CallOption) (Receiver_PushStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Receiver_ServiceDesc.Streams[0], "/receiverpb.Receiver/PushStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &receiverPushStreamClient{stream}
+	return x, nil
+}
+
+type Receiver_PushStreamClient interface {
+	Send(*Packet) error
+	Recv() (*PacketAck, error)
+	grpc.ClientStream
+}
+
+type receiverPushStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *receiverPushStreamClient) Send(m *Packet) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *receiverPushStreamClient) Recv() (*PacketAck, error) {
+	m := new(PacketAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ReceiverServer is the server API for Receiver service.
 // All implementations should embed UnimplementedReceiverServer
 // for forward compatibility
 type ReceiverServer interface {
 	PushPacket(context.Context, *Packet) (*BaseResp, error)
+	// PushStream keep one stream open, send packets continuously, ack every packet by packet id
+	PushStream(Receiver_PushStreamServer) error
 }
 
 // UnimplementedReceiverServer should be embedded to have forward compatible implementations.
@@ -56,6 +91,9 @@ type UnimplementedReceiverServer struct {
 func (UnimplementedReceiverServer) PushPacket(context.Context, *Packet) (*BaseResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PushPacket not implemented")
 }
+func (UnimplementedReceiverServer) PushStream(Receiver_PushStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PushStream not implemented")
+}
 
 // UnsafeReceiverServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to ReceiverServer will
@@ -86,6 +124,32 @@ func _Receiver_PushPacket_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Receiver_PushStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReceiverServer).PushStream(&receiverPushStreamServer{stream})
+}
+
+type Receiver_PushStreamServer interface {
+	Send(*PacketAck) error
+	Recv() (*Packet, error)
+	grpc.ServerStream
+}
+
+type receiverPushStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *receiverPushStreamServer) Send(m *PacketAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *receiverPushStreamServer) Recv() (*Packet, error) {
+	m := new(Packet)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Receiver_ServiceDesc is the grpc.ServiceDesc for Receiver service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -98,6 +162,13 @@ var Receiver_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _Receiver_PushPacket_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PushStream",
+			Handler:       _Receiver_PushStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "receiver.proto",
 }
@@ -9,7 +9,7 @@ import (
 	"github.com/bbdshow/qelog/pkg/admin"
 	"github.com/bbdshow/qelog/pkg/conf"
 	"github.com/bbdshow/qelog/pkg/receiver"
-	"github.com/bbdshow/qelog/pkg/server/grpc"
+	"github.com/bbdshow/qelog/pkg/server"
 	"github.com/bbdshow/qelog/pkg/server/http"
 	"github.com/bbdshow/qelog/pkg/types"
 	"go.uber.org/zap"
@@ -22,7 +22,7 @@ var (
 )
 
 func main() {
-	flag.StringVar(&mode, "mode", string(types.Single), "server mode, single | cluster_admin | cluster_receiver")
+	flag.StringVar(&mode, "mode", string(types.Single), "server mode, single | cluster_admin | cluster_receiver | embedded")
 
 	if err := conf.InitConf(); err != nil {
 		panic(err)
@@ -39,8 +39,11 @@ func main() {
 
 	smode := types.GetFlagOrOSEnvServerMode(types.ServerMode(mode))
 	fmt.Println("current running server mode:", smode)
+	if smode == types.Embedded {
+		conf.Conf.SetEmbedded()
+	}
 	switch smode {
-	case types.Single:
+	case types.Single, types.Embedded:
 		go func() {
 			adminServer(ctx, conf.Conf)
 		}()
@@ -81,9 +84,9 @@ func receiverServer(ctx context.Context, conf *conf.Config) {
 	svc := receiver.NewService(conf)
 	defer svc.Close()
 
-	rpcSvc := grpc.NewReceiverGRpc(conf, svc)
-	if err := runner.RunServer(rpcSvc,
-		runner.WithListenAddr(conf.Receiver.RpcListenAddr),
+	// grpc and http(if listen address not empty) receiver server
+	srv := server.NewReceiverServer(conf, svc)
+	if err := runner.RunServer(srv,
 		runner.WithContext(ctx),
 	); err != nil {
 		log.Printf("runner exit: %v\n", err)
@@ -5,10 +5,12 @@ go 1.17
 require (
 	github.com/bbdshow/bkit v0.3.8
 	github.com/bbdshow/bkit/db/mongo v0.1.1
-	github.com/bbdshow/qelog/api v1.1.0
+	github.com/bbdshow/qelog/api v1.2.0
 	github.com/bbdshow/qelog/qezap v1.1.1
 	github.com/gin-gonic/gin v1.7.2
 	github.com/json-iterator/go v1.1.12
+	github.com/segmentio/kafka-go v0.4.28
+	go.etcd.io/bbolt v1.3.6
 	go.mongodb.org/mongo-driver v1.7.2
 	go.uber.org/zap v1.17.0
 	google.golang.org/grpc v1.41.0
@@ -30,13 +32,14 @@ require (
 	github.com/go-stack/stack v1.8.0 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/golang/snappy v0.0.1 // indirect
-	github.com/klauspost/compress v1.9.5 // indirect
+	github.com/klauspost/compress v1.9.8 // indirect
 	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/mailru/easyjson v0.0.0-20180823135443-60711f1a8329 // indirect
 	github.com/mattn/go-isatty v0.0.12 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml v1.9.3 // indirect
+	github.com/pierrec/lz4 v2.6.0+incompatible // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/shopspring/decimal v1.2.0 // indirect
@@ -61,3 +64,8 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
+
+replace (
+	github.com/bbdshow/qelog/api => ./api
+	github.com/bbdshow/qelog/qezap => ./qezap
+)
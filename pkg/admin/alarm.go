@@ -6,10 +6,12 @@ import (
 	"time"
 
 	"github.com/bbdshow/bkit/errc"
-	"github.com/bbdshow/bkit/util/alert"
 	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/notify"
+	"github.com/bbdshow/qelog/pkg/receiver/alarm"
 	"github.com/bbdshow/qelog/pkg/types"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // FindAlarmRuleList query alarm rule list
@@ -27,8 +29,15 @@ func (svc *Service) FindAlarmRuleList(ctx context.Context, in *model.FindAlarmRu
 			ID:           v.ID.Hex(),
 			Enable:       v.Enable,
 			ModuleName:   v.ModuleName,
+			Type:         v.RuleType(),
 			Short:        v.Short,
+			Field:        v.Field,
+			Pattern:      v.Pattern,
 			Level:        v.Level.Int32(),
+			Condition:    v.AlarmCondition(),
+			WindowSec:    v.WindowSec,
+			Threshold:    v.Threshold,
+			Template:     v.Template,
 			Tag:          v.Tag,
 			RateSec:      v.RateSec,
 			Method:       v.Method.Int32(),
@@ -44,11 +53,21 @@ func (svc *Service) FindAlarmRuleList(ctx context.Context, in *model.FindAlarmRu
 
 // CreateAlarmRule create alarm rule
 func (svc *Service) CreateAlarmRule(ctx context.Context, in *model.CreateAlarmRuleReq) error {
+	if err := verifyAlarmRule(in); err != nil {
+		return err
+	}
 	doc := &model.AlarmRule{
 		Enable:     true,
 		ModuleName: in.ModuleName,
+		Type:       in.Type,
 		Short:      in.Short,
+		Field:      in.Field,
+		Pattern:    in.Pattern,
 		Level:      types.Level(in.Level),
+		Condition:  in.Condition,
+		WindowSec:  in.WindowSec,
+		Threshold:  in.Threshold,
+		Template:   in.Template,
 		Tag:        in.Tag,
 		RateSec:    in.RateSec,
 		Method:     model.Method(in.Method),
@@ -65,12 +84,84 @@ func (svc *Service) CreateAlarmRule(ctx context.Context, in *model.CreateAlarmRu
 
 // UpdateAlarmRule update alarm rule
 func (svc *Service) UpdateAlarmRule(ctx context.Context, in *model.UpdateAlarmRuleReq) error {
+	if err := verifyAlarmRule(&in.CreateAlarmRuleReq); err != nil {
+		return err
+	}
 	if err := svc.d.UpdateAlarmRule(ctx, in); err != nil {
 		return errc.ErrInternalErr.MultiErr(err)
 	}
 	return nil
 }
 
+// verifyAlarmRule rule type default exact, short message required. other type compiled by receiver matcher,
+// fields not used by type cleared
+func verifyAlarmRule(in *model.CreateAlarmRuleReq) error {
+	if in.Type == "" {
+		in.Type = model.RuleExact
+	}
+	switch in.Type {
+	case model.RuleExact:
+		if in.Short == "" {
+			return errc.ErrParamInvalid.MultiMsg("short required")
+		}
+		in.Field, in.Pattern = "", ""
+	case model.RuleShortRegex, model.RuleFullRegex, model.RuleLevel:
+		in.Short, in.Field = "", ""
+	default:
+		in.Short = ""
+	}
+	if in.Type == model.RuleLevel {
+		in.Pattern = ""
+	}
+	if err := verifyAlarmCondition(in); err != nil {
+		return err
+	}
+	if !notify.Registered(model.Method(in.Method)) {
+		return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("method %d not supported", in.Method))
+	}
+	if _, err := alarm.ParseTemplate(in.Template); err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	rule := &model.AlarmRule{
+		ModuleName: in.ModuleName,
+		Type:       in.Type,
+		Short:      in.Short,
+		Field:      in.Field,
+		Pattern:    in.Pattern,
+		Level:      types.Level(in.Level),
+	}
+	if _, err := alarm.NewMatcher(rule); err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	return nil
+}
+
+// verifyAlarmCondition condition default hit, window not used. window required by other condition,
+// threshold count of matched logging or percent of module logging, absence threshold not used
+func verifyAlarmCondition(in *model.CreateAlarmRuleReq) error {
+	if in.Condition == "" {
+		in.Condition = model.ConditionHit
+	}
+	switch in.Condition {
+	case model.ConditionHit:
+		in.WindowSec, in.Threshold = 0, 0
+		return nil
+	case model.ConditionAbsence:
+		in.Threshold = 0
+	case model.ConditionThreshold, model.ConditionRatio:
+		if in.Threshold <= 0 {
+			return errc.ErrParamInvalid.MultiMsg("threshold required")
+		}
+		if in.Condition == model.ConditionRatio && in.Threshold > 100 {
+			return errc.ErrParamInvalid.MultiMsg("ratio threshold percent lte 100")
+		}
+	}
+	if in.WindowSec < model.AlarmCounterStepSec {
+		return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("windowSec gte %d", model.AlarmCounterStepSec))
+	}
+	return nil
+}
+
 // DelAlarmRule delete alarm rule
 func (svc *Service) DelAlarmRule(ctx context.Context, in *model.DelAlarmRuleReq) error {
 	id, err := in.ObjectID()
@@ -99,6 +190,8 @@ func (svc *Service) FindHookURLList(ctx context.Context, in *model.FindHookURLLi
 			Method:       v.Method.Int32(),
 			KeyWord:      v.KeyWord,
 			HideText:     v.HideText,
+			BodyTemplate: v.BodyTemplate,
+			Template:     v.Template,
 			UpdatedTsSec: v.UpdatedAt.Unix(),
 		}
 		if d.HideText == nil {
@@ -113,13 +206,18 @@ func (svc *Service) FindHookURLList(ctx context.Context, in *model.FindHookURLLi
 
 // CreateHookURL create alarm hook URL
 func (svc *Service) CreateHookURL(ctx context.Context, in *model.CreateHookURLReq) error {
+	if err := verifyHookURL(in); err != nil {
+		return err
+	}
 	doc := &model.HookURL{
-		Name:      in.Name,
-		URL:       in.URL,
-		Method:    model.Method(in.Method),
-		KeyWord:   in.KeyWord,
-		HideText:  in.HideText,
-		UpdatedAt: time.Now().Local(),
+		Name:         in.Name,
+		URL:          in.URL,
+		Method:       model.Method(in.Method),
+		KeyWord:      in.KeyWord,
+		HideText:     in.HideText,
+		BodyTemplate: in.BodyTemplate,
+		Template:     in.Template,
+		UpdatedAt:    time.Now().Local(),
 	}
 
 	if err := svc.d.CreateHookURL(ctx, doc); err != nil {
@@ -130,12 +228,87 @@ func (svc *Service) CreateHookURL(ctx context.Context, in *model.CreateHookURLRe
 
 // UpdateHookURL update alarm hook URL
 func (svc *Service) UpdateHookURL(ctx context.Context, in *model.UpdateHookURLReq) error {
+	if err := verifyHookURL(&in.CreateHookURLReq); err != nil {
+		return err
+	}
 	if err := svc.d.UpdateHookURL(ctx, in); err != nil {
 		return errc.ErrInternalErr.MultiErr(err)
 	}
 	return nil
 }
 
+// verifyHookURL hook address and body template checked by notifier of method
+func verifyHookURL(in *model.CreateHookURLReq) error {
+	_, err := notify.New(model.Method(in.Method), &model.HookURL{
+		Name:         in.Name,
+		URL:          in.URL,
+		KeyWord:      in.KeyWord,
+		BodyTemplate: in.BodyTemplate,
+	})
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	if _, err := alarm.ParseTemplate(in.Template); err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	return nil
+}
+
+// PreviewAlarmTemplate alarm message of template rendered by sample logging, template syntax or field error returned.
+// rule and hook optional, message of them rendered, hide text masked
+func (svc *Service) PreviewAlarmTemplate(ctx context.Context, in *model.PreviewAlarmTemplateReq, out *model.PreviewAlarmTemplateResp) error {
+	rule := &model.AlarmRule{ModuleName: "example", Tag: "example"}
+	if in.RuleID != "" {
+		id, err := primitive.ObjectIDFromHex(in.RuleID)
+		if err != nil {
+			return errc.ErrParamInvalid.MultiErr(err)
+		}
+		exists, doc, err := svc.d.GetAlarmRule(ctx, bson.M{"_id": id})
+		if err != nil {
+			return errc.ErrInternalErr.MultiErr(err)
+		}
+		if !exists {
+			return errc.ErrNotFound.MultiMsg("alarm rule")
+		}
+		rule = doc
+		if in.HookID == "" {
+			in.HookID = doc.HookID
+		}
+	}
+	var hook *model.HookURL
+	if in.HookID != "" {
+		id, err := primitive.ObjectIDFromHex(in.HookID)
+		if err != nil {
+			return errc.ErrParamInvalid.MultiErr(err)
+		}
+		exists, doc, err := svc.d.GetHookURL(ctx, bson.M{"_id": id})
+		if err != nil {
+			return errc.ErrInternalErr.MultiErr(err)
+		}
+		if !exists {
+			return errc.ErrNotFound.MultiMsg("hook url")
+		}
+		hook = doc
+	}
+	text := in.Template
+	if text == "" {
+		text = rule.Template
+	}
+	if text == "" && hook != nil {
+		text = hook.Template
+	}
+	tpl, err := alarm.ParseTemplate(text)
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	content, err := alarm.RenderMessage(tpl, alarm.SampleMessage(rule, hook, svc.cfg.Admin.ExternalURL))
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	out.Content = alarm.HideText(content, hook)
+	return nil
+}
+
 // DelHookURL delete alarm hook URL
 func (svc *Service) DelHookURL(ctx context.Context, in *model.DelHookURLReq) error {
 	if err := svc.d.DelHookURL(ctx, in); err != nil {
@@ -157,19 +330,12 @@ func (svc *Service) PingHookURL(ctx context.Context, in *model.PingHookURLReq) e
 	if !exists {
 		return errc.ErrNotFound
 	}
-	switch doc.Method {
-	case model.MethodDingDing:
-		ding := alert.NewDingDing()
-		ding.SetHookURL(doc.URL)
-		if err := ding.Send(ctx, fmt.Sprintf("%s %s Ping hook Success", doc.KeyWord, doc.Name)); err != nil {
-			return errc.ErrParamInvalid.MultiErr(err)
-		}
-	case model.MethodTelegram:
-		tel := alert.NewTelegram()
-		tel.SetHookURL(doc.URL)
-		if err := tel.Send(ctx, fmt.Sprintf("%s %s Ping hook Success", doc.KeyWord, doc.Name)); err != nil {
-			return errc.ErrParamInvalid.MultiErr(err)
-		}
+	method, err := notify.New(doc.Method, doc)
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	if err := method.Send(ctx, fmt.Sprintf("%s %s Ping hook Success", doc.KeyWord, doc.Name)); err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
 	}
 	return nil
 }
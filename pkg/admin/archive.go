@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/archive"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+const (
+	archivePageLimit       = 1000
+	defaultRestoreKeepDays = 7
+)
+
+// archiveStorage storage of module archive policy, created when first used
+func (svc *Service) archiveStorage(typ string) (archive.Storage, error) {
+	svc.archiveMutex.Lock()
+	defer svc.archiveMutex.Unlock()
+	if s, ok := svc.archives[typ]; ok {
+		return s, nil
+	}
+	s, err := archive.New(svc.cfg.Archive, typ)
+	if err != nil {
+		return nil, err
+	}
+	svc.archives[typ] = s
+	return s, nil
+}
+
+// archiveExpiredCollection ok true expired shard can be dropped.
+// shard archived once, restored shard not archived again and kept until restore keep time.
+// migrated copy not archived, logging kept in target database
+func (svc *Service) archiveExpiredCollection(ctx context.Context, m *model.Module, shard *model.Shard) (bool, error) {
+	if !shard.Readable() {
+		return true, nil
+	}
+	exists, doc, err := svc.d.GetArchive(ctx, bson.M{
+		"module_name": m.Name,
+		"collection":  shard.Collection,
+		"$or": bson.A{
+			bson.M{"database": shard.Database},
+			bson.M{"restored_db": shard.Database},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return doc.KeepUntilAt.Before(time.Now()), nil
+	}
+	if m.ArchiveType() == model.ArchiveNone {
+		return true, nil
+	}
+	if err := svc.archiveCollection(ctx, m, shard.Database, shard.Collection); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// archiveCollection stream all logging of shard to temp file, then put to archive storage with manifest.
+// manifest recorded after data object put, record exists means shard can be dropped
+func (svc *Service) archiveCollection(ctx context.Context, m *model.Module, dbName, cName string) error {
+	s, err := svc.archiveStorage(m.ArchiveType())
+	if err != nil {
+		return err
+	}
+	tmpDir := filepath.Join(svc.cfg.Archive.Dir, ".tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(tmpDir, cName+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	doc := &model.Archive{
+		ModuleName: m.Name,
+		Database:   dbName,
+		Collection: cName,
+		Target:     m.ArchiveType(),
+		Key:        archive.Key(m.Name, dbName, cName),
+		CreatedAt:  time.Now(),
+	}
+	w := archive.NewWriter(f, doc)
+	err = svc.forEachLogging(ctx, dbName, cName, func(docs []*model.Logging) error {
+		for _, v := range docs {
+			if err := w.Write(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.Put(ctx, doc.Key, f, doc.Bytes); err != nil {
+		return err
+	}
+	manifest, _ := json.MarshalIndent(doc, "", "  ")
+	if err := s.Put(ctx, doc.ManifestKey(), bytes.NewReader(manifest), int64(len(manifest))); err != nil {
+		return err
+	}
+	return svc.d.UpsertArchive(ctx, doc)
+}
+
+// FindArchiveList archived shard of module
+func (svc *Service) FindArchiveList(ctx context.Context, in *model.FindArchiveListReq, out *model.ListResp) error {
+	c, docs, err := svc.d.FindArchiveList(ctx, in)
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	out.Count = c
+	list := make([]*model.FindArchiveList, 0, len(docs))
+	for _, v := range docs {
+		d := &model.FindArchiveList{
+			ID:               v.ID.Hex(),
+			ModuleName:       v.ModuleName,
+			Database:         v.Database,
+			Collection:       v.Collection,
+			Target:           v.Target,
+			Key:              v.Key,
+			Rows:             v.Rows,
+			Bytes:            v.Bytes,
+			MinTsSec:         v.MinTsSec,
+			MaxTsSec:         v.MaxTsSec,
+			CreatedTsSec:     v.CreatedAt.Unix(),
+			RestoredDatabase: v.RestoredDB,
+			RestoreStatus:    v.RestoreStatus,
+			RestoreError:     v.RestoreError,
+			RestoredRows:     v.RestoredRows,
+		}
+		if !v.RestoredAt.IsZero() {
+			d.RestoredTsSec = v.RestoredAt.Unix()
+			d.KeepUntilTsSec = v.KeepUntilAt.Unix()
+		}
+		list = append(list, d)
+	}
+	out.List = list
+	return nil
+}
+
+// RestoreArchive read archived shard back into receiver database, same collection name.
+// logging id kept, restored again duplicate logging ignored. checksum verified before restore finished,
+// restored shard registered in shard catalog, kept KeepDays before expired dropped again.
+// restore run in background, status and rows shown in archive list
+func (svc *Service) RestoreArchive(ctx context.Context, in *model.RestoreArchiveReq, out *model.RestoreArchiveResp) error {
+	id, err := in.ObjectID()
+	if err != nil {
+		return err
+	}
+	exists, doc, err := svc.d.GetArchive(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("archive")
+	}
+	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": doc.ModuleName})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("module")
+	}
+	dbName := in.Database
+	if dbName == "" {
+		dbName = doc.Database
+	}
+	if !svc.cfg.IsLoggingDatabase(dbName) {
+		return errc.ErrParamInvalid.MultiMsg("database not receiver database")
+	}
+	s, err := svc.archiveStorage(doc.Target)
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	ok, err := svc.d.StartArchiveRestore(ctx, doc)
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !ok {
+		return errc.ErrParamInvalid.MultiMsg("archive restore running")
+	}
+	keepDays := in.KeepDays
+	if keepDays <= 0 {
+		keepDays = defaultRestoreKeepDays
+	}
+	out.Database = dbName
+	out.Collection = doc.Collection
+
+	go func() {
+		ctx := context.Background()
+		rows, err := svc.restoreArchive(ctx, s, doc, m, dbName)
+		if err == nil {
+			err = svc.d.UpdateArchiveRestored(ctx, doc, dbName, rows, time.Now().AddDate(0, 0, keepDays))
+		}
+		if err != nil {
+			logs.Qezap.Error("RestoreArchive", zap.String("id", doc.ID.Hex()), zap.Error(err))
+			if err := svc.d.FailArchiveRestore(ctx, doc, err.Error()); err != nil {
+				logs.Qezap.Error("FailArchiveRestore", zap.String("id", doc.ID.Hex()), zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+// restoreArchive read archive object into collection and register shard, rows restored
+func (svc *Service) restoreArchive(ctx context.Context, s archive.Storage, doc *model.Archive, m *model.Module, dbName string) (int64, error) {
+	rc, err := s.Get(ctx, doc.Key)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	r, err := archive.NewReader(rc, doc)
+	if err != nil {
+		return 0, err
+	}
+	if err := svc.d.CreateLoggingIndex(dbName, doc.Collection, m.FullText); err != nil {
+		return 0, err
+	}
+	rows := int64(0)
+	batch := make([]interface{}, 0, archivePageLimit)
+	for {
+		l, err := r.Next()
+		if err != nil && err != io.EOF {
+			return rows, err
+		}
+		if l != nil {
+			batch = append(batch, l)
+		}
+		if len(batch) > 0 && (len(batch) >= archivePageLimit || err == io.EOF) {
+			if err := svc.d.CreateManyLogging(ctx, dbName, doc.Collection, batch); err != nil {
+				return rows, err
+			}
+			rows += int64(len(batch))
+			batch = batch[:0]
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	// time range of archived logging, module naming rule may be changed after archived
+	shard := &model.Shard{
+		ModuleName: doc.ModuleName,
+		Database:   dbName,
+		Collection: doc.Collection,
+		BeginTsSec: doc.MinTsSec,
+		EndTsSec:   doc.MaxTsSec + 1,
+		CreatedAt:  time.Now(),
+	}
+	return rows, svc.d.RegisterShard(ctx, shard)
+}
@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/dao"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultExportRows = 100000
+	maxExportRows     = 1000000
+	// bytes before gzip
+	maxExportBytes  = 512 << 20
+	exportPageLimit = 1000
+)
+
+// ExportLogging walk all logging match condition by cursor and write to w, shards merged order by ts desc.
+// stop when rows or bytes limit reached, audit.Truncated mark it, export failed after written also truncated.
+// every export recorded to audit, include failed
+func (svc *Service) ExportLogging(ctx context.Context, in *model.ExportLoggingReq, w io.Writer, audit *model.ExportAudit) (err error) {
+	if in.Format == "" {
+		in.Format = model.ExportFormatNDJSON
+	}
+	condition, _ := json.Marshal(in)
+	audit.ModuleName = in.ModuleName
+	audit.Condition = string(condition)
+	audit.Format = in.Format
+	audit.Gzip = in.Gzip
+	audit.CreatedAt = time.Now()
+	defer func() {
+		if err != nil {
+			audit.Error = err.Error()
+			audit.Truncated = true
+		}
+		// request context may be canceled by client
+		actx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if e := svc.d.CreateExportAudit(actx, audit); e != nil {
+			logs.Qezap.Error("CreateExportAudit", zap.Error(e), zap.Any("audit", audit))
+		}
+	}()
+
+	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": in.ModuleName})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("module")
+	}
+	if in.Keyword != "" && !m.FullText {
+		return errc.ErrParamInvalid.MultiMsg("module full-text search disabled")
+	}
+	b, e := in.InitTimeSection(time.Hour)
+	in.BeginTsSec = b.Unix()
+	in.EndTsSec = e.Unix()
+
+	filter, err := dao.FindLoggingListFilter(&in.FindLoggingListReq)
+	if err != nil {
+		return err
+	}
+	shards, err := svc.loggingShards(ctx, m, in.BeginTsSec, in.EndTsSec, in.ForceDatabase, in.ForceCollectionName)
+	if err != nil {
+		return err
+	}
+
+	maxRows := in.MaxRows
+	if maxRows <= 0 {
+		maxRows = defaultExportRows
+	}
+	if maxRows > maxExportRows {
+		maxRows = maxExportRows
+	}
+	cw := &countWriter{w: w}
+	enc := newLoggingEncoder(in.Format, cw)
+	defer func() {
+		audit.Bytes = cw.n
+	}()
+
+	// shards of different database or prefix may overlap in time, merged by newest head of each shard
+	readers := make([]*exportShardReader, 0, len(shards))
+	for _, v := range shards {
+		// _id zero, all logging ts < end
+		readers = append(readers, &exportShardReader{shard: v, cursor: &model.LoggingCursor{TsSec: in.EndTsSec}})
+	}
+	for {
+		if audit.Rows >= maxRows || cw.n >= maxExportBytes {
+			audit.Truncated = true
+			return enc.Flush()
+		}
+		var next *exportShardReader
+		for _, r := range readers {
+			if err := r.fill(ctx, svc, filter); err != nil {
+				return err
+			}
+			if len(r.buf) > 0 && (next == nil || model.LoggingLess(r.buf[0], next.buf[0])) {
+				next = r
+			}
+		}
+		if next == nil {
+			return enc.Flush()
+		}
+		if err := enc.Encode(toLoggingItem(next.buf[0])); err != nil {
+			return err
+		}
+		next.buf = next.buf[1:]
+		audit.Rows++
+		if audit.Rows%exportPageLimit == 0 {
+			if err := enc.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// exportShardReader a page of shard buffered, next page read after cursor when consumed
+type exportShardReader struct {
+	shard  model.LoggingShard
+	cursor *model.LoggingCursor
+	buf    []*model.Logging
+	done   bool
+}
+
+func (r *exportShardReader) fill(ctx context.Context, svc *Service, filter bson.M) error {
+	if len(r.buf) > 0 || r.done {
+		return nil
+	}
+	docs, err := svc.d.FindLoggingAfter(ctx, r.shard.Database, r.shard.Collection, filter, r.cursor, exportPageLimit)
+	if err != nil {
+		return err
+	}
+	if len(docs) < exportPageLimit {
+		r.done = true
+	}
+	if len(docs) > 0 {
+		r.cursor = model.NewLoggingCursor(docs[len(docs)-1], false)
+	}
+	r.buf = docs
+	return nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type loggingEncoder interface {
+	Encode(v *model.FindLoggingList) error
+	Flush() error
+}
+
+func newLoggingEncoder(format string, w io.Writer) loggingEncoder {
+	if format == model.ExportFormatCSV {
+		return &csvLoggingEncoder{w: csv.NewWriter(w)}
+	}
+	return &ndjsonLoggingEncoder{enc: json.NewEncoder(w)}
+}
+
+type ndjsonLoggingEncoder struct {
+	enc *json.Encoder
+}
+
+// Encode json.Encoder end with newline
+func (e *ndjsonLoggingEncoder) Encode(v *model.FindLoggingList) error {
+	return e.enc.Encode(v)
+}
+
+func (e *ndjsonLoggingEncoder) Flush() error {
+	return nil
+}
+
+type csvLoggingEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvLoggingEncoder) Encode(v *model.FindLoggingList) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write([]string{"id", "time", "level", "short", "full",
+			"conditionOne", "conditionTwo", "conditionThree", "traceId", "ip"}); err != nil {
+			return err
+		}
+	}
+	return e.w.Write([]string{v.ID, time.Unix(0, v.TsMill*int64(time.Millisecond)).Format("2006-01-02T15:04:05.000Z07:00"),
+		strconv.Itoa(int(v.Level)), v.Short, v.Full,
+		v.ConditionOne, v.ConditionTwo, v.ConditionThree, v.TraceID, v.IP})
+}
+
+func (e *csvLoggingEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
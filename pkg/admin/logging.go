@@ -3,14 +3,19 @@ package admin
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/bbdshow/bkit/db/mongo"
 	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/bkit/logs"
+	apiTypes "github.com/bbdshow/qelog/api/types"
+	"github.com/bbdshow/qelog/pkg/dao"
 	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/query"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.uber.org/zap"
 )
@@ -25,9 +30,36 @@ func (svc *Service) FindLoggingByTraceID(ctx context.Context, in *model.FindLogg
 		return errc.ErrNotFound.MultiMsg("module")
 	}
 
-	docs, err := svc.d.FindLoggingByTraceID(ctx, m, in)
+	tid, err := apiTypes.TraceIDFromHex(in.TraceID)
 	if err != nil {
-		return errc.WithStack(err)
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	// traceId have time info, set it time condition +-2 hour
+	tidTime := tid.Time()
+	shards, err := svc.loggingShards(ctx, m, tidTime.Add(-2*time.Hour).Unix(), tidTime.Add(2*time.Hour).Unix(), in.ForceDatabase, in.ForceCollectionName)
+	if err != nil {
+		return err
+	}
+	dbNames := make([]string, 0)
+	dbShards := map[string][]string{}
+	for _, v := range shards {
+		if _, ok := dbShards[v.Database]; !ok {
+			dbNames = append(dbNames, v.Database)
+		}
+		dbShards[v.Database] = append(dbShards[v.Database], v.Collection)
+	}
+	docs := make([]*model.Logging, 0)
+	for _, dbName := range dbNames {
+		v, err := svc.d.FindLoggingByTraceID(ctx, dbName, dbShards[dbName], m.Name, in.TraceID)
+		if err != nil {
+			return errc.WithStack(err)
+		}
+		docs = append(docs, v...)
+	}
+	if len(dbNames) > 1 {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return docs[i].TimeMill < docs[j].TimeMill
+		})
 	}
 	list := make([]*model.FindLoggingList, 0, len(docs))
 	// there is a low probability of data being written repeatedly
@@ -60,8 +92,8 @@ func (svc *Service) FindLoggingByTraceID(ctx context.Context, in *model.FindLogg
 	return nil
 }
 
-// FindLoggingList query logging by common condition
-func (svc *Service) FindLoggingList(ctx context.Context, in *model.FindLoggingListReq, out *model.ListResp) error {
+// FindLoggingList query logging by common condition, time range crossed shards, query all shards and merge
+func (svc *Service) FindLoggingList(ctx context.Context, in *model.FindLoggingListReq, out *model.FindLoggingListResp) error {
 	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": in.ModuleName})
 	if err != nil {
 		return errc.ErrInternalErr.MultiErr(err)
@@ -70,51 +102,244 @@ func (svc *Service) FindLoggingList(ctx context.Context, in *model.FindLoggingLi
 		return errc.ErrNotFound.MultiMsg("module")
 	}
 
+	if in.Keyword != "" && !m.FullText {
+		return errc.ErrParamInvalid.MultiMsg("module full-text search disabled")
+	}
+
 	// if condition not time filter, default setting 1 hour
 	b, e := in.InitTimeSection(time.Hour)
 	in.BeginTsSec = b.Unix()
 	in.EndTsSec = e.Unix()
 
-	// by querying the time, the data is calculated in which shard
-	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
-	dbName := m.Database
-	cName := ""
-	if in.ForceDatabase != "" {
-		if !svc.cfg.MongoGroup.IsReceiverDatabase(in.ForceDatabase) {
-			return errc.ErrParamInvalid.MultiMsg("force database not receiver database")
-		}
+	filter, err := dao.FindLoggingListFilter(in)
+	if err != nil {
+		return err
+	}
+	shards, err := svc.loggingShards(ctx, m, in.BeginTsSec, in.EndTsSec, in.ForceDatabase, in.ForceCollectionName)
+	if err != nil {
+		return err
+	}
+	return svc.findLoggingShards(ctx, shards, filter, in.Cursor, in.PageReq, out)
+}
+
+// QueryLogging query logging by query language, parse or validate error returns position in query text
+func (svc *Service) QueryLogging(ctx context.Context, in *model.QueryLoggingReq, out *model.FindLoggingListResp) error {
+	node, err := query.Parse(in.Query)
+	if err != nil {
+		return err
+	}
+	cond, analysis, err := query.Compile(node)
+	if err != nil {
+		return err
+	}
+
+	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": in.ModuleName})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
 	}
-	if in.ForceCollectionName != "" {
-		if !strings.HasPrefix(in.ForceCollectionName, m.Prefix) {
-			return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("force collection name not '%s' prefix", m.Prefix))
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("module")
+	}
+	if analysis.FullText && !m.FullText {
+		return errc.ErrParamInvalid.MultiMsg("module full-text search disabled")
+	}
+
+	b, e := in.InitTimeSection(time.Hour)
+	in.BeginTsSec = b.Unix()
+	in.EndTsSec = e.Unix()
+	// unindexed query scan all documents in time range, bounded time range
+	if !analysis.Indexed && e.Sub(b) > unindexedQueryMaxSpan {
+		return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("query condition not use index, time range limit %s, suggest add level, short, c1 or traceid condition",
+			unindexedQueryMaxSpan))
+	}
+
+	shards, err := svc.loggingShards(ctx, m, in.BeginTsSec, in.EndTsSec, "", "")
+	if err != nil {
+		return err
+	}
+	filter := bson.M{
+		"m":    m.Name,
+		"ts":   bson.M{"$gte": in.BeginTsSec, "$lt": in.EndTsSec},
+		"$and": bson.A{cond},
+	}
+	return svc.findLoggingShards(ctx, shards, filter, in.Cursor, in.PageReq, out)
+}
+
+const (
+	unindexedQueryMaxSpan = time.Hour
+	// page paging crossed shards, each shard need query all previous page, too deep use cursor
+	maxMergeLoggingSize = 5000
+	// avoid too many shards query at same time
+	maxShardConcurrent = 4
+	// shard walked by page
+	forEachPageLimit = 1000
+)
+
+// forEachLogging walk all logging of shard by cursor, order by ts desc, fn called with every page
+func (svc *Service) forEachLogging(ctx context.Context, dbName, cName string, fn func(docs []*model.Logging) error) error {
+	// _id zero, all logging
+	cursor := &model.LoggingCursor{TsSec: math.MaxInt64}
+	for {
+		docs, err := svc.d.FindLoggingAfter(ctx, dbName, cName, bson.M{}, cursor, forEachPageLimit)
+		if err != nil {
+			return err
 		}
-		cName = in.ForceCollectionName
-	} else {
-		// calc collection name
-		names := sc.CollNameByStartEnd(m.Bucket, in.BeginTsSec, in.EndTsSec)
-		if len(names) >= 2 {
-			format := "2006-01-02"
-			sepTime, err := sc.SepTime(names[0])
-			if err != nil {
-				return errc.ErrParamInvalid.MultiErr(err)
+		if len(docs) > 0 {
+			if err := fn(docs); err != nil {
+				return err
 			}
-			sep := sepTime.Format(format)
-			return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("Time has crossed shards,suggest time: %s -- %s || %s -- %s",
-				time.Unix(in.BeginTsSec, 0).Format(format), sep, sep, time.Unix(in.EndTsSec, 0).Format(format)))
 		}
-		if len(names) > 0 {
-			cName = names[0]
+		if len(docs) < forEachPageLimit {
+			return nil
+		}
+		cursor = model.NewLoggingCursor(docs[len(docs)-1], false)
+	}
+}
+
+// loggingShards shards of time range from shard catalog, module day span, prefix or database changed not affect history shards.
+// shard migrated routed to target database, stale copy only queried by force database
+func (svc *Service) loggingShards(ctx context.Context, m *model.Module, beginTsSec, endTsSec int64, forceDatabase, forceCollectionName string) ([]model.LoggingShard, error) {
+	if forceDatabase != "" {
+		if !svc.cfg.IsLoggingDatabase(forceDatabase) {
+			return nil, errc.ErrParamInvalid.MultiMsg("force database not receiver database")
+		}
+	}
+	if forceCollectionName != "" {
+		// registered shard of module, prefix changed after created still allowed
+		filter := bson.M{"module_name": m.Name, "collection": forceCollectionName}
+		if forceDatabase != "" {
+			filter["database"] = forceDatabase
+		} else {
+			filter["migrated_to"] = ""
+		}
+		docs, err := svc.d.FindShard(ctx, filter)
+		if err != nil {
+			return nil, errc.ErrInternalErr.MultiErr(err)
+		}
+		if len(docs) == 0 && !strings.HasPrefix(forceCollectionName, m.Prefix) {
+			return nil, errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("force collection name not '%s' prefix", m.Prefix))
+		}
+		dbName := forceDatabase
+		if dbName == "" {
+			dbName = m.Database
+			if len(docs) > 0 {
+				dbName = docs[0].Database
+			}
 		}
+		return []model.LoggingShard{{Database: dbName, Collection: forceCollectionName}}, nil
 	}
 
-	c, docs, err := svc.d.FindLoggingList(ctx, dbName, cName, in)
+	docs, err := svc.d.FindShardByTime(ctx, m.Name, forceDatabase, beginTsSec, endTsSec)
 	if err != nil {
-		return errc.WithStack(err)
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	shards := make([]model.LoggingShard, 0, len(docs))
+	for _, v := range docs {
+		shards = append(shards, model.LoggingShard{Database: v.Database, Collection: v.Collection})
 	}
-	out.Count = c
+	return shards, nil
+}
 
-	// there is a low probability of data being written repeatedly
-	// filtering duplicate written data
+// findLoggingShards query each shard concurrently, merge by ts desc, counts summed.
+// cursor paging, each shard only need a page after cursor.
+// page paging compatible, each shard need all previous pages, because skip can't calc on every shard
+func (svc *Service) findLoggingShards(ctx context.Context, shards []model.LoggingShard, filter bson.M, cursorToken string, page model.PageReq, out *model.FindLoggingListResp) error {
+	var cursor *model.LoggingCursor
+	if cursorToken != "" {
+		c, err := model.DecodeLoggingCursor(cursorToken)
+		if err != nil {
+			return errc.ErrParamInvalid.MultiErr(err)
+		}
+		cursor = c
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	skip := int64(0)
+	if cursor == nil && page.Page > 1 {
+		skip = (page.Page - 1) * limit
+	}
+	shardPage := model.PageReq{Page: 1, Limit: skip + limit}
+	if len(shards) == 1 {
+		// skip by db
+		shardPage = model.PageReq{Page: skip/limit + 1, Limit: limit}
+		skip = 0
+	} else if shardPage.Limit > maxMergeLoggingSize {
+		return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("time range crossed shards, page too deep, max %d logging, use cursor paging", maxMergeLoggingSize))
+	}
+
+	type result struct {
+		count int64
+		docs  []*model.Logging
+		err   error
+	}
+	results := make([]result, len(shards))
+	sem := make(chan struct{}, maxShardConcurrent)
+	wg := sync.WaitGroup{}
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard model.LoggingShard) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			c, docs, err := svc.d.FindLoggingByFilter(ctx, shard.Database, shard.Collection, filter, cursor, shardPage)
+			results[i] = result{count: c, docs: docs, err: err}
+		}(i, shard)
+	}
+	wg.Wait()
+
+	docs := make([]*model.Logging, 0)
+	for _, r := range results {
+		if r.err != nil {
+			return errc.WithStack(r.err)
+		}
+		out.Count += r.count
+		docs = append(docs, r.docs...)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return model.LoggingLess(docs[i], docs[j])
+	})
+	if int64(len(docs)) <= skip {
+		docs = docs[:0]
+	} else {
+		docs = docs[skip:]
+	}
+	prev := cursor != nil && cursor.Prev
+	if int64(len(docs)) > limit {
+		if prev {
+			// nearest cursor position at tail
+			docs = docs[int64(len(docs))-limit:]
+		} else {
+			docs = docs[:limit]
+		}
+	}
+	if len(docs) > 0 {
+		full := int64(len(docs)) == limit
+		// full page, maybe have next page. came from next page, next page exists
+		if full || prev {
+			out.NextCursor = model.NewLoggingCursor(docs[len(docs)-1], false).Encode()
+		}
+		// newer logging may be written at any time, prev cursor always returned
+		out.PrevCursor = model.NewLoggingCursor(docs[0], true).Encode()
+	} else if cursor != nil {
+		// no more data, keep position, client can turn back or retry later
+		c := *cursor
+		c.Prev = !c.Prev
+		if prev {
+			out.PrevCursor = cursorToken
+			out.NextCursor = c.Encode()
+		} else {
+			out.PrevCursor = c.Encode()
+		}
+	}
+	out.List = toLoggingList(docs)
+	return nil
+}
+
+// there is a low probability of data being written repeatedly
+// filtering duplicate written data
+func toLoggingList(docs []*model.Logging) []*model.FindLoggingList {
 	hitMap := map[string]struct{}{}
 	list := make([]*model.FindLoggingList, 0, len(docs))
 	for _, v := range docs {
@@ -123,24 +348,24 @@ func (svc *Service) FindLoggingList(ctx context.Context, in *model.FindLoggingLi
 		} else {
 			hitMap[v.MessageID] = struct{}{}
 		}
-
-		d := &model.FindLoggingList{
-			ID:             v.ID.Hex(),
-			TsMill:         v.TimeMill,
-			Level:          int32(v.Level),
-			Short:          v.Short,
-			Full:           v.Full,
-			ConditionOne:   v.Condition1,
-			ConditionTwo:   v.Condition2,
-			ConditionThree: v.Condition3,
-			IP:             v.IP,
-			TraceID:        v.TraceID,
-		}
-		list = append(list, d)
+		list = append(list, toLoggingItem(v))
 	}
-	out.List = list
+	return list
+}
 
-	return nil
+func toLoggingItem(v *model.Logging) *model.FindLoggingList {
+	return &model.FindLoggingList{
+		ID:             v.ID.Hex(),
+		TsMill:         v.TimeMill,
+		Level:          int32(v.Level),
+		Short:          v.Short,
+		Full:           v.Full,
+		ConditionOne:   v.Condition1,
+		ConditionTwo:   v.Condition2,
+		ConditionThree: v.Condition3,
+		IP:             v.IP,
+		TraceID:        v.TraceID,
+	}
 }
 
 // DropLoggingCollection manual delete collection, release storage disk space
@@ -156,13 +381,27 @@ func (svc *Service) DropLoggingCollection(ctx context.Context, in *model.DropLog
 		return errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("collection name not '%s' prefix", m.LoggingPrefix()))
 	}
 
-	if err := svc.d.DropLoggingCollection(ctx, m, in.Collection); err != nil {
+	dbName := in.Database
+	if dbName == "" {
+		dbName = m.Database
+		docs, err := svc.d.FindShard(ctx, bson.M{"module_name": m.Name, "collection": in.Collection, "migrated_to": ""})
+		if err != nil {
+			return errc.ErrInternalErr.MultiErr(err)
+		}
+		if len(docs) > 0 {
+			dbName = docs[0].Database
+		}
+	} else if !svc.cfg.IsLoggingDatabase(dbName) {
+		return errc.ErrParamInvalid.MultiMsg("database not receiver database")
+	}
+	if err := svc.d.DropLoggingCollection(ctx, m.Name, dbName, in.Collection); err != nil {
 		return err
 	}
 	return nil
 }
 
-// auto delete expired collection, release storage disk space
+// auto delete expired collection, release storage disk space. archived before dropped by module archive policy.
+// shard expired by time range in shard catalog, dropped from database it located
 func (svc *Service) bgDelExpiredCollection() {
 	for {
 		time.Sleep(time.Duration(rand.Intn(30)+30) * time.Minute)
@@ -176,23 +415,25 @@ func (svc *Service) bgDelExpiredCollection() {
 			if m.MaxMonth <= 0 {
 				continue
 			}
-			sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
-			cNames, err := svc.d.ListCollectionNames(context.Background(), m.Database, m.LoggingPrefix())
+			shards, err := svc.d.FindShard(context.Background(), bson.M{
+				"module_name": m.Name,
+				"end_ts":      bson.M{"$lte": time.Now().AddDate(0, -m.MaxMonth, 0).Unix()},
+			})
 			if err != nil {
-				logs.Qezap.Error("bgDelExpiredCollection", zap.String("ListCollectionNames", err.Error()))
+				logs.Qezap.Error("bgDelExpiredCollection", zap.String("FindShard", err.Error()))
 				continue
 			}
-			for _, cName := range cNames {
-				t, err := sc.CollNameDate(cName)
-				if err != nil {
-					logs.Qezap.Error("bgDelExpiredCollection", zap.String("CollNameDate", err.Error()))
+			for _, shard := range shards {
+				if ok, err := svc.archiveExpiredCollection(context.Background(), m, shard); !ok {
+					if err != nil {
+						logs.Qezap.Error("bgDelExpiredCollection", zap.String("archiveExpiredCollection", err.Error()),
+							zap.String("module", m.Name), zap.String("database", shard.Database), zap.String("collection", shard.Collection))
+					}
 					continue
 				}
-				if t.AddDate(0, m.MaxMonth, 0).Before(time.Now()) {
-					if err := svc.d.DropLoggingCollection(context.Background(), m, cName); err != nil {
-						logs.Qezap.Error("bgDelExpiredCollection", zap.String("DropLoggingCollection", err.Error()))
-						continue
-					}
+				if err := svc.d.DropLoggingCollection(context.Background(), m.Name, shard.Database, shard.Collection); err != nil {
+					logs.Qezap.Error("bgDelExpiredCollection", zap.String("DropLoggingCollection", err.Error()))
+					continue
 				}
 			}
 		}
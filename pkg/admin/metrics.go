@@ -16,7 +16,6 @@ import (
 	"github.com/bbdshow/qelog/pkg/model"
 	"github.com/bbdshow/qelog/pkg/types"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
 
@@ -79,7 +78,8 @@ func (svc *Service) metricsDBStats(conn mongo.Conn) error {
 	return svc.d.UpsertDBStats(ctx, doc)
 }
 
-// interval statistics mongo collection stats info, and store
+// interval statistics mongo collection stats info, and store. shards of module found by shard catalog,
+// history shards in other database included
 func (svc *Service) bgMetricsCollectionStats() {
 	for {
 		time.Sleep(time.Duration(rand.Intn(30)+30) * time.Minute)
@@ -89,26 +89,51 @@ func (svc *Service) bgMetricsCollectionStats() {
 			logs.Qezap.Error("bgMetricsCollectionStats", zap.String("FindAllModule", err.Error()))
 			continue
 		}
-		// find shard conn by module info
 		for _, m := range modules {
-			for _, conn := range svc.cfg.Mongo.Conns {
-				if conn.Database == m.Database {
-					colls, err := svc.d.ListCollectionNames(ctx, m.Database, m.LoggingPrefix())
-					if err != nil {
-						logs.Qezap.Error("bgMetricsCollectionStats", zap.String("ListCollectionNames", err.Error()))
-						continue
+			dbNames, dbColls, err := svc.moduleShardDatabases(ctx, m)
+			if err != nil {
+				logs.Qezap.Error("bgMetricsCollectionStats", zap.String("FindShard", err.Error()))
+				continue
+			}
+			for _, dbName := range dbNames {
+				if host := svc.storeCollStatsHost(dbName); host != "" {
+					if err := svc.metricsStoreCollStats(m, dbName, host, dbColls[dbName]); err != nil {
+						logs.Qezap.Error("bgMetricsCollectionStats", zap.String("metricsStoreCollStats", err.Error()))
 					}
-					if err := svc.metricsCollStats(conn, m, colls); err != nil {
-						logs.Qezap.Error("bgMetricsDBStats", zap.String("metricsCollStats", err.Error()))
-						continue
+					continue
+				}
+				// find shard conn by database
+				for _, conn := range svc.cfg.Mongo.Conns {
+					if conn.Database == dbName {
+						if err := svc.metricsCollStats(conn, m, dbColls[dbName]); err != nil {
+							logs.Qezap.Error("bgMetricsDBStats", zap.String("metricsCollStats", err.Error()))
+							continue
+						}
+						time.Sleep(3 * time.Second)
 					}
-					time.Sleep(3 * time.Second)
 				}
 			}
 		}
 	}
 }
 
+// moduleShardDatabases shard collections of module group by database, database in order of first shard
+func (svc *Service) moduleShardDatabases(ctx context.Context, m *model.Module) ([]string, map[string][]string, error) {
+	shards, err := svc.d.FindShard(ctx, bson.M{"module_name": m.Name})
+	if err != nil {
+		return nil, nil, err
+	}
+	dbNames := make([]string, 0)
+	dbColls := map[string][]string{}
+	for _, v := range shards {
+		if _, ok := dbColls[v.Database]; !ok {
+			dbNames = append(dbNames, v.Database)
+		}
+		dbColls[v.Database] = append(dbColls[v.Database], v.Collection)
+	}
+	return dbNames, dbColls, nil
+}
+
 func (svc *Service) metricsCollStats(conn mongo.Conn, m *model.Module, colls []string) error {
 	validColls := make([]string, 0)
 	host := strings.Join(mongo.URIToHosts(conn.URI), ",")
@@ -158,6 +183,7 @@ func (svc *Service) metricsCollStats(conn mongo.Conn, m *model.Module, colls []s
 			Capped:         stats.Capped,
 			TotalIndexSize: stats.TotalIndexSize,
 			IndexSizes:     stats.IndexSizes,
+			TextIndexSize:  stats.IndexSizes[model.LoggingTextIndexName],
 		}
 		// format ns string, delete db. string
 		ns := strings.Replace(stats.Ns, fmt.Sprintf("%s.", conn.Database), "", 1)
@@ -166,6 +192,68 @@ func (svc *Service) metricsCollStats(conn mongo.Conn, m *model.Module, colls []s
 			logs.Qezap.Error("metricsCollStats", zap.String("UpsertCollStats", err.Error()))
 			continue
 		}
+		if err := svc.d.UpdateShardStats(ctx, m.Name, conn.Database, doc.Name, doc.Count, doc.Size); err != nil {
+			logs.Qezap.Error("metricsCollStats", zap.String("UpdateShardStats", err.Error()))
+		}
+	}
+	return nil
+}
+
+// storeCollStatsHost database logging not in mongo conn, stats read by storage, host as storage type.
+// empty stats read from mongo conn
+func (svc *Service) storeCollStatsHost(dbName string) string {
+	if svc.cfg.ClickHouse.IsDatabase(dbName) {
+		return model.StorageClickHouse
+	}
+	if svc.cfg.Storage.Embedded {
+		return model.StorageLocal
+	}
+	return ""
+}
+
+// metricsStoreCollStats shard stats read by logging storage
+func (svc *Service) metricsStoreCollStats(m *model.Module, dbName, host string, colls []string) error {
+	ctx := context.Background()
+	beforeDay := itime.BeforeDayDate(1)
+	for _, coll := range colls {
+		filter := bson.M{
+			"module_name": m.Name,
+			"host":        host,
+			"db":          dbName,
+			"name":        coll,
+			"updated_at":  bson.M{"$gt": beforeDay},
+		}
+		exists, _, err := svc.d.GetCollStats(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		stats, err := svc.d.ReadLoggingCollStats(ctx, dbName, coll)
+		if err != nil {
+			logs.Qezap.Error("metricsStoreCollStats", zap.String("ReadLoggingCollStats", err.Error()))
+			continue
+		}
+		doc := &model.CollStats{
+			ModuleName:  m.Name,
+			Host:        host,
+			DB:          dbName,
+			Name:        coll,
+			Size:        stats.Size,
+			Count:       stats.Count,
+			StorageSize: stats.StorageSize,
+		}
+		if stats.Count > 0 {
+			doc.AvgObjSize = stats.Size / stats.Count
+		}
+		if err := svc.d.UpsertCollStats(ctx, doc); err != nil {
+			logs.Qezap.Error("metricsStoreCollStats", zap.String("UpsertCollStats", err.Error()))
+			continue
+		}
+		if err := svc.d.UpdateShardStats(ctx, m.Name, dbName, coll, doc.Count, doc.Size); err != nil {
+			logs.Qezap.Error("metricsStoreCollStats", zap.String("UpdateShardStats", err.Error()))
+		}
 	}
 	return nil
 }
@@ -206,25 +294,34 @@ func (svc *Service) MetricsCollStats(ctx context.Context, in *model.MetricsCollS
 	if !exists {
 		return errc.ErrNotFound.MultiMsg(in.ModuleName)
 	}
-	var validConn mongo.Conn
-	for _, conn := range svc.cfg.Mongo.Conns {
-		if m.Database == m.Database {
-			validConn = conn
-			break
-		}
+	// readable shards in shard catalog, migrated copy ignored
+	shards, err := svc.d.FindShard(ctx, bson.M{"module_name": m.Name, "migrated_to": ""})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
 	}
-	if validConn.Database == "" {
-		return errc.ErrNotFound.MultiMsg(fmt.Sprintf("%s not found %s database", m.Name, m.Database))
+	hosts := map[string]string{}
+	located := make(map[string]struct{}, len(shards))
+	dbNames := bson.A{}
+	for _, v := range shards {
+		located[v.Database+"."+v.Collection] = struct{}{}
+		if _, ok := hosts[v.Database]; ok {
+			continue
+		}
+		host := svc.storeCollStatsHost(v.Database)
+		if host == "" {
+			for _, conn := range svc.cfg.Mongo.Conns {
+				if conn.Database == v.Database {
+					host = strings.Join(mongo.URIToHosts(conn.URI), ",")
+					break
+				}
+			}
+		}
+		hosts[v.Database] = host
+		dbNames = append(dbNames, v.Database)
 	}
-	host := strings.Join(mongo.URIToHosts(validConn.URI), ",")
 	filter := bson.M{
 		"module_name": m.Name,
-		"host":        host,
-		"db":          m.Database,
-		"name": primitive.Regex{
-			Pattern: fmt.Sprintf("%s_", m.LoggingPrefix()),
-			Options: "i",
-		},
+		"db":          bson.M{"$in": dbNames},
 	}
 	docs, err := svc.d.FindCollStats(ctx, filter)
 	if err != nil {
@@ -233,6 +330,9 @@ func (svc *Service) MetricsCollStats(ctx context.Context, in *model.MetricsCollS
 
 	list := make([]*model.CollStat, 0, len(docs))
 	for _, v := range docs {
+		if _, ok := located[v.DB+"."+v.Name]; !ok || hosts[v.DB] != v.Host {
+			continue
+		}
 		d := &model.CollStat{
 			ModuleName:     v.ModuleName,
 			Host:           v.Host,
@@ -245,6 +345,7 @@ func (svc *Service) MetricsCollStats(ctx context.Context, in *model.MetricsCollS
 			Capped:         v.Capped,
 			TotalIndexSize: v.TotalIndexSize,
 			IndexSizes:     v.IndexSizes,
+			TextIndexSize:  v.TextIndexSize,
 			UpdatedTsSec:   v.UpdatedAt.Unix(),
 			CreatedTsSec:   v.CreatedAt.Unix(),
 		}
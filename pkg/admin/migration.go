@@ -0,0 +1,335 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// CreateMigrationJob copy shards of module between receiver databases in background.
+// from database can not be module current database, receiver still writing into it,
+// update module database to target first, then migrate history shards
+func (svc *Service) CreateMigrationJob(ctx context.Context, in *model.CreateMigrationJobReq, out *model.CreateMigrationJobResp) error {
+	if !svc.cfg.MongoGroup.IsReceiverDatabase(in.FromDatabase) || !svc.cfg.MongoGroup.IsReceiverDatabase(in.ToDatabase) {
+		return errc.ErrParamInvalid.MultiMsg("database not receiver database")
+	}
+	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": in.ModuleName})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("module")
+	}
+	if m.Database == in.FromDatabase {
+		return errc.ErrParamInvalid.MultiMsg("from database is module current database, update module database first")
+	}
+	// checked and reserved at once, concurrent request of same module rejected
+	if !svc.reserveMigration(m.Name) {
+		return errc.ErrParamInvalid.MultiMsg("module migration job running")
+	}
+	started := false
+	defer func() {
+		if !started {
+			svc.releaseMigration(m.Name)
+		}
+	}()
+
+	// migrated copy in from database not migrated again
+	docs, err := svc.d.FindShard(ctx, bson.M{"module_name": m.Name, "database": in.FromDatabase, "migrated_to": ""})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	located := make(map[string]struct{}, len(docs))
+	for _, v := range docs {
+		located[v.Collection] = struct{}{}
+	}
+	collections := in.Collections
+	if len(collections) == 0 {
+		for v := range located {
+			collections = append(collections, v)
+		}
+	}
+	sort.Strings(collections)
+	shards := make([]model.MigrationShard, 0, len(collections))
+	for _, v := range collections {
+		if _, ok := located[v]; !ok {
+			return errc.ErrNotFound.MultiMsg(fmt.Sprintf("%s in %s", v, in.FromDatabase))
+		}
+		shards = append(shards, model.MigrationShard{Collection: v, Status: model.MigrationShardPending})
+	}
+	if len(shards) == 0 {
+		return errc.ErrNotFound.MultiMsg(fmt.Sprintf("module shard in %s", in.FromDatabase))
+	}
+
+	job := &model.MigrationJob{
+		ModuleName:   m.Name,
+		FromDatabase: in.FromDatabase,
+		ToDatabase:   in.ToDatabase,
+		DropSource:   in.DropSource,
+		Status:       model.MigrationPending,
+		Shards:       shards,
+		CreatedAt:    time.Now(),
+	}
+	if err := svc.d.CreateMigrationJob(ctx, job); err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	svc.startMigrationJob(job)
+	started = true
+	out.ID = job.ID.Hex()
+	return nil
+}
+
+// FindMigrationJobList migration job of module
+func (svc *Service) FindMigrationJobList(ctx context.Context, in *model.FindMigrationJobListReq, out *model.ListResp) error {
+	c, docs, err := svc.d.FindMigrationJobList(ctx, in)
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	out.Count = c
+	list := make([]*model.FindMigrationJobList, 0, len(docs))
+	for _, v := range docs {
+		list = append(list, toMigrationJobItem(v))
+	}
+	out.List = list
+	return nil
+}
+
+// GetMigrationJob job progress
+func (svc *Service) GetMigrationJob(ctx context.Context, in *model.GetMigrationJobReq, out *model.FindMigrationJobList) error {
+	id, err := in.ObjectID()
+	if err != nil {
+		return err
+	}
+	exists, doc, err := svc.d.GetMigrationJob(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("migration job")
+	}
+	*out = *toMigrationJobItem(doc)
+	return nil
+}
+
+// CancelMigrationJob stop running job, switched shard kept in target database
+func (svc *Service) CancelMigrationJob(ctx context.Context, in *model.CancelMigrationJobReq) error {
+	id, err := in.ObjectID()
+	if err != nil {
+		return err
+	}
+	svc.migrationMutex.Lock()
+	cancel, ok := svc.migrations[id.Hex()]
+	svc.migrationMutex.Unlock()
+	if !ok {
+		return errc.ErrNotFound.MultiMsg("running migration job")
+	}
+	cancel()
+	return nil
+}
+
+func toMigrationJobItem(v *model.MigrationJob) *model.FindMigrationJobList {
+	d := &model.FindMigrationJobList{
+		ID:           v.ID.Hex(),
+		ModuleName:   v.ModuleName,
+		FromDatabase: v.FromDatabase,
+		ToDatabase:   v.ToDatabase,
+		DropSource:   v.DropSource,
+		Status:       v.Status,
+		Shards:       v.Shards,
+		Error:        v.Error,
+		CreatedTsSec: v.CreatedAt.Unix(),
+	}
+	if !v.StartedAt.IsZero() {
+		d.StartedTsSec = v.StartedAt.Unix()
+	}
+	if !v.FinishedAt.IsZero() {
+		d.FinishedTsSec = v.FinishedAt.Unix()
+	}
+	src, copied := int64(0), int64(0)
+	for _, s := range v.Shards {
+		src += s.SrcCount
+		copied += s.Copied
+	}
+	if src > 0 {
+		d.Progress = math.Min(float64(copied)/float64(src), 1)
+	} else if v.Status == model.MigrationSucceeded {
+		d.Progress = 1
+	}
+	return d
+}
+
+// resumeMigrationJob job interrupted by restart run again, copied logging written again ignored
+func (svc *Service) resumeMigrationJob() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	jobs, err := svc.d.FindUnfinishedMigrationJob(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		// unfinished job of same module resumed at next restart
+		if !svc.reserveMigration(job.ModuleName) {
+			logs.Qezap.Warn("MigrationJob", zap.String("id", job.ID.Hex()), zap.String("resume", "module migration job running"))
+			continue
+		}
+		svc.startMigrationJob(job)
+	}
+	return nil
+}
+
+// reserveMigration one running job each module, false module job running
+func (svc *Service) reserveMigration(moduleName string) bool {
+	svc.migrationMutex.Lock()
+	defer svc.migrationMutex.Unlock()
+	if _, ok := svc.migrationModules[moduleName]; ok {
+		return false
+	}
+	svc.migrationModules[moduleName] = struct{}{}
+	return true
+}
+
+func (svc *Service) releaseMigration(moduleName string) {
+	svc.migrationMutex.Lock()
+	delete(svc.migrationModules, moduleName)
+	svc.migrationMutex.Unlock()
+}
+
+// startMigrationJob module of job reserved before started, released when job finished
+func (svc *Service) startMigrationJob(job *model.MigrationJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc.migrationMutex.Lock()
+	svc.migrations[job.ID.Hex()] = cancel
+	svc.migrationMutex.Unlock()
+
+	go func() {
+		err := svc.runMigrationJob(ctx, job)
+		job.FinishedAt = time.Now()
+		switch {
+		case err == nil:
+			job.Status = model.MigrationSucceeded
+		case ctx.Err() != nil:
+			job.Status = model.MigrationCanceled
+		default:
+			job.Status = model.MigrationFailed
+			job.Error = err.Error()
+			logs.Qezap.Error("MigrationJob", zap.String("id", job.ID.Hex()), zap.Error(err))
+		}
+		// released before finished status saved, job of module can be created once finished seen
+		cancel()
+		svc.migrationMutex.Lock()
+		delete(svc.migrations, job.ID.Hex())
+		delete(svc.migrationModules, job.ModuleName)
+		svc.migrationMutex.Unlock()
+
+		uctx, ucancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer ucancel()
+		if err := svc.d.UpdateMigrationJob(uctx, job); err != nil {
+			logs.Qezap.Error("UpdateMigrationJob", zap.String("id", job.ID.Hex()), zap.Error(err))
+		}
+	}()
+}
+
+func (svc *Service) runMigrationJob(ctx context.Context, job *model.MigrationJob) error {
+	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": job.ModuleName})
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("module not found")
+	}
+	job.Status = model.MigrationRunning
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now()
+	}
+	if err := svc.d.UpdateMigrationJob(ctx, job); err != nil {
+		return err
+	}
+	for i := range job.Shards {
+		if err := svc.migrateShard(ctx, job, m, &job.Shards[i]); err != nil {
+			return fmt.Errorf("%s %v", job.Shards[i].Collection, err)
+		}
+	}
+	return nil
+}
+
+// migrateShard copy by cursor, logging id kept, duplicate ignored. so interrupted shard copied again from beginning.
+// verified target count not less than source, then reads switched
+func (svc *Service) migrateShard(ctx context.Context, job *model.MigrationJob, m *model.Module, shard *model.MigrationShard) error {
+	switch shard.Status {
+	case model.MigrationShardDropped:
+		return nil
+	case model.MigrationShardSwitched:
+		return svc.dropMigratedShard(ctx, job, shard)
+	}
+	src, err := svc.d.ReadLoggingCollStats(ctx, job.FromDatabase, shard.Collection)
+	if err != nil {
+		return err
+	}
+	shard.Status = model.MigrationShardCopying
+	shard.SrcCount = src.Count
+	shard.Copied = 0
+	if err := svc.d.UpdateMigrationJob(ctx, job); err != nil {
+		return err
+	}
+	if err := svc.d.CreateLoggingIndex(job.ToDatabase, shard.Collection, m.FullText); err != nil {
+		return err
+	}
+
+	err = svc.forEachLogging(ctx, job.FromDatabase, shard.Collection, func(docs []*model.Logging) error {
+		batch := make([]interface{}, 0, len(docs))
+		for _, v := range docs {
+			batch = append(batch, v)
+		}
+		if err := svc.d.CreateManyLogging(ctx, job.ToDatabase, shard.Collection, batch); err != nil {
+			return err
+		}
+		shard.Copied += int64(len(docs))
+		return svc.d.UpdateMigrationJob(ctx, job)
+	})
+	if err != nil {
+		return err
+	}
+
+	dst, err := svc.d.ReadLoggingCollStats(ctx, job.ToDatabase, shard.Collection)
+	if err != nil {
+		return err
+	}
+	shard.DstCount = dst.Count
+	if shard.Copied < shard.SrcCount || shard.DstCount < shard.SrcCount {
+		return fmt.Errorf("verify count failed, source %d, copied %d, target %d", shard.SrcCount, shard.Copied, shard.DstCount)
+	}
+	exists, doc, err := svc.d.GetShard(ctx, bson.M{"module_name": m.Name, "database": job.FromDatabase, "collection": shard.Collection})
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("shard catalog not found")
+	}
+	if err := svc.d.SwitchShard(ctx, doc, job.ToDatabase); err != nil {
+		return err
+	}
+	shard.Status = model.MigrationShardSwitched
+	if err := svc.d.UpdateMigrationJob(ctx, job); err != nil {
+		return err
+	}
+	return svc.dropMigratedShard(ctx, job, shard)
+}
+
+func (svc *Service) dropMigratedShard(ctx context.Context, job *model.MigrationJob, shard *model.MigrationShard) error {
+	if !job.DropSource {
+		return nil
+	}
+	if err := svc.d.DropLoggingCollection(ctx, job.ModuleName, job.FromDatabase, shard.Collection); err != nil {
+		return err
+	}
+	shard.Status = model.MigrationShardDropped
+	return svc.d.UpdateMigrationJob(ctx, job)
+}
@@ -2,10 +2,13 @@ package admin
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"regexp"
 	"time"
 
+	"github.com/bbdshow/bkit/db/mongo"
 	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/bkit/gen/str"
 	"github.com/bbdshow/qelog/pkg/model"
@@ -31,25 +34,52 @@ func (svc *Service) FindModuleList(ctx context.Context, in *model.FindModuleList
 			MaxMonth:     v.MaxMonth,
 			Database:     v.Database,
 			Prefix:       v.Prefix,
+			FullText:     v.FullText,
+			Storage:      v.StorageType(),
+			Archive:      v.ArchiveType(),
+			AccessToken:  v.AccessToken,
 			UpdatedTsSec: v.UpdatedAt.Unix(),
 		}
+		if v.PrevAccessToken != "" && v.PrevTokenExpiredAt.After(time.Now()) {
+			d.PrevTokenExpiredTsSec = v.PrevTokenExpiredAt.Unix()
+		}
 		list = append(list, d)
 	}
 	out.List = list
 	return nil
 }
 
-// CreateModule create module info
+// CreateModule create module info, generate access token
 func (svc *Service) CreateModule(ctx context.Context, in *model.CreateModuleReq) error {
+	token, err := genAccessToken()
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	return svc.createModule(ctx, in, token)
+}
+
+func (svc *Service) createModule(ctx context.Context, in *model.CreateModuleReq, token string) error {
+	database, err := svc.storageDatabase(ctx, in.Storage, "")
+	if err != nil {
+		return err
+	}
+	if err := svc.verifyArchive(in.Archive); err != nil {
+		return err
+	}
 	doc := &model.Module{
 		Name:      in.Name,
 		Desc:      in.Desc,
 		Bucket:    str.RandAlphaNumString(6, true),
 		DaySpan:   in.DaySpan,
 		MaxMonth:  in.MaxMonth,
-		Database:  svc.cfg.MongoGroup.RandReceiverDatabase(),
+		Database:  database,
 		Prefix:    "lg",
+		FullText:  in.FullText,
+		Storage:   in.Storage,
+		Archive:   in.Archive,
 		UpdatedAt: time.Now(),
+
+		AccessToken: token,
 	}
 	if err := svc.d.CreateModule(ctx, doc); err != nil {
 		return errc.ErrInternalErr.MultiErr(err)
@@ -57,9 +87,40 @@ func (svc *Service) CreateModule(ctx context.Context, in *model.CreateModuleReq)
 	return nil
 }
 
+// storageDatabase module logging database by storage type, clickhouse only one database.
+// mongo database not receiver database, placed by placement policy
+func (svc *Service) storageDatabase(ctx context.Context, storage, database string) (string, error) {
+	if storage == model.StorageClickHouse {
+		if !svc.cfg.ClickHouse.Enabled() {
+			return "", errc.ErrParamInvalid.MultiMsg("clickhouse storage not enabled")
+		}
+		return svc.cfg.ClickHouse.Database, nil
+	}
+	if database == "" || !svc.cfg.MongoGroup.IsReceiverDatabase(database) {
+		placed, err := svc.placeDatabase(ctx)
+		if err != nil {
+			return "", errc.ErrInternalErr.MultiErr(err)
+		}
+		if placed == "" {
+			return "", errc.ErrParamInvalid.MultiMsg("receiver database not placeable")
+		}
+		return placed, nil
+	}
+	return database, nil
+}
+
+// verifyArchive s3 archive policy required s3 configured
+func (svc *Service) verifyArchive(archive string) error {
+	if archive == model.ArchiveS3 && !svc.cfg.Archive.S3.Enabled() {
+		return errc.ErrParamInvalid.MultiMsg("archive s3 not configured")
+	}
+	return nil
+}
+
 // UpdateModule update module info
+// storage empty not changed, storage changed, history logging still in previous database
 func (svc *Service) UpdateModule(ctx context.Context, in *model.UpdateModuleReq) error {
-	if in.Database != "" {
+	if in.Database != "" && !svc.cfg.ClickHouse.IsDatabase(in.Database) {
 		if !svc.cfg.MongoGroup.IsExists(in.Database) {
 			return errc.ErrNotFound.MultiMsg(fmt.Sprintf("%s database", in.Database))
 		}
@@ -73,9 +134,76 @@ func (svc *Service) UpdateModule(ctx context.Context, in *model.UpdateModuleReq)
 			return errc.ErrParamInvalid.MultiMsg("prefix must regexp [a-z]")
 		}
 	}
+	if err := svc.verifyArchive(in.Archive); err != nil {
+		return err
+	}
+	id, err := in.ObjectID()
+	if err != nil {
+		return err
+	}
+	exists, doc, err := svc.d.GetModule(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("module")
+	}
+	if in.Storage == "" {
+		in.Storage = doc.StorageType()
+	}
+	if in.Storage == model.StorageClickHouse || in.Storage != doc.StorageType() || svc.cfg.ClickHouse.IsDatabase(in.Database) {
+		if in.Database, err = svc.storageDatabase(ctx, in.Storage, in.Database); err != nil {
+			return err
+		}
+	}
 	if err := svc.d.UpdateModule(ctx, in); err != nil {
 		return errc.ErrInternalErr.MultiErr(err)
 	}
+	if in.FullText != nil && doc.FullText != *in.FullText {
+		doc.FullText = *in.FullText
+		if err := svc.switchLoggingTextIndex(ctx, doc); err != nil {
+			return errc.ErrInternalErr.MultiErr(err)
+		}
+	}
+	return nil
+}
+
+// switchLoggingTextIndex full text enabled, all shards of module create text index, keyword query covered history shards.
+// current shard included, not registered in catalog until sync. disabled, all shards drop text index, release storage
+func (svc *Service) switchLoggingTextIndex(ctx context.Context, m *model.Module) error {
+	shards, err := svc.d.FindShard(ctx, bson.M{"module_name": m.Name, "migrated_to": ""})
+	if err != nil {
+		return err
+	}
+	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
+	current := &model.Shard{Database: m.Database, Collection: sc.EncodeCollName(m.Bucket, time.Now().Unix())}
+	shards = append(shards, current)
+	if !m.FullText {
+		// collections of module in current database, not registered included
+		names, err := svc.d.ListCollectionNames(ctx, m.Database, m.LoggingPrefix())
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			shards = append(shards, &model.Shard{Database: m.Database, Collection: name})
+		}
+	}
+	done := map[string]struct{}{}
+	for _, v := range shards {
+		key := v.Database + "." + v.Collection
+		if _, ok := done[key]; ok {
+			continue
+		}
+		done[key] = struct{}{}
+		if m.FullText {
+			err = svc.d.CreateLoggingTextIndex(ctx, v.Database, v.Collection)
+		} else {
+			err = svc.d.DropLoggingTextIndex(ctx, v.Database, v.Collection)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -100,3 +228,39 @@ func (svc *Service) DelModule(ctx context.Context, in *model.DelModuleReq) error
 	}
 	return nil
 }
+
+// RotateModuleToken generate new access token, previous token still valid in grace period
+func (svc *Service) RotateModuleToken(ctx context.Context, in *model.RotateModuleTokenReq, out *model.RotateModuleTokenResp) error {
+	id, err := in.ObjectID()
+	if err != nil {
+		return err
+	}
+	exists, doc, err := svc.d.GetModule(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists || doc.Name != in.Name {
+		return errc.ErrNotFound
+	}
+	token, err := genAccessToken()
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	prevExpiredAt := time.Now().Add(time.Duration(in.GraceSec) * time.Second)
+	if err := svc.d.UpdateModuleToken(ctx, doc, token, prevExpiredAt); err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	out.AccessToken = token
+	if doc.AccessToken != "" && in.GraceSec > 0 {
+		out.PrevTokenExpiredTsSec = prevExpiredAt.Unix()
+	}
+	return nil
+}
+
+func genAccessToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
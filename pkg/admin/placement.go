@@ -0,0 +1,217 @@
+package admin
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/util/itime"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultRebalanceRatio = 1.5
+	maxRebalanceMoves     = 20
+)
+
+// moduleLoad logging of module in receiver database
+type moduleLoad struct {
+	name         string
+	database     string
+	storageSize  int64
+	ingestNumber int64
+}
+
+// placeDatabase receiver database of new module by placement policy.
+// database weight 0 not placed, equal load random one
+func (svc *Service) placeDatabase(ctx context.Context) (string, error) {
+	mg := svc.cfg.MongoGroup
+	switch mg.Placement {
+	case model.PlacementWeight:
+		return mg.WeightReceiverDatabase(), nil
+	case model.PlacementStorage, model.PlacementIngest:
+	default:
+		return mg.RandReceiverDatabase(), nil
+	}
+	loads, _, err := svc.databaseLoads(ctx)
+	if err != nil {
+		return "", err
+	}
+	candidates := make([]string, 0)
+	min := int64(-1)
+	for _, v := range loads {
+		if v.Weight <= 0 {
+			continue
+		}
+		n := v.StorageSize
+		if mg.Placement == model.PlacementIngest {
+			n = v.IngestNumber
+		}
+		if min < 0 || n < min {
+			min = n
+			candidates = candidates[:0]
+		}
+		if n == min {
+			candidates = append(candidates, v.Database)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
+// databaseLoads storage and recent ingest of receiver databases, in order of config.
+// storage by db_stats, database not collected yet summed by shard catalog. ingest by module_metrics of recent 2 days
+func (svc *Service) databaseLoads(ctx context.Context) ([]*model.DatabaseLoad, []*moduleLoad, error) {
+	mg := svc.cfg.MongoGroup
+	loads := make([]*model.DatabaseLoad, 0, len(mg.ReceiverDatabase))
+	dbLoad := map[string]*model.DatabaseLoad{}
+	for _, v := range mg.ReceiverDatabase {
+		l := &model.DatabaseLoad{Database: v, Weight: mg.Weight(v)}
+		loads = append(loads, l)
+		dbLoad[v] = l
+	}
+
+	modules, err := svc.d.FindAllModule(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	moduleLoads := make([]*moduleLoad, 0, len(modules))
+	byName := map[string]*moduleLoad{}
+	for _, m := range modules {
+		l, ok := dbLoad[m.Database]
+		if !ok {
+			continue
+		}
+		l.Modules++
+		ml := &moduleLoad{name: m.Name, database: m.Database}
+		moduleLoads = append(moduleLoads, ml)
+		byName[m.Name] = ml
+	}
+
+	// shard size updated by collection stats, only readable shard
+	shards, err := svc.d.FindShard(ctx, bson.M{"migrated_to": ""})
+	if err != nil {
+		return nil, nil, err
+	}
+	catalogSize := map[string]int64{}
+	for _, v := range shards {
+		catalogSize[v.Database] += v.Size
+		if ml, ok := byName[v.ModuleName]; ok && ml.database == v.Database {
+			ml.storageSize += v.Size
+		}
+	}
+	stats, err := svc.d.FindDBStats(ctx, bson.M{})
+	if err != nil {
+		return nil, nil, err
+	}
+	collected := map[string]struct{}{}
+	for _, v := range stats {
+		if l, ok := dbLoad[v.DB]; ok {
+			l.StorageSize += v.StorageSize
+			collected[v.DB] = struct{}{}
+		}
+	}
+	for _, l := range loads {
+		if _, ok := collected[l.Database]; !ok {
+			l.StorageSize = catalogSize[l.Database]
+		}
+	}
+
+	metrics, err := svc.d.FindMetricsModule(ctx, bson.M{"created_date": bson.M{"$gte": itime.BeforeDayDate(1)}})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range metrics {
+		if ml, ok := byName[v.ModuleName]; ok {
+			ml.ingestNumber += v.Number
+			dbLoad[ml.database].IngestNumber += v.Number
+		}
+	}
+	return loads, moduleLoads, nil
+}
+
+// RebalanceAdvice suggest module moves when receiver database load far above mean.
+// heaviest database module moved to lightest one, module load less than gap of them, until no database above mean ratio
+func (svc *Service) RebalanceAdvice(ctx context.Context, in *model.RebalanceAdviceReq, out *model.RebalanceAdviceResp) error {
+	loads, moduleLoads, err := svc.databaseLoads(ctx)
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	out.By = in.By
+	if out.By == "" {
+		out.By = model.PlacementStorage
+	}
+	out.Ratio = in.Ratio
+	if out.Ratio <= 1 {
+		out.Ratio = defaultRebalanceRatio
+	}
+	out.Databases = loads
+	out.Moves = make([]*model.RebalanceMove, 0)
+
+	loadOf := func(storage, ingest int64) int64 {
+		if out.By == model.PlacementIngest {
+			return ingest
+		}
+		return storage
+	}
+	value := map[string]int64{}
+	sum := int64(0)
+	for _, v := range loads {
+		value[v.Database] = loadOf(v.StorageSize, v.IngestNumber)
+		sum += value[v.Database]
+	}
+	if len(loads) < 2 || sum <= 0 {
+		return nil
+	}
+	out.Mean = float64(sum) / float64(len(loads))
+
+	// larger module first, less moves
+	sort.SliceStable(moduleLoads, func(i, j int) bool {
+		return loadOf(moduleLoads[i].storageSize, moduleLoads[i].ingestNumber) > loadOf(moduleLoads[j].storageSize, moduleLoads[j].ingestNumber)
+	})
+	moved := map[string]struct{}{}
+	for len(out.Moves) < maxRebalanceMoves {
+		var heavy, light *model.DatabaseLoad
+		for _, v := range loads {
+			if heavy == nil || value[v.Database] > value[heavy.Database] {
+				heavy = v
+			}
+			if v.Weight > 0 && (light == nil || value[v.Database] < value[light.Database]) {
+				light = v
+			}
+		}
+		if light == nil || heavy == light || float64(value[heavy.Database]) <= out.Mean*out.Ratio {
+			break
+		}
+		gap := value[heavy.Database] - value[light.Database]
+		var move *moduleLoad
+		for _, ml := range moduleLoads {
+			if _, ok := moved[ml.name]; ok || ml.database != heavy.Database {
+				continue
+			}
+			if n := loadOf(ml.storageSize, ml.ingestNumber); n > 0 && n < gap {
+				move = ml
+				break
+			}
+		}
+		if move == nil {
+			break
+		}
+		n := loadOf(move.storageSize, move.ingestNumber)
+		value[heavy.Database] -= n
+		value[light.Database] += n
+		moved[move.name] = struct{}{}
+		out.Moves = append(out.Moves, &model.RebalanceMove{
+			ModuleName:   move.name,
+			FromDatabase: heavy.Database,
+			ToDatabase:   light.Database,
+			StorageSize:  move.storageSize,
+			IngestNumber: move.ingestNumber,
+		})
+	}
+	return nil
+}
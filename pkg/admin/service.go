@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bbdshow/qelog/pkg/archive"
 	"github.com/bbdshow/qelog/pkg/conf"
 	"github.com/bbdshow/qelog/pkg/dao"
 	"github.com/bbdshow/qelog/pkg/model"
@@ -16,12 +17,24 @@ type Service struct {
 	cfg  *conf.Config
 	d    *dao.Dao
 	once sync.Once
+
+	archiveMutex sync.Mutex
+	archives     map[string]archive.Storage
+
+	// running migration job cancel func by job id, and module of it
+	migrationMutex   sync.Mutex
+	migrations       map[string]context.CancelFunc
+	migrationModules map[string]struct{}
 }
 
 func NewService(cfg *conf.Config) *Service {
 	svc := &Service{
 		cfg: cfg,
 		d:   dao.New(cfg),
+
+		archives:         map[string]archive.Storage{},
+		migrations:       map[string]context.CancelFunc{},
+		migrationModules: map[string]struct{}{},
 	}
 
 	if err := svc.initData(); err != nil {
@@ -29,23 +42,40 @@ func NewService(cfg *conf.Config) *Service {
 	}
 
 	bgOp := func() {
+		go svc.bgSyncShardCatalog()
 		go svc.bgDelExpiredCollection()
 		go svc.bgMetricsCollectionStats()
 		go svc.bgMetricsDBStats()
 	}
 	svc.once.Do(bgOp)
 
+	if err := svc.upgradeAlarmRule(); err != nil {
+		panic(err)
+	}
 	// admin db inst, create collection and index
-	if err := svc.d.AdminInst().UpsertCollectionIndexMany(
+	if err := svc.d.UpsertAdminIndexMany(
 		model.ModuleIndexMany(),
 		model.AlarmRuleIndexMany(),
+		model.AlarmCounterIndexMany(),
+		model.AlarmLeaseIndexMany(),
 		model.DBStatsIndexMany(),
 		model.ModuleMetricsIndexMany(),
 		model.CollStatsIndexMany(),
+		model.ExportAuditIndexMany(),
+		model.ArchiveIndexMany(),
+		model.MigrationJobIndexMany(),
+		model.ShardCatalogIndexMany(),
 	); err != nil {
 		panic(err)
 	}
 
+	if err := svc.resumeMigrationJob(); err != nil {
+		panic(err)
+	}
+	if err := svc.d.FailInterruptedArchiveRestore(context.Background()); err != nil {
+		panic(err)
+	}
+
 	return svc
 }
 
@@ -59,12 +89,13 @@ func (svc *Service) initData() error {
 				return err
 			}
 			if !exists {
-				if err := svc.CreateModule(ctx, &model.CreateModuleReq{
+				// self-access logger can not carry access token, keep module not verify
+				if err := svc.createModule(ctx, &model.CreateModuleReq{
 					Name:     svc.cfg.Logging.Module,
 					Desc:     "self-access",
 					DaySpan:  0,
 					MaxMonth: 6,
-				}); err != nil {
+				}, ""); err != nil {
 					return err
 				}
 			}
@@ -75,6 +106,17 @@ func (svc *Service) initData() error {
 	return nil
 }
 
+// upgradeAlarmRule legacy unique index replaced by rule type index, rule created before set exact
+func (svc *Service) upgradeAlarmRule() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	collection, name := model.AlarmRuleLegacyIndex()
+	if err := svc.d.DropAdminIndex(ctx, collection, name); err != nil {
+		return err
+	}
+	return svc.d.UpdateAlarmRuleType(ctx)
+}
+
 func (svc *Service) Close() {
 	if svc.d != nil {
 		svc.d.Close()
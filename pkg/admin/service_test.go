@@ -1,12 +1,22 @@
 package admin
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/bbdshow/bkit/db/mongo"
 	"github.com/bbdshow/qelog/pkg/conf"
 	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var svc *Service
@@ -30,3 +40,662 @@ func TestService_CreateModule(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestService_RotateModuleToken(t *testing.T) {
+	ctx := context.Background()
+	name := "rotate_token_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	_, doc, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: doc.ID.Hex()}, Name: name})
+	if doc.AccessToken == "" || !doc.VerifyAccessToken(doc.AccessToken) || doc.VerifyAccessToken("") {
+		t.Fatalf("access token not verify %v", doc)
+	}
+
+	in := &model.RotateModuleTokenReq{ObjectIDReq: model.ObjectIDReq{ID: doc.ID.Hex()}, Name: name, GraceSec: 60}
+	out := &model.RotateModuleTokenResp{}
+	if err := svc.RotateModuleToken(ctx, in, out); err != nil {
+		t.Fatal(err)
+	}
+	_, rotated, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.AccessToken != out.AccessToken || rotated.AccessToken == doc.AccessToken {
+		t.Fatalf("token not rotated %v", rotated)
+	}
+	// previous token valid in grace period
+	if !rotated.VerifyAccessToken(doc.AccessToken) || !rotated.VerifyAccessToken(out.AccessToken) {
+		t.Fatal("previous and current token should valid")
+	}
+	rotated.PrevTokenExpiredAt = time.Now().Add(-time.Second)
+	if rotated.VerifyAccessToken(doc.AccessToken) {
+		t.Fatal("previous token expired, should invalid")
+	}
+}
+
+func TestService_FindLoggingListCrossShard(t *testing.T) {
+	ctx := context.Background()
+	name := "cross_shard_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name, DaySpan: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Name: name})
+
+	// logging write both side of midnight
+	y, mon, d := time.Now().Date()
+	midnight := time.Date(y, mon, d, 0, 0, 0, 0, time.Local).Unix()
+	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
+	for i, ts := range []int64{midnight - 20, midnight - 10, midnight + 10, midnight + 20} {
+		cName := sc.EncodeCollName(m.Bucket, ts)
+		if err := svc.d.CreateLoggingIndex(m.Database, cName, false); err != nil {
+			t.Fatal(err)
+		}
+		defer svc.d.DropLoggingCollection(ctx, m.Name, m.Database, cName)
+		doc := &model.Logging{Module: name, Short: "cross", TimeSec: ts, MessageID: fmt.Sprintf("%d", i)}
+		if err := svc.d.CreateManyLogging(ctx, m.Database, cName, []interface{}{doc}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// collections created out of receiver, registered by catalog sync
+	if err := svc.syncShardCatalog(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &model.FindLoggingListReq{ModuleName: name, Level: -2}
+	in.BeginTsSec, in.EndTsSec = midnight-60, midnight+60
+	in.Page, in.Limit = 1, 3
+	out := &model.FindLoggingListResp{}
+	if err := svc.FindLoggingList(ctx, in, out); err != nil {
+		t.Fatal(err)
+	}
+	list := out.List.([]*model.FindLoggingList)
+	if out.Count != 4 || len(list) != 3 || out.NextCursor == "" {
+		t.Fatalf("first page %d %d %s", out.Count, len(list), out.NextCursor)
+	}
+	in.Cursor = out.NextCursor
+	next := &model.FindLoggingListResp{}
+	if err := svc.FindLoggingList(ctx, in, next); err != nil {
+		t.Fatal(err)
+	}
+	nextList := next.List.([]*model.FindLoggingList)
+	if len(nextList) != 1 || nextList[0].ID == list[2].ID || next.NextCursor != "" {
+		t.Fatalf("next page %v %s", nextList, next.NextCursor)
+	}
+
+	// turn back, same as first page
+	in.Cursor = next.PrevCursor
+	prev := &model.FindLoggingListResp{}
+	if err := svc.FindLoggingList(ctx, in, prev); err != nil {
+		t.Fatal(err)
+	}
+	prevList := prev.List.([]*model.FindLoggingList)
+	if len(prevList) != 3 || prevList[0].ID != list[0].ID || prevList[2].ID != list[2].ID {
+		t.Fatalf("prev page %v", prevList)
+	}
+}
+
+func TestService_RebalanceAdvice(t *testing.T) {
+	out := &model.RebalanceAdviceResp{}
+	if err := svc.RebalanceAdvice(context.Background(), &model.RebalanceAdviceReq{By: model.PlacementIngest}, out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Databases) != len(conf.Conf.MongoGroup.ReceiverDatabase) {
+		t.Fatalf("database loads %d, want %d", len(out.Databases), len(conf.Conf.MongoGroup.ReceiverDatabase))
+	}
+}
+
+func TestService_PreviewAlarmTemplate(t *testing.T) {
+	out := &model.PreviewAlarmTemplateResp{}
+	in := &model.PreviewAlarmTemplateReq{Template: "**{{.Rule.Tag}}** {{.Logging.Short}}"}
+	if err := svc.PreviewAlarmTemplate(context.Background(), in, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Content != "**example** sample short message" {
+		t.Fatalf("preview content %s", out.Content)
+	}
+	in.Template = "{{.Logging.Unknown}}"
+	if err := svc.PreviewAlarmTemplate(context.Background(), in, out); err == nil {
+		t.Fatal("unknown field template previewed")
+	}
+}
+
+func TestService_SyncShardCatalogPrefixChanged(t *testing.T) {
+	ctx := context.Background()
+	name := "shard_prefix_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Name: name})
+
+	// shard of previous prefix, registered long ago
+	now := time.Now().Unix()
+	cName := mongo.NewShardCollection(m.Prefix, m.DaySpan).EncodeCollName(m.Bucket, now)
+	if err := svc.d.CreateLoggingIndex(m.Database, cName, false); err != nil {
+		t.Fatal(err)
+	}
+	defer svc.d.DropLoggingCollection(ctx, m.Name, m.Database, cName)
+	old, err := model.NewShard(m, m.Database, cName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.CreatedAt = time.Now().Add(-2 * shardCatalogPruneAfter)
+	if err := svc.d.RegisterShard(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	// collection dropped out of admin
+	dropped := *old
+	dropped.Collection = mongo.NewShardCollection(m.Prefix, m.DaySpan).EncodeCollName(m.Bucket, now-100*86400)
+	if err := svc.d.RegisterShard(ctx, &dropped); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &model.UpdateModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Prefix: "renamed"}
+	if err := svc.UpdateModule(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.syncShardCatalog(ctx); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := svc.d.FindShardByTime(ctx, name, "", now-60, now+60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Collection != cName {
+		t.Fatalf("previous prefix shard pruned %v", docs)
+	}
+	if exists, _, _ := svc.d.GetShard(ctx, bson.M{"module_name": name, "collection": dropped.Collection}); exists {
+		t.Fatal("dropped collection shard not pruned")
+	}
+
+	_, m, err = svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards, err := svc.loggingShards(ctx, m, 0, 0, "", cName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 1 || shards[0].Database != old.Database {
+		t.Fatalf("force previous prefix collection %v", shards)
+	}
+	if _, err := svc.loggingShards(ctx, m, 0, 0, "", "unknown_"+cName); err == nil {
+		t.Fatal("force collection of other prefix allowed")
+	}
+}
+
+func TestService_UpdateModuleFullText(t *testing.T) {
+	ctx := context.Background()
+	name := "full_text_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name, FullText: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Name: name})
+
+	// omitted not changed
+	in := &model.UpdateModuleReq{
+		ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()},
+		Bucket:      m.Bucket,
+		DaySpan:     m.DaySpan,
+		MaxMonth:    m.MaxMonth,
+		Database:    m.Database,
+		Prefix:      m.Prefix,
+		Desc:        "desc",
+	}
+	if err := svc.UpdateModule(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+	if _, m, _ = svc.d.GetModule(ctx, bson.M{"name": name}); !m.FullText || m.Desc != "desc" {
+		t.Fatalf("full text omitted changed %v", m)
+	}
+	disabled := false
+	in.FullText = &disabled
+	if err := svc.UpdateModule(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+	if _, m, _ = svc.d.GetModule(ctx, bson.M{"name": name}); m.FullText {
+		t.Fatal("full text not disabled")
+	}
+}
+
+func TestService_FindLoggingKeywordHistoryShard(t *testing.T) {
+	ctx := context.Background()
+	name := "keyword_history_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name, DaySpan: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Name: name})
+
+	// history shard created before full text enabled, without text index
+	now := time.Now().Unix()
+	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
+	for i, ts := range []int64{now - 2*86400, now} {
+		cName := sc.EncodeCollName(m.Bucket, ts)
+		if err := svc.d.CreateLoggingIndex(m.Database, cName, false); err != nil {
+			t.Fatal(err)
+		}
+		defer svc.d.DropLoggingCollection(ctx, m.Name, m.Database, cName)
+		doc := &model.Logging{ID: primitive.NewObjectID(), Module: name, Short: "payment timeout", TimeSec: ts, MessageID: fmt.Sprintf("%d", i)}
+		if err := svc.d.CreateManyLogging(ctx, m.Database, cName, []interface{}{doc}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.syncShardCatalog(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled := true
+	if err := svc.UpdateModule(ctx, &model.UpdateModuleReq{
+		ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()},
+		Bucket:      m.Bucket,
+		DaySpan:     m.DaySpan,
+		MaxMonth:    m.MaxMonth,
+		Database:    m.Database,
+		Prefix:      m.Prefix,
+		FullText:    &enabled,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &model.FindLoggingListReq{ModuleName: name, Level: -2, Keyword: "timeout"}
+	in.BeginTsSec, in.EndTsSec = now-3*86400, now+60
+	in.Page, in.Limit = 1, 10
+	out := &model.FindLoggingListResp{}
+	if err := svc.FindLoggingList(ctx, in, out); err != nil {
+		t.Fatal(err)
+	}
+	if list := out.List.([]*model.FindLoggingList); len(list) != 2 {
+		t.Fatalf("keyword query history shard %v", list)
+	}
+}
+
+func TestService_TailLoggingMoreThanPoll(t *testing.T) {
+	ctx := context.Background()
+	name := "tail_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Name: name})
+
+	// more than tailMaxPerPoll in look back window
+	now := time.Now().Unix()
+	cName := mongo.NewShardCollection(m.Prefix, m.DaySpan).EncodeCollName(m.Bucket, now)
+	if err := svc.d.CreateLoggingIndex(m.Database, cName, false); err != nil {
+		t.Fatal(err)
+	}
+	defer svc.d.DropLoggingCollection(ctx, m.Name, m.Database, cName)
+	total := tailMaxPerPoll + tailPageLimit*3
+	docs := make([]interface{}, 0, total)
+	for i := 0; i < total; i++ {
+		ts := now - 2 + int64(i%2)
+		docs = append(docs, &model.Logging{ID: primitive.NewObjectID(), Module: name, Short: "tail", TimeSec: ts, TimeMill: ts * 1000,
+			MessageID: fmt.Sprintf("tail_%d", i)})
+	}
+	if err := svc.d.CreateManyLogging(ctx, m.Database, cName, docs); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.syncShardCatalog(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	received := map[string]struct{}{}
+	var lastTsMill int64
+	err = svc.TailLogging(ctx, &model.TailLoggingReq{ModuleName: name, Level: -2}, now-2, func(list []*model.FindLoggingList, _ int64) error {
+		for _, v := range list {
+			if _, ok := received[v.ID]; ok {
+				t.Fatalf("logging %s sent again", v.ID)
+			}
+			if v.TsMill < lastTsMill {
+				t.Fatalf("logging %s not order by ts asc", v.ID)
+			}
+			received[v.ID] = struct{}{}
+			lastTsMill = v.TsMill
+		}
+		if len(received) == total {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != total {
+		t.Fatalf("tail received %d, want %d", len(received), total)
+	}
+}
+
+// failWriter write failed after n bytes
+type failWriter struct {
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n < len(p) {
+		return 0, errors.New("client gone")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestService_ExportLogging(t *testing.T) {
+	ctx := context.Background()
+	name := "export_testing"
+	if err := svc.CreateModule(ctx, &model.CreateModuleReq{Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.DelModule(ctx, &model.DelModuleReq{ObjectIDReq: model.ObjectIDReq{ID: m.ID.Hex()}, Name: name})
+
+	// shards of previous and current prefix overlap in time, logging interleaved
+	now := time.Now().Unix()
+	total := exportPageLimit + 10
+	for i, prefix := range []string{"prev", m.Prefix} {
+		cName := mongo.NewShardCollection(prefix, m.DaySpan).EncodeCollName(m.Bucket, now)
+		if err := svc.d.CreateLoggingIndex(m.Database, cName, false); err != nil {
+			t.Fatal(err)
+		}
+		defer svc.d.DropLoggingCollection(ctx, m.Name, m.Database, cName)
+		shard, err := model.NewShard(&model.Module{Name: name, Prefix: prefix, DaySpan: m.DaySpan}, m.Database, cName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.d.RegisterShard(ctx, shard); err != nil {
+			t.Fatal(err)
+		}
+		docs := make([]interface{}, 0, total/2)
+		for j := i; j < total; j += 2 {
+			ts := now - int64(total) + int64(j)
+			docs = append(docs, &model.Logging{ID: primitive.NewObjectID(), Module: name, Short: "export",
+				TimeSec: ts, TimeMill: ts * 1000, MessageID: fmt.Sprintf("export_%d", j)})
+		}
+		if err := svc.d.CreateManyLogging(ctx, m.Database, cName, docs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newExportReq := func(maxRows int64) *model.ExportLoggingReq {
+		in := &model.ExportLoggingReq{MaxRows: maxRows}
+		in.ModuleName, in.Level = name, -2
+		in.BeginTsSec, in.EndTsSec = now-int64(total)-60, now+60
+		return in
+	}
+	buf := &bytes.Buffer{}
+	audit := &model.ExportAudit{}
+	if err := svc.ExportLogging(ctx, newExportReq(0), buf, audit); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Rows != int64(total) || audit.Truncated {
+		t.Fatalf("export rows %d truncated %t", audit.Rows, audit.Truncated)
+	}
+	var lastTsMill int64 = math.MaxInt64
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		v := &model.FindLoggingList{}
+		if err := dec.Decode(v); err != nil {
+			t.Fatal(err)
+		}
+		if v.TsMill >= lastTsMill {
+			t.Fatalf("shards not merged by ts desc, %d after %d", v.TsMill, lastTsMill)
+		}
+		lastTsMill = v.TsMill
+	}
+
+	// rows limit reached
+	audit = &model.ExportAudit{}
+	if err := svc.ExportLogging(ctx, newExportReq(10), ioutil.Discard, audit); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Rows != 10 || !audit.Truncated {
+		t.Fatalf("limited export rows %d truncated %t", audit.Rows, audit.Truncated)
+	}
+
+	// write failed after file started
+	audit = &model.ExportAudit{}
+	if err := svc.ExportLogging(ctx, newExportReq(0), &failWriter{n: 1024}, audit); err == nil {
+		t.Fatal("export write error not returned")
+	}
+	if !audit.Truncated || audit.Error == "" || audit.Rows == 0 {
+		t.Fatalf("failed export audit %+v", audit)
+	}
+}
+
+// newEmbeddedService service on local storage, receiver databases replaced if set
+func newEmbeddedService(t *testing.T, dbs ...string) *Service {
+	cfg := *conf.Conf
+	cfg.Storage.Dir = t.TempDir()
+	if len(dbs) > 0 {
+		cfg.MongoGroup.ReceiverDatabase = dbs
+	}
+	cfg.SetEmbedded()
+	s := NewService(&cfg)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitMigrationJob wait job finished in background
+func waitMigrationJob(t *testing.T, s *Service, id string) *model.FindMigrationJobList {
+	out := &model.FindMigrationJobList{}
+	for i := 0; i < 100; i++ {
+		if err := s.GetMigrationJob(context.Background(), &model.GetMigrationJobReq{ObjectIDReq: model.ObjectIDReq{ID: id}}, out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Status != model.MigrationPending && out.Status != model.MigrationRunning {
+			return out
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("migration job %s not finished, %s", id, out.Status)
+	return nil
+}
+
+func TestService_MigrationJob(t *testing.T) {
+	ctx := context.Background()
+	s := newEmbeddedService(t, "qelog_receiver", "qelog_receiver_2")
+	name := "migration_testing"
+	if err := s.CreateModule(ctx, &model.CreateModuleReq{Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := s.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := "qelog_receiver"
+	if m.Database == from {
+		from = "qelog_receiver_2"
+	}
+
+	// history shards in previous database
+	now := time.Now()
+	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
+	rows := forEachPageLimit + 10
+	cNames := []string{sc.EncodeCollName(m.Bucket, now.AddDate(0, -2, 0).Unix()), sc.EncodeCollName(m.Bucket, now.AddDate(0, -1, 0).Unix())}
+	for _, cName := range cNames {
+		if err := s.d.CreateLoggingIndex(from, cName, false); err != nil {
+			t.Fatal(err)
+		}
+		docs := make([]interface{}, 0, rows)
+		for i := 0; i < rows; i++ {
+			docs = append(docs, &model.Logging{ID: primitive.NewObjectID(), Module: name, Short: "migration", TimeSec: now.Unix() - int64(i)})
+		}
+		if err := s.d.CreateManyLogging(ctx, from, cName, docs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.syncShardCatalog(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &model.CreateMigrationJobReq{ModuleName: name, FromDatabase: from, ToDatabase: m.Database,
+		Collections: cNames[:1], DropSource: true}
+	// job of module running
+	s.reserveMigration(name)
+	if err := s.CreateMigrationJob(ctx, in, &model.CreateMigrationJobResp{}); err == nil {
+		t.Fatal("concurrent migration job of module created")
+	}
+	s.releaseMigration(name)
+
+	out := &model.CreateMigrationJobResp{}
+	if err := s.CreateMigrationJob(ctx, in, out); err != nil {
+		t.Fatal(err)
+	}
+	job := waitMigrationJob(t, s, out.ID)
+	if job.Status != model.MigrationSucceeded || job.Shards[0].Status != model.MigrationShardDropped ||
+		job.Shards[0].Copied != int64(rows) || job.Shards[0].DstCount != int64(rows) {
+		t.Fatalf("migration job %+v", job)
+	}
+	// reads switched to target, source dropped
+	docs, err := s.d.FindShard(ctx, bson.M{"module_name": name, "collection": cNames[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Database != m.Database || docs[0].MigratedTo != "" {
+		t.Fatalf("shard not switched %v", docs)
+	}
+	names, err := s.d.ListCollectionNames(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range names {
+		if v == cNames[0] {
+			t.Fatal("source collection not dropped")
+		}
+	}
+
+	// job interrupted by restart, copying shard resumed
+	interrupted := &model.MigrationJob{ModuleName: name, FromDatabase: from, ToDatabase: m.Database,
+		Status: model.MigrationRunning, CreatedAt: time.Now(), StartedAt: time.Now(),
+		Shards: []model.MigrationShard{{Collection: cNames[1], Status: model.MigrationShardCopying, Copied: 10}}}
+	if err := s.d.CreateMigrationJob(ctx, interrupted); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.resumeMigrationJob(); err != nil {
+		t.Fatal(err)
+	}
+	job = waitMigrationJob(t, s, interrupted.ID.Hex())
+	if job.Status != model.MigrationSucceeded || job.Shards[0].Status != model.MigrationShardSwitched ||
+		job.Shards[0].Copied != int64(rows) {
+		t.Fatalf("resumed migration job %+v", job)
+	}
+	listIn := &model.FindLoggingListReq{ModuleName: name, Level: -2, ForceCollectionName: cNames[1]}
+	listIn.BeginTsSec, listIn.EndTsSec = now.Unix()-int64(rows), now.Unix()+1
+	listIn.Page, listIn.Limit = 1, 1
+	list := &model.FindLoggingListResp{}
+	if err := s.FindLoggingList(ctx, listIn, list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != int64(rows) {
+		t.Fatalf("migrated shard count %d, want %d", list.Count, rows)
+	}
+}
+
+func TestService_RestoreArchive(t *testing.T) {
+	ctx := context.Background()
+	s := newEmbeddedService(t)
+	s.cfg.Archive.Dir = t.TempDir()
+	name := "restore_testing"
+	if err := s.CreateModule(ctx, &model.CreateModuleReq{Name: name, Archive: model.ArchiveDir}); err != nil {
+		t.Fatal(err)
+	}
+	_, m, err := s.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
+	cName := sc.EncodeCollName(m.Bucket, time.Now().AddDate(0, -2, 0).Unix())
+	rows := archivePageLimit + 10
+	docs := make([]interface{}, 0, rows)
+	for i := 0; i < rows; i++ {
+		docs = append(docs, &model.Logging{ID: primitive.NewObjectID(), Module: name, Short: "restore", TimeSec: time.Now().Unix() - int64(i)})
+	}
+	if err := s.d.CreateManyLogging(ctx, m.Database, cName, docs); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.archiveCollection(ctx, m, m.Database, cName); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.d.DropLoggingCollection(ctx, name, m.Database, cName); err != nil {
+		t.Fatal(err)
+	}
+	_, archives, err := s.d.FindArchiveList(ctx, &model.FindArchiveListReq{ModuleName: name, PageReq: model.PageReq{Page: 1, Limit: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 {
+		t.Fatalf("archives %v", archives)
+	}
+	in := &model.RestoreArchiveReq{ObjectIDReq: model.ObjectIDReq{ID: archives[0].ID.Hex()}}
+
+	// restore of archive running
+	if ok, err := s.d.StartArchiveRestore(ctx, archives[0]); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	if err := s.RestoreArchive(ctx, in, &model.RestoreArchiveResp{}); err == nil {
+		t.Fatal("concurrent archive restore started")
+	}
+	if err := s.d.FailArchiveRestore(ctx, archives[0], "testing"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &model.RestoreArchiveResp{}
+	if err := s.RestoreArchive(ctx, in, out); err != nil {
+		t.Fatal(err)
+	}
+	var doc *model.Archive
+	for i := 0; i < 100; i++ {
+		if _, doc, err = s.d.GetArchive(ctx, bson.M{"_id": archives[0].ID}); err != nil {
+			t.Fatal(err)
+		}
+		if doc.RestoreStatus != model.ArchiveRestoreRunning {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if doc.RestoreStatus != model.ArchiveRestoreSucceeded || doc.RestoredRows != int64(rows) || doc.RestoredDB != out.Database {
+		t.Fatalf("archive restore %+v", doc)
+	}
+	restored := 0
+	err = s.forEachLogging(ctx, out.Database, out.Collection, func(docs []*model.Logging) error {
+		restored += len(docs)
+		return nil
+	})
+	if err != nil || restored != rows {
+		t.Fatal(restored, err)
+	}
+
+	// module of archive deleted
+	if err := s.d.DelModule(ctx, bson.M{"name": name}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreArchive(ctx, in, &model.RestoreArchiveResp{}); err == nil {
+		t.Fatal("archive of deleted module restored")
+	}
+}
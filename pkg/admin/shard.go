@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// registered shard collection not created yet, not pruned in this time
+const shardCatalogPruneAfter = time.Hour
+
+// FindShardList shards of module in shard catalog, migrated copy included
+func (svc *Service) FindShardList(ctx context.Context, in *model.FindShardListReq, out *model.ListResp) error {
+	filter := bson.M{"module_name": in.ModuleName}
+	if in.BeginTsSec > 0 {
+		filter["end_ts"] = bson.M{"$gt": in.BeginTsSec}
+	}
+	if in.EndTsSec > 0 {
+		filter["begin_ts"] = bson.M{"$lt": in.EndTsSec}
+	}
+	docs, err := svc.d.FindShard(ctx, filter)
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	list := make([]*model.FindShardList, 0, len(docs))
+	for _, v := range docs {
+		d := &model.FindShardList{
+			Database:     v.Database,
+			Collection:   v.Collection,
+			BeginTsSec:   v.BeginTsSec,
+			EndTsSec:     v.EndTsSec,
+			MigratedTo:   v.MigratedTo,
+			Count:        v.Count,
+			Size:         v.Size,
+			CreatedTsSec: v.CreatedAt.Unix(),
+		}
+		if !v.StatsUpdatedAt.IsZero() {
+			d.StatsUpdatedTs = v.StatsUpdatedAt.Unix()
+		}
+		list = append(list, d)
+	}
+	out.Count = int64(len(list))
+	out.List = list
+	return nil
+}
+
+func (svc *Service) bgSyncShardCatalog() {
+	for {
+		if err := svc.syncShardCatalog(context.Background()); err != nil {
+			logs.Qezap.Error("bgSyncShardCatalog", zap.String("syncShardCatalog", err.Error()))
+		}
+		time.Sleep(time.Duration(rand.Intn(30)+30) * time.Minute)
+	}
+}
+
+// syncShardCatalog collection created before shard catalog registered by naming rule of module,
+// collection dropped out of admin removed from catalog.
+// registered shard existence checked by its own collection name, shards of previous prefix or bucket kept
+func (svc *Service) syncShardCatalog(ctx context.Context) error {
+	modules, err := svc.d.FindAllModule(ctx)
+	if err != nil {
+		return err
+	}
+	// all collections of logging database, listed once for every module
+	listed := make(map[string]map[string]struct{})
+	for _, dbName := range svc.cfg.LoggingDatabases() {
+		names, err := svc.d.ListCollectionNames(ctx, dbName)
+		if err != nil {
+			return err
+		}
+		set := make(map[string]struct{}, len(names))
+		for _, name := range names {
+			set[name] = struct{}{}
+		}
+		listed[dbName] = set
+	}
+	for _, m := range modules {
+		shards, err := svc.d.FindShard(ctx, bson.M{"module_name": m.Name})
+		if err != nil {
+			return err
+		}
+		registered := make(map[string]*model.Shard, len(shards))
+		for _, v := range shards {
+			registered[v.Database+"."+v.Collection] = v
+		}
+		sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
+		for dbName, set := range listed {
+			for name := range set {
+				// bucket prefix of other module
+				if !strings.HasPrefix(name, m.LoggingPrefix()+sc.Sep) {
+					continue
+				}
+				if _, ok := registered[dbName+"."+name]; ok {
+					continue
+				}
+				shard, err := model.NewShard(m, dbName, name)
+				if err != nil {
+					continue
+				}
+				if err := svc.d.RegisterShard(ctx, shard); err != nil {
+					return err
+				}
+			}
+		}
+		for _, v := range registered {
+			set, ok := listed[v.Database]
+			if !ok || time.Since(v.CreatedAt) < shardCatalogPruneAfter {
+				continue
+			}
+			if _, ok := set[v.Collection]; ok {
+				continue
+			}
+			if err := svc.d.DeleteShard(ctx, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/qelog/pkg/dao"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	tailPollInterval = time.Second
+	// receiver written by batch, logging ts behind written time. look back window every poll, dedup by id
+	tailLookback  = 10 * time.Second
+	tailPageLimit = 500
+	// logging more than it each poll continued by next poll immediately
+	tailMaxPerPoll = 5000
+)
+
+// TailLogging poll newest logging match condition, send new logging order by ts asc, until ctx done or send error.
+// list empty when no new logging, can be used to keep alive.
+// poll db instead of change stream, standalone mongo not support change stream, and all receivers in cluster written same db
+func (svc *Service) TailLogging(ctx context.Context, in *model.TailLoggingReq, sinceTsSec int64,
+	send func(list []*model.FindLoggingList, lastTsSec int64) error) error {
+	exists, m, err := svc.d.GetModule(ctx, bson.M{"name": in.ModuleName})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if !exists {
+		return errc.ErrNotFound.MultiMsg("module")
+	}
+	filter, err := dao.FindLoggingListFilter(&model.FindLoggingListReq{
+		ModuleName:     in.ModuleName,
+		Short:          in.Short,
+		Level:          in.Level,
+		IP:             in.IP,
+		ConditionOne:   in.ConditionOne,
+		ConditionTwo:   in.ConditionTwo,
+		ConditionThree: in.ConditionThree,
+	})
+	if err != nil {
+		return err
+	}
+	if in.TraceID != "" {
+		filter["ti"] = in.TraceID
+	}
+
+	if sinceTsSec <= 0 {
+		sinceTsSec = time.Now().Unix()
+	}
+	seen := map[primitive.ObjectID]int64{}
+	// poll truncated by tailMaxPerPoll, next poll continue after last returned logging, not look back again
+	var after *model.LoggingCursor
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	for {
+		fromTsSec := sinceTsSec - int64(tailLookback/time.Second)
+		docs, next, err := svc.tailLoggingOnce(ctx, m, filter, fromTsSec, after)
+		if err != nil {
+			return err
+		}
+		after = next
+		fresh := make([]*model.Logging, 0, len(docs))
+		for _, v := range docs {
+			if _, ok := seen[v.ID]; ok {
+				continue
+			}
+			seen[v.ID] = v.TimeSec
+			fresh = append(fresh, v)
+			if v.TimeSec > sinceTsSec {
+				sinceTsSec = v.TimeSec
+			}
+		}
+		for id, ts := range seen {
+			if ts < fromTsSec {
+				delete(seen, id)
+			}
+		}
+		if err := send(toLoggingList(fresh), sinceTsSec); err != nil {
+			return err
+		}
+
+		// catching up, poll immediately
+		if after != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tailLoggingOnce logging ts >= fromTsSec or after cursor in module current database, order by ts asc.
+// shard more than tailMaxPerPoll, logging before shard last returned kept, cursor of it returned for next poll
+func (svc *Service) tailLoggingOnce(ctx context.Context, m *model.Module, filter bson.M, fromTsSec int64,
+	after *model.LoggingCursor) ([]*model.Logging, *model.LoggingCursor, error) {
+	if after != nil {
+		fromTsSec = after.TsSec
+	}
+	now := time.Now().Unix()
+	shards, err := svc.loggingShards(ctx, m, fromTsSec, now+1, m.Database, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	filter["ts"] = bson.M{"$gte": fromTsSec}
+	docs := make([]*model.Logging, 0)
+	var next *model.Logging
+	for _, shard := range shards {
+		// _id zero, all logging ts >= fromTsSec
+		cursor := &model.LoggingCursor{TsSec: fromTsSec, Prev: true}
+		if after != nil {
+			cursor = after
+		}
+		for n := 0; ; n += tailPageLimit {
+			if n >= tailMaxPerPoll {
+				if last := docs[len(docs)-1]; next == nil || model.LoggingLess(next, last) {
+					next = last
+				}
+				break
+			}
+			list, err := svc.d.FindLoggingAfter(ctx, shard.Database, shard.Collection, filter, cursor, tailPageLimit)
+			if err != nil {
+				return nil, nil, err
+			}
+			docs = append(docs, list...)
+			if len(list) < tailPageLimit {
+				break
+			}
+			cursor = model.NewLoggingCursor(list[len(list)-1], true)
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return model.LoggingLess(docs[j], docs[i])
+	})
+	if next == nil {
+		return docs, nil, nil
+	}
+	// logging of other shards after truncated position returned by next poll
+	for i, v := range docs {
+		if model.LoggingLess(v, next) {
+			docs = docs[:i]
+			break
+		}
+	}
+	return docs, model.NewLoggingCursor(next, true), nil
+}
@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/bbdshow/qelog/pkg/conf"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	TypeDir = model.ArchiveDir
+	TypeS3  = model.ArchiveS3
+)
+
+// maxLineSize archived logging line limit
+const maxLineSize = 64 << 20
+
+var ErrNotFound = errors.New("archive object not found")
+
+// Storage archived object storage, key '/' separated
+type Storage interface {
+	// Put object replaced if exists, size of r required by object storage
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// New storage by archive policy type
+func New(c conf.Archive, typ string) (Storage, error) {
+	switch typ {
+	case TypeDir:
+		return NewDirStorage(c.Dir)
+	case TypeS3:
+		if !c.S3.Enabled() {
+			return nil, fmt.Errorf("archive s3 not configured")
+		}
+		return NewS3Storage(c.S3)
+	}
+	return nil, fmt.Errorf("unsupported archive type '%s'", typ)
+}
+
+// Key archived data object key of shard collection
+func Key(moduleName, dbName, cName string) string {
+	return fmt.Sprintf("%s/%s/%s.%s", moduleName, dbName, cName, model.ArchiveFormat)
+}
+
+// Writer logging per line as mongo extended json, gzip compressed.
+// rows, time range and checksum of compressed bytes accumulated to manifest
+type Writer struct {
+	gz    *gzip.Writer
+	hash  hash.Hash
+	count *countWriter
+	m     *model.Archive
+}
+
+func NewWriter(w io.Writer, m *model.Archive) *Writer {
+	h := sha256.New()
+	cw := &countWriter{w: io.MultiWriter(w, h)}
+	m.Format = model.ArchiveFormat
+	m.Rows, m.Bytes, m.MinTsSec, m.MaxTsSec = 0, 0, 0, 0
+	return &Writer{gz: gzip.NewWriter(cw), hash: h, count: cw, m: m}
+}
+
+func (w *Writer) Write(l *model.Logging) error {
+	b, err := bson.MarshalExtJSON(l, false, false)
+	if err != nil {
+		return err
+	}
+	if _, err := w.gz.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if w.m.Rows == 0 || l.TimeSec < w.m.MinTsSec {
+		w.m.MinTsSec = l.TimeSec
+	}
+	if w.m.Rows == 0 || l.TimeSec > w.m.MaxTsSec {
+		w.m.MaxTsSec = l.TimeSec
+	}
+	w.m.Rows++
+	return nil
+}
+
+// Close flush compressed data, manifest bytes and checksum completed
+func (w *Writer) Close() error {
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	w.m.Bytes = w.count.n
+	w.m.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	return nil
+}
+
+// Reader read archived logging written by Writer.
+// checksum verified when all read, Next returns io.EOF only if rows and checksum matched manifest
+type Reader struct {
+	gz   *gzip.Reader
+	br   *bufio.Reader
+	hash hash.Hash
+	m    *model.Archive
+	rows int64
+}
+
+func NewReader(r io.Reader, m *model.Archive) (*Reader, error) {
+	h := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(r, h))
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{gz: gz, br: bufio.NewReader(gz), hash: h, m: m}, nil
+}
+
+func (r *Reader) Next() (*model.Logging, error) {
+	line, err := r.readLine()
+	if err == io.EOF {
+		return nil, r.verify()
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := &model.Logging{}
+	if err := bson.UnmarshalExtJSON(line, false, l); err != nil {
+		return nil, fmt.Errorf("archive line %d %v", r.rows+1, err)
+	}
+	r.rows++
+	return l, nil
+}
+
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		b, isPrefix, err := r.br.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b...)
+		if len(line) > maxLineSize {
+			return nil, fmt.Errorf("archive line %d too large", r.rows+1)
+		}
+		if !isPrefix {
+			if len(line) == 0 {
+				continue
+			}
+			return line, nil
+		}
+	}
+}
+
+func (r *Reader) verify() error {
+	// gzip trailer read, rest of underlying reader hashed
+	if _, err := io.Copy(io.Discard, r.gz); err != nil {
+		return err
+	}
+	if r.rows != r.m.Rows {
+		return fmt.Errorf("archive rows %d, manifest %d", r.rows, r.m.Rows)
+	}
+	if sum := hex.EncodeToString(r.hash.Sum(nil)); r.m.SHA256 != "" && sum != r.m.SHA256 {
+		return fmt.Errorf("archive checksum %s, manifest %s", sum, r.m.SHA256)
+	}
+	return io.EOF
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -1,7 +1,10 @@
 package conf
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 
 	"github.com/bbdshow/bkit/conf"
@@ -23,8 +26,11 @@ type Config struct {
 	Mongo      mongo.Config
 	Logging    *logs.Config
 
-	Admin    Admin
-	Receiver Receiver
+	Storage    Storage
+	ClickHouse ClickHouse
+	Archive    Archive
+	Admin      Admin
+	Receiver   Receiver
 }
 
 func InitConf(path ...string) error {
@@ -44,6 +50,21 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("mongo conns database must be different")
 	}
 
+	switch c.MongoGroup.Placement {
+	case "", "random", "storage", "ingest", "weight":
+	default:
+		return fmt.Errorf("mongo group placement %s invalid", c.MongoGroup.Placement)
+	}
+	for db, w := range c.MongoGroup.Weights {
+		if !c.MongoGroup.IsReceiverDatabase(db) || w < 0 {
+			return fmt.Errorf("mongo group weight %s=%d invalid", db, w)
+		}
+	}
+
+	if c.ClickHouse.Enabled() && c.MongoGroup.IsExists(c.ClickHouse.Database) {
+		return fmt.Errorf("clickhouse database must be different from mongo group database")
+	}
+
 	for db := range mongoConn {
 		if !c.MongoGroup.IsExists(db) {
 			return fmt.Errorf("mongo conns databse must be in the mongo group database")
@@ -53,6 +74,20 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// IsLoggingDatabase mongo receiver database or clickhouse database
+func (c *Config) IsLoggingDatabase(database string) bool {
+	return c.MongoGroup.IsReceiverDatabase(database) || c.ClickHouse.IsDatabase(database)
+}
+
+// LoggingDatabases all databases logging may be stored
+func (c *Config) LoggingDatabases() []string {
+	names := append([]string{}, c.MongoGroup.ReceiverDatabase...)
+	if c.ClickHouse.Enabled() {
+		names = append(names, c.ClickHouse.Database)
+	}
+	return names
+}
+
 func (c *Config) Release() bool {
 	return c.Env == "release"
 }
@@ -72,6 +107,10 @@ func (c *Config) EraseSensitive() Config {
 type MongoGroup struct {
 	AdminDatabase    string
 	ReceiverDatabase []string
+	// new module receiver database placement, oneof random | storage | ingest | weight
+	// storage least storage used, ingest lowest recent ingest, weight random by Weights
+	Placement string         `defval:"random"`
+	Weights   map[string]int // receiver database weight, not set weight 1, 0 not placed
 }
 
 func (mg MongoGroup) IsExists(database string) bool {
@@ -95,12 +134,44 @@ func (mg MongoGroup) IsReceiverDatabase(database string) bool {
 	return false
 }
 
+// RandReceiverDatabase random receiver database, weight 0 not placed, all weight 0 empty
 func (mg MongoGroup) RandReceiverDatabase() string {
-	if len(mg.ReceiverDatabase) == 0 {
+	dbs := make([]string, 0, len(mg.ReceiverDatabase))
+	for _, v := range mg.ReceiverDatabase {
+		if mg.Weight(v) > 0 {
+			dbs = append(dbs, v)
+		}
+	}
+	if len(dbs) == 0 {
+		return ""
+	}
+	return dbs[rand.Intn(len(dbs))]
+}
+
+// WeightReceiverDatabase random receiver database by weight, all weight 0 empty
+func (mg MongoGroup) WeightReceiverDatabase() string {
+	total := 0
+	for _, v := range mg.ReceiverDatabase {
+		total += mg.Weight(v)
+	}
+	if total <= 0 {
 		return ""
 	}
-	i := rand.Intn(len(mg.ReceiverDatabase))
-	return mg.ReceiverDatabase[i]
+	n := rand.Intn(total)
+	for _, v := range mg.ReceiverDatabase {
+		if n < mg.Weight(v) {
+			return v
+		}
+		n -= mg.Weight(v)
+	}
+	return ""
+}
+
+func (mg MongoGroup) Weight(database string) int {
+	if w, ok := mg.Weights[database]; ok {
+		return w
+	}
+	return 1
 }
 
 func (mg MongoGroup) Databases() []string {
@@ -110,11 +181,112 @@ func (mg MongoGroup) Databases() []string {
 	return names
 }
 
+// Storage logging storage backend, admin data in mongo unless embedded
+type Storage struct {
+	Type     string `defval:"mongo"`  // oneof mongo | local
+	Dir      string `defval:"./data"` // local storage data directory
+	Embedded bool   // admin data and logging all in local storage Dir, without mongo
+}
+
+// SetEmbedded all data in local storage, mongo conns ignored
+func (c *Config) SetEmbedded() {
+	c.Storage.Embedded = true
+	c.Storage.Type = "local"
+	c.Mongo.Conns = nil
+	if c.MongoGroup.AdminDatabase == "" {
+		c.MongoGroup.AdminDatabase = "qelog_admin"
+	}
+	if len(c.MongoGroup.ReceiverDatabase) == 0 {
+		c.MongoGroup.ReceiverDatabase = []string{"qelog_receiver"}
+	}
+}
+
+// ClickHouse logging storage of module storage type clickhouse, Addr empty disabled
+type ClickHouse struct {
+	Addr          string // http interface, e.g. http://127.0.0.1:8123
+	Database      string `defval:"qelog_logging"`
+	Username      string
+	Password      string
+	BatchSize     int `defval:"10000"` // receiver written rows merged in one insert
+	FlushInterval int `defval:"1000"`  // /ms batch max waiting time
+}
+
+func (ch ClickHouse) Enabled() bool {
+	return ch.Addr != ""
+}
+
+// IsDatabase module logging database is clickhouse database
+func (ch ClickHouse) IsDatabase(database string) bool {
+	return ch.Enabled() && ch.Database == database
+}
+
+// Archive expired logging shard archived before dropped, by module archive policy
+type Archive struct {
+	Dir string `defval:"./archive"` // archive policy dir target directory, and temp file before uploaded
+	S3  S3
+}
+
+// S3 compatible object storage of archive policy s3, Endpoint empty disabled
+type S3 struct {
+	Endpoint  string // e.g. http://127.0.0.1:9000, path style request
+	Region    string `defval:"us-east-1"`
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+func (s S3) Enabled() bool {
+	return s.Endpoint != "" && s.Bucket != ""
+}
+
 type Receiver struct {
-	HttpListenAddr string `defval:"0.0.0.0:31081"` // if empty, disable http server
-	RpcListenAddr  string `defval:":31082"`
-	AlarmEnable    bool   `defval:"true"`
-	MetricsEnable  bool   `defval:"true"`
+	HttpListenAddr  string `defval:"0.0.0.0:31081"` // if empty, disable http server
+	RpcListenAddr   string `defval:":31082"`
+	AlarmEnable     bool   `defval:"true"`
+	MetricsEnable   bool   `defval:"true"`
+	TLSCertFile     string // if cert and key not empty, enable TLS
+	TLSKeyFile      string
+	TLSClientCAFile string // if not empty, require and verify client certificate (mutual TLS)
+	Queue           ReceiverQueue
+}
+
+// ReceiverQueue buffering between receiver and db, packet acked when it durable in queue
+type ReceiverQueue struct {
+	Type         string // empty disabled, oneof disk | kafka
+	Dir          string `defval:"./queue"`    // disk queue data directory
+	MaxBytes     int64  `defval:"1073741824"` // disk queue not written db data size limit, full publish failed
+	Writers      int    `defval:"4"`          // goroutines drain queue written to db
+	KafkaBrokers []string
+	KafkaTopic   string `defval:"qelog_logging"`
+	KafkaGroupID string `defval:"qelog_receiver"`
+}
+
+// TLSConfig returns nil if TLS disabled
+func (r Receiver) TLSConfig() (*tls.Config, error) {
+	if r.TLSCertFile == "" || r.TLSKeyFile == "" {
+		if r.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("receiver client ca required tls cert and key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.TLSCertFile, r.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("receiver tls certificate %v", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if r.TLSClientCAFile != "" {
+		ca, err := ioutil.ReadFile(r.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("receiver tls client ca %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("receiver tls client ca invalid pem")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
 }
 
 type Admin struct {
@@ -122,4 +294,5 @@ type Admin struct {
 	AuthEnable     bool   `defval:"true"`
 	Username       string `defval:"admin"` // manager: username/passwd
 	Password       string `defval:"111111"`
+	ExternalURL    string // admin address reachable by alarm receiver, logging link of alarm message, e.g. https://qelog.example.com
 }
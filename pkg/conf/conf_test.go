@@ -15,3 +15,50 @@ func TestConfigToFile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestReceiver_TLSConfig(t *testing.T) {
+	testCases := []struct {
+		Receiver Receiver
+		Enable   bool
+		Err      bool
+	}{
+		{Receiver: Receiver{}, Enable: false, Err: false},
+		{Receiver: Receiver{TLSClientCAFile: "./ca.crt"}, Enable: false, Err: true},
+		{Receiver: Receiver{TLSCertFile: "./not_exists.crt", TLSKeyFile: "./not_exists.key"}, Enable: false, Err: true},
+	}
+	for i, v := range testCases {
+		cfg, err := v.Receiver.TLSConfig()
+		if (err != nil) != v.Err {
+			t.Fatalf("case %d: err %v", i, err)
+		}
+		if (cfg != nil) != v.Enable {
+			t.Fatalf("case %d: tls config %v", i, cfg)
+		}
+	}
+}
+
+func TestMongoGroup_WeightReceiverDatabase(t *testing.T) {
+	mg := MongoGroup{
+		ReceiverDatabase: []string{"r1", "r2", "r3"},
+		Weights:          map[string]int{"r1": 0, "r3": 3},
+	}
+	hits := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		hits[mg.WeightReceiverDatabase()]++
+	}
+	if hits["r1"] != 0 || hits["r2"] == 0 || hits["r3"] < 2*hits["r2"] {
+		t.Fatalf("weight placement %v", hits)
+	}
+	for i := 0; i < 100; i++ {
+		if v := mg.RandReceiverDatabase(); v == "r1" {
+			t.Fatalf("random placement weight 0 placed %s", v)
+		}
+	}
+	mg.Weights = map[string]int{"r1": 0, "r2": 0, "r3": 0}
+	if v := mg.WeightReceiverDatabase(); v != "" {
+		t.Fatalf("all weight 0 placed %s", v)
+	}
+	if v := mg.RandReceiverDatabase(); v != "" {
+		t.Fatalf("random placement all weight 0 placed %s", v)
+	}
+}
@@ -21,7 +21,7 @@ func (d *Dao) FindAlarmRule(ctx context.Context, module string, enable bool) ([]
 		"enable":      enable,
 	}
 	docs := make([]*model.AlarmRule, 0)
-	err := d.adminInst.Find(ctx, model.CNAlarmRule, filter, &docs)
+	err := d.admin.Find(ctx, model.CNAlarmRule, filter, &docs)
 	return docs, errc.WithStack(err)
 }
 
@@ -47,20 +47,20 @@ func (d *Dao) FindAlarmRuleList(ctx context.Context, in *model.FindAlarmRuleList
 
 	opt := in.SetPage(options.Find()).SetSort(bson.M{"_id": -1})
 	docs := make([]*model.AlarmRule, 0, in.Limit)
-	c, err := d.adminInst.FindCount(ctx, model.CNAlarmRule, filter, &docs, opt, nil)
+	c, err := d.admin.FindCount(ctx, model.CNAlarmRule, filter, &docs, opt)
 	return c, docs, errc.WithStack(err)
 }
 
 // CreateAlarmRule common db CRUD op
 func (d *Dao) CreateAlarmRule(ctx context.Context, in *model.AlarmRule) error {
-	_, err := d.adminInst.Collection(model.CNAlarmRule).InsertOne(ctx, in)
+	err := d.admin.InsertOne(ctx, model.CNAlarmRule, in)
 	return errc.WithStack(err)
 }
 
 // GetAlarmRule common db CRUD op
 func (d *Dao) GetAlarmRule(ctx context.Context, filter bson.M) (bool, *model.AlarmRule, error) {
 	doc := &model.AlarmRule{}
-	exists, err := d.adminInst.FindOne(ctx, model.CNAlarmRule, filter, doc)
+	exists, err := d.admin.FindOne(ctx, model.CNAlarmRule, filter, doc)
 	return exists, doc, errc.WithStack(err)
 }
 
@@ -83,9 +83,30 @@ func (d *Dao) UpdateAlarmRule(ctx context.Context, in *model.UpdateAlarmRuleReq)
 	if in.Enable != doc.Enable {
 		fields["enable"] = in.Enable
 	}
+	if in.Type != doc.Type {
+		fields["type"] = in.Type
+	}
 	if in.Short != doc.Short {
 		fields["short"] = in.Short
 	}
+	if in.Field != doc.Field {
+		fields["field"] = in.Field
+	}
+	if in.Pattern != doc.Pattern {
+		fields["pattern"] = in.Pattern
+	}
+	if in.Condition != doc.Condition {
+		fields["condition"] = in.Condition
+	}
+	if in.WindowSec != doc.WindowSec {
+		fields["window_sec"] = in.WindowSec
+	}
+	if in.Threshold != doc.Threshold {
+		fields["threshold"] = in.Threshold
+	}
+	if in.Template != doc.Template {
+		fields["template"] = in.Template
+	}
 	if in.RateSec != doc.RateSec {
 		fields["rate_sec"] = in.RateSec
 	}
@@ -114,19 +135,140 @@ func (d *Dao) UpdateAlarmRule(ctx context.Context, in *model.UpdateAlarmRuleReq)
 		"_id":        id,
 		"updated_at": doc.UpdatedAt,
 	}
-	uRet, err := d.adminInst.Collection(model.CNAlarmRule).UpdateOne(ctx, filter, update)
+	matched, err := d.admin.UpdateOne(ctx, model.CNAlarmRule, filter, update, false)
 	if err != nil {
 		return errc.WithStack(err)
 	}
-	if uRet.MatchedCount <= 0 {
+	if matched <= 0 {
 		return mongo.ErrNotMatched
 	}
 	return nil
 }
 
+// UpdateAlarmRuleType rule created before rule type set exact, unique index of rule type same as before
+func (d *Dao) UpdateAlarmRuleType(ctx context.Context) error {
+	update := bson.M{
+		"$set": bson.M{
+			"type":    model.RuleExact,
+			"field":   "",
+			"pattern": "",
+		},
+	}
+	err := d.admin.UpdateMany(ctx, model.CNAlarmRule, bson.M{"type": nil}, update)
+	return errc.WithStack(err)
+}
+
+// IncrAlarmCounter window counter of rule in bucket, every receiver incremented same document
+func (d *Dao) IncrAlarmCounter(ctx context.Context, ruleID string, bucketTs, hits, total int64) error {
+	filter := bson.M{
+		"rule_id":   ruleID,
+		"bucket_ts": bucketTs,
+	}
+	update := bson.M{
+		"$inc": bson.M{
+			"hits":  hits,
+			"total": total,
+		},
+		"$setOnInsert": bson.M{
+			"created_at": time.Now().Local(),
+		},
+	}
+	if _, err := d.admin.UpdateOne(ctx, model.CNAlarmCounter, filter, update, true); err != nil {
+		return errc.WithStack(err)
+	}
+	return nil
+}
+
+// SumAlarmCounter sum window counter of rule buckets in [beginTs, endTs), sum by find, storage not need aggregate
+func (d *Dao) SumAlarmCounter(ctx context.Context, ruleID string, beginTs, endTs int64) (int64, int64, error) {
+	filter := bson.M{
+		"rule_id":   ruleID,
+		"bucket_ts": bson.M{"$gte": beginTs, "$lt": endTs},
+	}
+	docs := make([]*model.AlarmCounter, 0)
+	if err := d.admin.Find(ctx, model.CNAlarmCounter, filter, &docs); err != nil {
+		return 0, 0, errc.WithStack(err)
+	}
+	hits, total := int64(0), int64(0)
+	for _, v := range docs {
+		hits += v.Hits
+		total += v.Total
+	}
+	return hits, total, nil
+}
+
+// IncrAlarmLease hits of receiver merged to lease of rule, lease created if not exists
+func (d *Dao) IncrAlarmLease(ctx context.Context, ruleID string, hits int64) error {
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"owner":      "",
+			"node":       "",
+			"expire_ts":  int64(0),
+			"sent_hits":  int64(0),
+			"updated_at": time.Now().Local(),
+		},
+	}
+	if hits > 0 {
+		update["$inc"] = bson.M{"hits": hits}
+	} else {
+		update["$setOnInsert"].(bson.M)["hits"] = int64(0)
+	}
+	if _, err := d.admin.UpdateOne(ctx, model.CNAlarmLease, bson.M{"rule_id": ruleID}, update, true); err != nil {
+		return errc.WithStack(err)
+	}
+	return nil
+}
+
+// AcquireAlarmLease hits merged, lease expired or held by owner acquired for lease sec.
+// acquired returns merged hits of all receivers not sent, marked sent
+func (d *Dao) AcquireAlarmLease(ctx context.Context, ruleID, owner, node string, hits, leaseSec int64) (bool, int64, error) {
+	if err := d.IncrAlarmLease(ctx, ruleID, hits); err != nil {
+		return false, 0, err
+	}
+	now := time.Now()
+	filter := bson.M{
+		"rule_id": ruleID,
+		"$or": bson.A{
+			bson.M{"expire_ts": bson.M{"$lte": now.Unix()}},
+			bson.M{"owner": owner},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"owner":      owner,
+			"node":       node,
+			"expire_ts":  now.Unix() + leaseSec,
+			"updated_at": now.Local(),
+		},
+	}
+	matched, err := d.admin.UpdateOne(ctx, model.CNAlarmLease, filter, update, false)
+	if err != nil {
+		return false, 0, errc.WithStack(err)
+	}
+	if matched <= 0 {
+		return false, 0, nil
+	}
+	doc := &model.AlarmLease{}
+	exists, err := d.admin.FindOne(ctx, model.CNAlarmLease, bson.M{"rule_id": ruleID}, doc)
+	if err != nil {
+		return false, 0, errc.WithStack(err)
+	}
+	// acquired by other after expired
+	if !exists || doc.Owner != owner {
+		return false, 0, nil
+	}
+	// hits merged after read, sent next time
+	_, err = d.admin.UpdateOne(ctx, model.CNAlarmLease, bson.M{"rule_id": ruleID, "owner": owner},
+		bson.M{"$set": bson.M{"sent_hits": doc.Hits}}, false)
+	if err != nil {
+		return false, 0, errc.WithStack(err)
+	}
+	return true, doc.Hits - doc.SentHits, nil
+}
+
 // DelAlarmRule common db CRUD op
 func (d *Dao) DelAlarmRule(ctx context.Context, filter bson.M) error {
-	_, err := d.adminInst.Collection(model.CNAlarmRule).DeleteOne(ctx, filter)
+	err := d.admin.DeleteOne(ctx, model.CNAlarmRule, filter)
 	return errc.WithStack(err)
 }
 
@@ -152,27 +294,27 @@ func (d *Dao) FindHookURLList(ctx context.Context, in *model.FindHookURLListReq)
 
 	opt := in.SetPage(options.Find()).SetSort(bson.M{"_id": -1})
 	docs := make([]*model.HookURL, 0, in.Limit)
-	c, err := d.adminInst.FindCount(ctx, model.CNHookURL, filter, &docs, opt, nil)
+	c, err := d.admin.FindCount(ctx, model.CNHookURL, filter, &docs, opt)
 	return c, docs, errc.WithStack(err)
 }
 
 // FindAllHookURL common db CRUD op
 func (d *Dao) FindAllHookURL(ctx context.Context) ([]*model.HookURL, error) {
 	docs := make([]*model.HookURL, 0)
-	err := d.adminInst.Find(ctx, model.CNHookURL, bson.M{}, &docs)
+	err := d.admin.Find(ctx, model.CNHookURL, bson.M{}, &docs)
 	return docs, errc.WithStack(err)
 }
 
 // GetHookURL common db CRUD op
 func (d *Dao) GetHookURL(ctx context.Context, filter bson.M) (bool, *model.HookURL, error) {
 	doc := &model.HookURL{}
-	exists, err := d.adminInst.FindOne(ctx, model.CNHookURL, filter, doc)
+	exists, err := d.admin.FindOne(ctx, model.CNHookURL, filter, doc)
 	return exists, doc, errc.WithStack(err)
 }
 
 // CreateHookURL common db CRUD op
 func (d *Dao) CreateHookURL(ctx context.Context, in *model.HookURL) error {
-	_, err := d.adminInst.Collection(model.CNHookURL).InsertOne(ctx, in)
+	err := d.admin.InsertOne(ctx, model.CNHookURL, in)
 	return errc.WithStack(err)
 }
 
@@ -210,6 +352,12 @@ func (d *Dao) UpdateHookURL(ctx context.Context, in *model.UpdateHookURLReq) err
 	if strings.Join(in.HideText, ",") != strings.Join(doc.HideText, ",") {
 		fields["hide_text"] = in.HideText
 	}
+	if in.BodyTemplate != doc.BodyTemplate {
+		fields["body_template"] = in.BodyTemplate
+	}
+	if in.Template != doc.Template {
+		fields["template"] = in.Template
+	}
 
 	if len(fields) > 0 {
 		fields["updated_at"] = time.Now().Local()
@@ -224,17 +372,17 @@ func (d *Dao) UpdateHookURL(ctx context.Context, in *model.UpdateHookURLReq) err
 		"updated_at": doc.UpdatedAt,
 	}
 	// 更新已经引用的
-	_, err = d.adminInst.Collection(model.CNAlarmRule).UpdateMany(ctx, bson.M{"hook_id": id.Hex()},
+	err = d.admin.UpdateMany(ctx, model.CNAlarmRule, bson.M{"hook_id": id.Hex()},
 		bson.M{"$set": bson.M{"updated_at": time.Now()}})
 	if err != nil {
 		return errc.WithStack(err)
 	}
 
-	uRet, err := d.adminInst.Collection(model.CNHookURL).UpdateOne(ctx, filter, update)
+	matched, err := d.admin.UpdateOne(ctx, model.CNHookURL, filter, update, false)
 	if err != nil {
 		return errc.WithStack(err)
 	}
-	if uRet.MatchedCount <= 0 {
+	if matched <= 0 {
 		return mongo.ErrNotMatched
 	}
 	return nil
@@ -247,13 +395,13 @@ func (d *Dao) DelHookURL(ctx context.Context, in *model.DelHookURLReq) error {
 		return err
 	}
 	// 是否存在绑定
-	c, err := d.adminInst.Collection(model.CNAlarmRule).CountDocuments(ctx, bson.M{"hook_id": in.ID}, options.Count().SetLimit(1))
+	c, err := d.admin.CountDocuments(ctx, model.CNAlarmRule, bson.M{"hook_id": in.ID})
 	if err != nil {
 		return errc.WithStack(err)
 	}
 	if c != 0 {
 		return fmt.Errorf("hook url be referenced")
 	}
-	_, err = d.adminInst.Collection(model.CNHookURL).DeleteOne(ctx, bson.M{"_id": id})
+	err = d.admin.DeleteOne(ctx, model.CNHookURL, bson.M{"_id": id})
 	return errc.WithStack(err)
 }
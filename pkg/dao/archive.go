@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpsertArchive shard archived again replace previous manifest
+func (d *Dao) UpsertArchive(ctx context.Context, in *model.Archive) error {
+	filter := bson.M{
+		"module_name": in.ModuleName,
+		"database":    in.Database,
+		"collection":  in.Collection,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"target":     in.Target,
+			"key":        in.Key,
+			"format":     in.Format,
+			"rows":       in.Rows,
+			"bytes":      in.Bytes,
+			"sha256":     in.SHA256,
+			"min_ts":     in.MinTsSec,
+			"max_ts":     in.MaxTsSec,
+			"created_at": in.CreatedAt,
+		},
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNArchive, filter, update, true)
+	return errc.WithStack(err)
+}
+
+// GetArchive common db CRUD operation
+func (d *Dao) GetArchive(ctx context.Context, filter bson.M) (bool, *model.Archive, error) {
+	doc := &model.Archive{}
+	exists, err := d.admin.FindOne(ctx, model.CNArchive, filter, doc)
+	return exists, doc, errc.WithStack(err)
+}
+
+// FindArchiveList newer shard first
+func (d *Dao) FindArchiveList(ctx context.Context, in *model.FindArchiveListReq) (int64, []*model.Archive, error) {
+	filter := bson.M{"module_name": in.ModuleName}
+	opt := in.SetPage(options.Find()).SetSort(bson.M{"max_ts": -1})
+	docs := make([]*model.Archive, 0, in.Limit)
+	c, err := d.admin.FindCount(ctx, model.CNArchive, filter, &docs, opt)
+	return c, docs, errc.WithStack(err)
+}
+
+// StartArchiveRestore mark archive restore running, false restore of it already running
+func (d *Dao) StartArchiveRestore(ctx context.Context, doc *model.Archive) (bool, error) {
+	filter := bson.M{
+		"_id":            doc.ID,
+		"restore_status": bson.M{"$ne": model.ArchiveRestoreRunning},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"restore_status": model.ArchiveRestoreRunning,
+			"restore_error":  "",
+		},
+	}
+	matched, err := d.admin.UpdateOne(ctx, model.CNArchive, filter, update, false)
+	return matched > 0, errc.WithStack(err)
+}
+
+// UpdateArchiveRestored record restored database, shard kept until keepUntil
+func (d *Dao) UpdateArchiveRestored(ctx context.Context, doc *model.Archive, dbName string, rows int64, keepUntil time.Time) error {
+	update := bson.M{
+		"$set": bson.M{
+			"restored_at":    time.Now(),
+			"restored_db":    dbName,
+			"keep_until_at":  keepUntil,
+			"restore_status": model.ArchiveRestoreSucceeded,
+			"restored_rows":  rows,
+		},
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNArchive, bson.M{"_id": doc.ID}, update, false)
+	return errc.WithStack(err)
+}
+
+// FailArchiveRestore restore failed, restored logging kept, restore again duplicate ignored
+func (d *Dao) FailArchiveRestore(ctx context.Context, doc *model.Archive, msg string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"restore_status": model.ArchiveRestoreFailed,
+			"restore_error":  msg,
+		},
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNArchive, bson.M{"_id": doc.ID}, update, false)
+	return errc.WithStack(err)
+}
+
+// FailInterruptedArchiveRestore restore running when admin restarted marked failed
+func (d *Dao) FailInterruptedArchiveRestore(ctx context.Context) error {
+	update := bson.M{
+		"$set": bson.M{
+			"restore_status": model.ArchiveRestoreFailed,
+			"restore_error":  "interrupted by restart",
+		},
+	}
+	err := d.admin.UpdateMany(ctx, model.CNArchive, bson.M{"restore_status": model.ArchiveRestoreRunning}, update)
+	return errc.WithStack(err)
+}
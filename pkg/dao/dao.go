@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/bbdshow/bkit/db/mongo"
+	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/qelog/pkg/conf"
+	"github.com/bbdshow/qelog/pkg/store"
 )
 
 // Dao all database operation
@@ -15,32 +17,72 @@ type Dao struct {
 	cfg *conf.Config
 	//custom mongo shard
 	mongo *mongo.Groups
-	// admin mongo inst
-	adminInst *mongo.Database
+	// admin documents storage
+	admin store.DocStore
+	// logging storage backend
+	store store.LogStore
+	// logging storage of clickhouse database, nil disabled
+	clickhouse store.LogStore
 }
 
 func New(cfg *conf.Config) *Dao {
 	d := &Dao{
 		cfg: cfg,
 	}
-	mc, err := mongo.NewGroups(cfg.Mongo)
-	if err != nil {
-		panic(err)
+	var err error
+	if cfg.Storage.Embedded {
+		// admin documents and logging all in local storage, without mongo
+		d.admin, err = store.NewLocalDocStore(cfg.Storage.Dir, cfg.MongoGroup.AdminDatabase)
+		if err != nil {
+			panic(err)
+		}
+		d.store, err = store.NewLocalStore(cfg.Storage.Dir)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		d.mongo, err = mongo.NewGroups(cfg.Mongo)
+		if err != nil {
+			panic(err)
+		}
+		d.admin = store.NewMongoDocStore(d.adminInst())
+		d.store, err = store.New(cfg.Storage.Type, cfg.Storage.Dir, d.mongo)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if cfg.ClickHouse.Enabled() {
+		d.clickhouse, err = store.NewClickHouseStore(store.ClickHouseConfig{
+			Addr:          cfg.ClickHouse.Addr,
+			Username:      cfg.ClickHouse.Username,
+			Password:      cfg.ClickHouse.Password,
+			BatchSize:     cfg.ClickHouse.BatchSize,
+			FlushInterval: time.Duration(cfg.ClickHouse.FlushInterval) * time.Millisecond,
+		})
+		if err != nil {
+			panic(err)
+		}
 	}
-	d.mongo = mc
-
-	d.adminInst = d.AdminInst()
 
 	return d
 }
 
 func (d *Dao) Close() {
+	if d.clickhouse != nil {
+		_ = d.clickhouse.Close()
+	}
+	if d.store != nil {
+		_ = d.store.Close()
+	}
+	if d.admin != nil {
+		_ = d.admin.Close()
+	}
 	if d.mongo != nil {
 		_ = d.mongo.Disconnect()
 	}
 }
 
-func (d *Dao) AdminInst() *mongo.Database {
+func (d *Dao) adminInst() *mongo.Database {
 	dbName := d.cfg.MongoGroup.AdminDatabase
 	db, err := d.mongo.GetInstance(dbName)
 	if err != nil {
@@ -51,6 +93,9 @@ func (d *Dao) AdminInst() *mongo.Database {
 
 // ReceiverInst query receiver server db inst by name
 func (d *Dao) ReceiverInst(dbName string) (*mongo.Database, error) {
+	if d.mongo == nil {
+		return nil, fmt.Errorf("%s mongo not connected, embedded storage", dbName)
+	}
 	db, err := d.mongo.GetInstance(dbName)
 	if err != nil {
 		return nil, fmt.Errorf("%s %v", dbName, err)
@@ -58,6 +103,24 @@ func (d *Dao) ReceiverInst(dbName string) (*mongo.Database, error) {
 	return db, nil
 }
 
+// logStore logging storage of database, clickhouse database routed to clickhouse
+func (d *Dao) logStore(dbName string) store.LogStore {
+	if d.clickhouse != nil && d.cfg.ClickHouse.IsDatabase(dbName) {
+		return d.clickhouse
+	}
+	return d.store
+}
+
+// UpsertAdminIndexMany admin collections created and index
+func (d *Dao) UpsertAdminIndexMany(indexMany ...[]mongo.Index) error {
+	return d.admin.UpsertCollectionIndexMany(indexMany...)
+}
+
+// DropAdminIndex index of admin collection declared before, replaced by other index
+func (d *Dao) DropAdminIndex(ctx context.Context, collection, name string) error {
+	return errc.WithStack(d.admin.DropIndex(ctx, collection, name))
+}
+
 // CtxAfterSecDeadline if not deadline, return defSec, if defSec <= 0, return int32 max sec duration
 func (d *Dao) CtxAfterSecDeadline(ctx context.Context, defSec int32) time.Duration {
 	deadline, ok := ctx.Deadline()
@@ -2,52 +2,56 @@ package dao
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"time"
 
-	"github.com/bbdshow/bkit/db/mongo"
 	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/bkit/logs"
-	apiTypes "github.com/bbdshow/qelog/api/types"
 	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
-// CreateManyLogging multi insert logging to db
+// CreateManyLogging multi insert logging to storage.
+// logging id assigned before written when redelivered by queue, duplicate logging ignored
 func (d *Dao) CreateManyLogging(ctx context.Context, dbName, cName string, docs []interface{}) error {
-	inst, err := d.mongo.GetInstance(dbName)
-	if err != nil {
-		return errc.WithStack(err)
-	}
-	_, err = inst.Collection(cName).InsertMany(ctx, docs)
-	return errc.WithStack(err)
+	return d.logStore(dbName).CreateManyLogging(ctx, dbName, cName, docs)
 }
 
 // ListCollectionNames query this db collection by prefix
 func (d *Dao) ListCollectionNames(ctx context.Context, dbName string, prefix ...string) ([]string, error) {
-	inst, err := d.mongo.GetInstance(dbName)
-	if err != nil {
-		return nil, err
-	}
-	return inst.ListCollectionNames(ctx, prefix...)
+	return d.logStore(dbName).ListCollectionNames(ctx, dbName, prefix...)
 }
 
 // CreateLoggingIndex db runtime create index, when new collection created
-func (d *Dao) CreateLoggingIndex(dbName, cName string) error {
-	inst, err := d.mongo.GetInstance(dbName)
-	if err != nil {
-		return err
-	}
-	return inst.UpsertCollectionIndexMany(model.LoggingIndexMany(cName))
+// fullText create text index on short and full message, support keyword search
+func (d *Dao) CreateLoggingIndex(dbName, cName string, fullText bool) error {
+	return d.logStore(dbName).CreateLoggingIndex(context.Background(), dbName, cName, fullText)
+}
+
+// CreateLoggingTextIndex text index not tokenize by language, because log content mixed language
+func (d *Dao) CreateLoggingTextIndex(ctx context.Context, dbName, cName string) error {
+	return d.logStore(dbName).CreateLoggingTextIndex(ctx, dbName, cName)
+}
+
+// DropLoggingTextIndex release full-text index storage, index not exists ignore
+func (d *Dao) DropLoggingTextIndex(ctx context.Context, dbName, cName string) error {
+	return d.logStore(dbName).DropLoggingTextIndex(ctx, dbName, cName)
 }
 
 // FindLoggingList query logging
 func (d *Dao) FindLoggingList(ctx context.Context, dbName, cName string, in *model.FindLoggingListReq) (int64, []*model.Logging, error) {
-	s := time.Now()
+	filter, err := FindLoggingListFilter(in)
+	if err != nil {
+		return 0, nil, err
+	}
+	return d.FindLoggingByFilter(ctx, dbName, cName, filter, nil, in.PageReq)
+}
+
+// FindLoggingListFilter common condition to filter, the condition order depend on index
+func FindLoggingListFilter(in *model.FindLoggingListReq) (bson.M, error) {
 	filter := bson.M{
 		"m": strings.TrimSpace(in.ModuleName),
 	}
@@ -60,7 +64,7 @@ func (d *Dao) FindLoggingList(ctx context.Context, dbName, cName string, in *mod
 
 	if in.Short != "" {
 		if _, ok := filter["l"]; !ok {
-			return 0, nil, errc.ErrParamInvalid.MultiMsg("required level condition before it can used short message condition")
+			return nil, errc.ErrParamInvalid.MultiMsg("required level condition before it can used short message condition")
 		}
 		filter["s"] = primitive.Regex{
 			Pattern: in.Short,
@@ -68,9 +72,13 @@ func (d *Dao) FindLoggingList(ctx context.Context, dbName, cName string, in *mod
 		}
 	}
 
+	if in.Keyword != "" {
+		filter["$text"] = bson.M{"$search": in.Keyword}
+	}
+
 	if in.IP != "" {
 		if _, ok := filter["s"]; !ok {
-			return 0, nil, errc.ErrParamInvalid.MultiMsg("required short message condition before it can used IP condition")
+			return nil, errc.ErrParamInvalid.MultiMsg("required short message condition before it can used IP condition")
 		}
 		filter["ip"] = in.IP
 	}
@@ -86,110 +94,62 @@ func (d *Dao) FindLoggingList(ctx context.Context, dbName, cName string, in *mod
 		}
 	}
 
-	inst, err := d.mongo.GetInstance(dbName)
-	if err != nil {
-		return 0, nil, errc.ErrParamInvalid.MultiErr(err)
-	}
-	// async count, max number 50000 or max time 15s
-	// too many bar counts, meaningless, and poor performance
-	calcCount := func(ctx context.Context) (int64, error) {
-		opt := options.Count().SetLimit(50000).SetMaxTime(d.CtxAfterSecDeadline(ctx, 15))
-		c, err := inst.Collection(cName).CountDocuments(ctx, filter, opt)
-		return c, err
-	}
-	countResp := make(chan int64, 1)
-	go func() {
-		c, err := calcCount(ctx)
-		if err != nil {
-			logs.Qezap.Error("FindLoggingCount", zap.Error(err))
-		}
-		countResp <- c
-	}()
+	return filter, nil
+}
 
-	docs := make([]*model.Logging, 0, in.Limit)
-	opt := in.SetPage(options.Find()).SetSort(bson.M{"ts": -1})
-	err = inst.Find(ctx, cName, filter, &docs, opt)
+// FindLoggingByFilter query logging by filter, module and time range required in filter for index performance.
+// cursor not nil, find logging after cursor, count still total of filter. returns logging always order by ts desc
+func (d *Dao) FindLoggingByFilter(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, page model.PageReq) (int64, []*model.Logging, error) {
+	s := time.Now()
+	c, docs, err := d.logStore(dbName).FindLogging(ctx, dbName, cName, filter, cursor, page)
 	if err != nil {
-		return 0, docs, errc.ErrInternalErr.MultiErr(err)
+		return 0, docs, err
 	}
+	logs.Qezap.Info("LoggingQuery", zap.String("latency", time.Since(s).String()),
+		zap.String("database", dbName),
+		zap.Any("collection", cName),
+		zap.Any("condition", filter))
+	return c, docs, nil
+}
 
-	select {
-	case c := <-countResp:
-		if c <= 0 {
-			c = int64(len(docs))
-		}
-		logs.Qezap.Info("LoggingQuery", zap.String("latency", time.Since(s).String()),
-			zap.String("database", dbName),
-			zap.Any("collection", cName),
-			zap.Any("condition", filter))
-		return c, docs, nil
-	}
+// FindLoggingAfter query logging after cursor position without count, logging order same as cursor sort
+func (d *Dao) FindLoggingAfter(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, limit int64) ([]*model.Logging, error) {
+	return d.logStore(dbName).FindLoggingAfter(ctx, dbName, cName, filter, cursor, limit)
 }
 
-// FindLoggingByTraceID query logging by traceId
-func (d *Dao) FindLoggingByTraceID(ctx context.Context, m *model.Module, in *model.FindLoggingByTraceIDReq) ([]*model.Logging, error) {
-	tid, err := apiTypes.TraceIDFromHex(in.TraceID)
-	if err != nil {
-		return nil, errc.ErrParamInvalid.MultiErr(err)
-	}
-	// traceId have time info, set it time condition +-2 hour
-	tidTime := tid.Time()
-	b := tidTime.Add(-2 * time.Hour)
-	e := tidTime.Add(2 * time.Hour)
-	dbName := m.Database
-	cNames := make([]string, 0, 2)
-	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
-	if in.ForceDatabase != "" {
-		if !d.cfg.MongoGroup.IsReceiverDatabase(in.ForceDatabase) {
-			return nil, errc.ErrParamInvalid.MultiMsg("force database not receiver database")
-		}
-	}
-	if in.ForceCollectionName != "" {
-		if !strings.HasPrefix(in.ForceCollectionName, m.Prefix) {
-			return nil, errc.ErrParamInvalid.MultiMsg(fmt.Sprintf("force collection name not '%s' prefix", m.Prefix))
-		}
-		cNames = append(cNames, in.ForceCollectionName)
-	} else {
-		cNames = append(cNames, sc.CollNameByStartEnd(m.Bucket, b.Unix(), e.Unix())...)
-	}
+// FindLoggingByTraceID query logging by traceId in shards of database
+func (d *Dao) FindLoggingByTraceID(ctx context.Context, dbName string, cNames []string, moduleName, traceID string) ([]*model.Logging, error) {
+	return d.logStore(dbName).FindLoggingByTraceID(ctx, dbName, cNames, moduleName, traceID)
+}
 
-	inst, err := d.mongo.GetInstance(dbName)
-	if err != nil {
-		return nil, errc.ErrParamInvalid.MultiErr(err)
-	}
+// ReadLoggingCollStats logging collection storage cost by storage backend
+func (d *Dao) ReadLoggingCollStats(ctx context.Context, dbName, cName string) (*store.CollStats, error) {
+	return d.logStore(dbName).CollStats(ctx, dbName, cName)
+}
 
-	allDocs := make([]*model.Logging, 0)
-	for _, cName := range cNames {
-		filter := bson.M{
-			"m":  in.ModuleName,
-			"ti": in.TraceID,
-		}
-		// asc logging by time
-		opt := options.Find().SetSort(bson.M{"ts": 1})
-		docs := make([]*model.Logging, 0)
-		if err := inst.Find(ctx, cName, filter, &docs, opt); err != nil {
-			return nil, errc.ErrInternalErr.MultiErr(err)
-		}
-		allDocs = append(allDocs, docs...)
-	}
-	return allDocs, nil
+// CreateExportAudit record logging export
+func (d *Dao) CreateExportAudit(ctx context.Context, in *model.ExportAudit) error {
+	err := d.admin.InsertOne(ctx, model.CNExportAudit, in)
+	return errc.WithStack(err)
 }
 
-// DropLoggingCollection delete collection
-func (d *Dao) DropLoggingCollection(ctx context.Context, m *model.Module, cName string) error {
-	inst, err := d.mongo.GetInstance(m.Database)
-	if err != nil {
-		return errc.ErrParamInvalid.MultiErr(err)
-	}
-	if err := inst.Collection(cName).Drop(ctx); err != nil {
-		return errc.ErrInternalErr.MultiErr(err)
+// DropLoggingCollection delete collection, shard catalog of this database removed
+func (d *Dao) DropLoggingCollection(ctx context.Context, moduleName, dbName, cName string) error {
+	if err := d.logStore(dbName).DropLoggingCollection(ctx, dbName, cName); err != nil {
+		return err
 	}
 
 	filter := bson.M{
-		"module_name": m.Name,
+		"module_name": moduleName,
+		"db":          dbName,
 		"name":        cName,
 	}
-	_, _ = d.adminInst.Collection(model.CNCollStats).DeleteOne(ctx, filter)
+	_ = d.admin.DeleteOne(ctx, model.CNCollStats, filter)
+	_ = d.admin.DeleteOne(ctx, model.CNShardCatalog, bson.M{
+		"module_name": moduleName,
+		"database":    dbName,
+		"collection":  cName,
+	})
 
 	return nil
 }
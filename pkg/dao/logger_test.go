@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bbdshow/qelog/pkg/conf"
+	"github.com/bbdshow/qelog/pkg/model"
+)
+
+func TestDao_FindLoggingListKeyword(t *testing.T) {
+	// TestClose closed shared dao
+	d := New(conf.Conf)
+	defer d.Close()
+
+	ctx := context.Background()
+	dbName := conf.Conf.MongoGroup.ReceiverDatabase[0]
+	cName := "lg_testing_full_text"
+	if err := d.CreateLoggingIndex(dbName, cName, true); err != nil {
+		t.Fatal(err)
+	}
+	inst, _ := d.mongo.GetInstance(dbName)
+	defer inst.Collection(cName).Drop(ctx)
+
+	now := time.Now().Unix()
+	docs := []interface{}{
+		&model.Logging{Module: "testing", Short: "order created", Full: `{"order":"A100"}`, TimeSec: now},
+		&model.Logging{Module: "testing", Short: "payment failed", Full: `{"order":"A100","reason":"timeout"}`, TimeSec: now},
+	}
+	if err := d.CreateManyLogging(ctx, dbName, cName, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &model.FindLoggingListReq{ModuleName: "testing", Level: -2, Keyword: "timeout"}
+	in.BeginTsSec, in.EndTsSec = now-60, now+60
+	in.Page, in.Limit = 1, 20
+	_, list, err := d.FindLoggingList(ctx, dbName, cName, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Short != "payment failed" {
+		t.Fatalf("keyword search %v", list)
+	}
+
+	if err := d.DropLoggingTextIndex(ctx, dbName, cName); err != nil {
+		t.Fatal(err)
+	}
+	// drop not exists index ignore
+	if err := d.DropLoggingTextIndex(ctx, dbName, cName); err != nil {
+		t.Fatal(err)
+	}
+}
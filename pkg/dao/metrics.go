@@ -21,8 +21,6 @@ func (d *Dao) IncrModuleMetrics(ctx context.Context, in *model.MetricsState) err
 		"created_date": in.Date,
 	}
 
-	opt := options.Update().SetUpsert(true)
-
 	fields := bson.M{
 		"number": in.Number,
 		"size":   in.Size,
@@ -38,62 +36,30 @@ func (d *Dao) IncrModuleMetrics(ctx context.Context, in *model.MetricsState) err
 	update := bson.M{
 		"$inc": fields,
 	}
-	if _, err := d.adminInst.Collection(model.CNModuleMetrics).UpdateOne(ctx, filter, update, opt); err != nil {
+	if _, err := d.admin.UpdateOne(ctx, model.CNModuleMetrics, filter, update, true); err != nil {
 		return errc.WithStack(err)
 	}
 
 	return nil
 }
 
-// GetModuleMetricsCountByDate aggregate module this time period logging count and data size
+// GetModuleMetricsCountByDate sum module this time period logging count and data size
+// one metrics document of module each period, sum by find, storage not need aggregate
 func (d *Dao) GetModuleMetricsCountByDate(ctx context.Context, date time.Time) (*model.ModuleCount, error) {
-	coll := d.adminInst.Collection(model.CNModuleMetrics)
-
-	pipeline := []bson.D{
-		{
-			{Key: "$match", Value: bson.M{"created_date": date}},
-		},
-		{
-			{Key: "$group", Value: bson.M{
-				"_id": bson.D{
-					{"$dateToString", bson.D{
-						{"format", "%Y-%m-%d%H"},
-						{"date", "$created_date"},
-					}},
-				},
-				"numbers": bson.M{"$sum": "$number"},
-				"sizes":   bson.M{"$sum": "$size"},
-				"count":   bson.M{"$sum": 1},
-			}},
-		},
-		{
-			{Key: "$project", Value: bson.M{
-				"numbers": 1,
-				"sizes":   1,
-				"count":   1,
-			}},
-		},
-	}
-	cursor, err := coll.Aggregate(ctx, pipeline)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	type counts = struct {
-		Numbers int64 `bson:"numbers"`
-		Sizes   int64 `bson:"sizes"`
-		Count   int64 `bson:"count"`
+		Number int64 `bson:"number"`
+		Size   int64 `bson:"size"`
 	}
 	val := make([]counts, 0)
-	if err := cursor.All(ctx, &val); err != nil {
+	opt := options.Find().SetProjection(bson.M{"number": 1, "size": 1})
+	if err := d.admin.Find(ctx, model.CNModuleMetrics, bson.M{"created_date": date}, &val, opt); err != nil {
 		return nil, err
 	}
 	out := &model.ModuleCount{}
-	if len(val) > 0 {
-		out.Numbers = val[0].Numbers
-		out.LoggingSize = val[0].Sizes
-		out.Modules = val[0].Count
+	for _, v := range val {
+		out.Numbers += v.Number
+		out.LoggingSize += v.Size
+		out.Modules++
 	}
 	return out, nil
 }
@@ -101,7 +67,7 @@ func (d *Dao) GetModuleMetricsCountByDate(ctx context.Context, date time.Time) (
 // GetDBStats common db CRUD operation
 func (d *Dao) GetDBStats(ctx context.Context, filter bson.M) (bool, *model.DBStats, error) {
 	doc := &model.DBStats{}
-	exists, err := d.adminInst.FindOne(ctx, model.CNDBStats, filter, doc)
+	exists, err := d.admin.FindOne(ctx, model.CNDBStats, filter, doc)
 	return exists, doc, errc.WithStack(err)
 }
 
@@ -109,7 +75,7 @@ func (d *Dao) GetDBStats(ctx context.Context, filter bson.M) (bool, *model.DBSta
 func (d *Dao) FindDBStats(ctx context.Context, filter bson.M) ([]*model.DBStats, error) {
 	docs := make([]*model.DBStats, 0)
 	opt := options.Find().SetSort(bson.M{"_id": -1})
-	err := d.adminInst.Find(ctx, model.CNDBStats, filter, &docs, opt)
+	err := d.admin.Find(ctx, model.CNDBStats, filter, &docs, opt)
 	return docs, errc.WithStack(err)
 }
 
@@ -120,8 +86,6 @@ func (d *Dao) UpsertDBStats(ctx context.Context, in *model.DBStats) error {
 		"db":   in.DB,
 	}
 
-	opt := options.Update().SetUpsert(true)
-
 	update := bson.M{
 		"$set": bson.M{
 			"collections":  in.Collections,
@@ -135,14 +99,14 @@ func (d *Dao) UpsertDBStats(ctx context.Context, in *model.DBStats) error {
 			"created_at": time.Now(),
 		},
 	}
-	_, err := d.adminInst.Collection(model.CNDBStats).UpdateOne(ctx, filter, update, opt)
+	_, err := d.admin.UpdateOne(ctx, model.CNDBStats, filter, update, true)
 	return err
 }
 
 // GetCollStats common db CRUD operation
 func (d *Dao) GetCollStats(ctx context.Context, filter bson.M) (bool, *model.CollStats, error) {
 	doc := &model.CollStats{}
-	exists, err := d.adminInst.FindOne(ctx, model.CNCollStats, filter, doc)
+	exists, err := d.admin.FindOne(ctx, model.CNCollStats, filter, doc)
 	return exists, doc, errc.WithStack(err)
 }
 
@@ -150,7 +114,7 @@ func (d *Dao) GetCollStats(ctx context.Context, filter bson.M) (bool, *model.Col
 func (d *Dao) FindCollStats(ctx context.Context, filter bson.M) ([]*model.CollStats, error) {
 	docs := make([]*model.CollStats, 0)
 	opt := options.Find().SetSort(bson.M{"_id": -1})
-	err := d.adminInst.Find(ctx, model.CNCollStats, filter, &docs, opt)
+	err := d.admin.Find(ctx, model.CNCollStats, filter, &docs, opt)
 	return docs, errc.WithStack(err)
 }
 
@@ -163,8 +127,6 @@ func (d *Dao) UpsertCollStats(ctx context.Context, in *model.CollStats) error {
 		"name":        in.Name,
 	}
 
-	opt := options.Update().SetUpsert(true)
-
 	update := bson.M{
 		"$set": bson.M{
 			"size":             in.Size,
@@ -174,13 +136,14 @@ func (d *Dao) UpsertCollStats(ctx context.Context, in *model.CollStats) error {
 			"capped":           in.Capped,
 			"total_index_size": in.TotalIndexSize,
 			"index_sizes":      in.IndexSizes,
+			"text_index_size":  in.TextIndexSize,
 			"updated_at":       time.Now(),
 		},
 		"$setOnInsert": bson.M{
 			"created_at": time.Now(),
 		},
 	}
-	_, err := d.adminInst.Collection(model.CNCollStats).UpdateOne(ctx, filter, update, opt)
+	_, err := d.admin.UpdateOne(ctx, model.CNCollStats, filter, update, true)
 	return err
 }
 
@@ -199,7 +162,7 @@ func (d *Dao) ReadCollStats(ctx context.Context, db *mongo.Database, collection
 // FindMetricsModule common db CRUD operation
 func (d *Dao) FindMetricsModule(ctx context.Context, filter bson.M) ([]*model.ModuleMetrics, error) {
 	docs := make([]*model.ModuleMetrics, 0)
-	err := d.adminInst.Find(ctx, model.CNModuleMetrics, filter, &docs)
+	err := d.admin.Find(ctx, model.CNModuleMetrics, filter, &docs)
 	return docs, errc.WithStack(err)
 }
 
@@ -219,6 +182,6 @@ func (d *Dao) FindMetricsModuleList(ctx context.Context, in *model.MetricsModule
 	opt := in.SetPage(options.Find()).SetSort(bson.M{"number": -1}).SetProjection(bson.M{"sections": 0})
 
 	docs := make([]*model.ModuleMetrics, 0)
-	c, err := d.adminInst.FindCount(ctx, model.CNModuleMetrics, filter, &docs, opt, nil)
+	c, err := d.admin.FindCount(ctx, model.CNModuleMetrics, filter, &docs, opt)
 	return c, docs, errc.WithStack(err)
 }
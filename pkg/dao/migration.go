@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateMigrationJob common db CRUD operation
+func (d *Dao) CreateMigrationJob(ctx context.Context, doc *model.MigrationJob) error {
+	if doc.ID.IsZero() {
+		doc.ID = primitive.NewObjectID()
+	}
+	err := d.admin.InsertOne(ctx, model.CNMigrationJob, doc)
+	return errc.WithStack(err)
+}
+
+// UpdateMigrationJob job status and shards progress
+func (d *Dao) UpdateMigrationJob(ctx context.Context, doc *model.MigrationJob) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":      doc.Status,
+			"shards":      doc.Shards,
+			"error":       doc.Error,
+			"started_at":  doc.StartedAt,
+			"finished_at": doc.FinishedAt,
+		},
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNMigrationJob, bson.M{"_id": doc.ID}, update, false)
+	return errc.WithStack(err)
+}
+
+// GetMigrationJob common db CRUD operation
+func (d *Dao) GetMigrationJob(ctx context.Context, filter bson.M) (bool, *model.MigrationJob, error) {
+	doc := &model.MigrationJob{}
+	exists, err := d.admin.FindOne(ctx, model.CNMigrationJob, filter, doc)
+	return exists, doc, errc.WithStack(err)
+}
+
+// FindMigrationJobList newer job first
+func (d *Dao) FindMigrationJobList(ctx context.Context, in *model.FindMigrationJobListReq) (int64, []*model.MigrationJob, error) {
+	filter := bson.M{"module_name": in.ModuleName}
+	opt := in.SetPage(options.Find()).SetSort(bson.M{"created_at": -1})
+	docs := make([]*model.MigrationJob, 0, in.Limit)
+	c, err := d.admin.FindCount(ctx, model.CNMigrationJob, filter, &docs, opt)
+	return c, docs, errc.WithStack(err)
+}
+
+// FindUnfinishedMigrationJob pending or running job, interrupted by admin restart
+func (d *Dao) FindUnfinishedMigrationJob(ctx context.Context) ([]*model.MigrationJob, error) {
+	filter := bson.M{"status": bson.M{"$in": bson.A{model.MigrationPending, model.MigrationRunning}}}
+	docs := make([]*model.MigrationJob, 0)
+	err := d.admin.Find(ctx, model.CNMigrationJob, filter, &docs, options.Find().SetSort(bson.M{"created_at": 1}))
+	return docs, errc.WithStack(err)
+}
@@ -23,27 +23,27 @@ func (d *Dao) FindModuleList(ctx context.Context, in *model.FindModuleListReq) (
 	}
 	opt := in.SetPage(options.Find()).SetSort(bson.M{"_id": -1})
 	docs := make([]*model.Module, 0, in.Limit)
-	c, err := d.adminInst.FindCount(ctx, model.CNModule, filter, &docs, opt, nil)
+	c, err := d.admin.FindCount(ctx, model.CNModule, filter, &docs, opt)
 	return c, docs, errc.WithStack(err)
 }
 
 // FindAllModule common db CRUD operation
 func (d *Dao) FindAllModule(ctx context.Context) ([]*model.Module, error) {
 	docs := make([]*model.Module, 0)
-	err := d.adminInst.Find(ctx, model.CNModule, bson.M{}, &docs)
+	err := d.admin.Find(ctx, model.CNModule, bson.M{}, &docs)
 	return docs, errc.WithStack(err)
 }
 
 // CreateModule common db CRUD operation
 func (d *Dao) CreateModule(ctx context.Context, doc *model.Module) error {
-	_, err := d.adminInst.Collection(model.CNModule).InsertOne(ctx, doc)
+	err := d.admin.InsertOne(ctx, model.CNModule, doc)
 	return errc.WithStack(err)
 }
 
 // GetModule common db CRUD operation
 func (d *Dao) GetModule(ctx context.Context, filter bson.M) (bool, *model.Module, error) {
 	doc := &model.Module{}
-	exists, err := d.adminInst.FindOne(ctx, model.CNModule, filter, doc)
+	exists, err := d.admin.FindOne(ctx, model.CNModule, filter, doc)
 	return exists, doc, errc.WithStack(err)
 }
 
@@ -83,6 +83,15 @@ func (d *Dao) UpdateModule(ctx context.Context, in *model.UpdateModuleReq) error
 	if doc.Prefix != in.Prefix {
 		fields["prefix"] = in.Prefix
 	}
+	if in.FullText != nil && doc.FullText != *in.FullText {
+		fields["full_text"] = *in.FullText
+	}
+	if doc.StorageType() != in.Storage {
+		fields["storage"] = in.Storage
+	}
+	if in.Archive != "" && doc.ArchiveType() != in.Archive {
+		fields["archive"] = in.Archive
+	}
 
 	if len(fields) > 0 {
 		fields["updated_at"] = time.Now().Local()
@@ -96,11 +105,35 @@ func (d *Dao) UpdateModule(ctx context.Context, in *model.UpdateModuleReq) error
 		"updated_at": doc.UpdatedAt,
 	}
 
-	uRet, err := d.adminInst.Collection(model.CNModule).UpdateOne(ctx, filter, update)
+	matched, err := d.admin.UpdateOne(ctx, model.CNModule, filter, update, false)
+	if err != nil {
+		return errc.WithStack(err)
+	}
+	if matched <= 0 {
+		return mongo.ErrNotMatched
+	}
+	return nil
+}
+
+// UpdateModuleToken replace access token, current token as previous token valid until prevExpiredAt
+func (d *Dao) UpdateModuleToken(ctx context.Context, doc *model.Module, token string, prevExpiredAt time.Time) error {
+	update := bson.M{
+		"$set": bson.M{
+			"access_token":          token,
+			"prev_access_token":     doc.AccessToken,
+			"prev_token_expired_at": prevExpiredAt,
+			"updated_at":            time.Now().Local(),
+		},
+	}
+	filter := bson.M{
+		"_id":        doc.ID,
+		"updated_at": doc.UpdatedAt,
+	}
+	matched, err := d.admin.UpdateOne(ctx, model.CNModule, filter, update, false)
 	if err != nil {
 		return errc.WithStack(err)
 	}
-	if uRet.MatchedCount <= 0 {
+	if matched <= 0 {
 		return mongo.ErrNotMatched
 	}
 	return nil
@@ -108,6 +141,6 @@ func (d *Dao) UpdateModule(ctx context.Context, in *model.UpdateModuleReq) error
 
 // DelModule common db CRUD operation
 func (d *Dao) DelModule(ctx context.Context, filter bson.M) error {
-	_, err := d.adminInst.Collection(model.CNModule).DeleteOne(ctx, filter)
+	err := d.admin.DeleteOne(ctx, model.CNModule, filter)
 	return errc.WithStack(err)
 }
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RegisterShard shard registered once, registered again not changed
+func (d *Dao) RegisterShard(ctx context.Context, in *model.Shard) error {
+	filter := bson.M{
+		"module_name": in.ModuleName,
+		"database":    in.Database,
+		"collection":  in.Collection,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"begin_ts":    in.BeginTsSec,
+			"end_ts":      in.EndTsSec,
+			"migrated_to": in.MigratedTo,
+			"created_at":  in.CreatedAt,
+		},
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNShardCatalog, filter, update, true)
+	return errc.WithStack(err)
+}
+
+// SwitchShard shard reads routed to target database, source kept as migrated copy
+func (d *Dao) SwitchShard(ctx context.Context, src *model.Shard, dbName string) error {
+	dst := *src
+	dst.Database = dbName
+	dst.MigratedTo = ""
+	dst.CreatedAt = time.Now()
+	if err := d.RegisterShard(ctx, &dst); err != nil {
+		return err
+	}
+	// target copied before, migrated back again
+	if _, err := d.admin.UpdateOne(ctx, model.CNShardCatalog, bson.M{
+		"module_name": dst.ModuleName,
+		"database":    dst.Database,
+		"collection":  dst.Collection,
+	}, bson.M{"$set": bson.M{"migrated_to": ""}}, false); err != nil {
+		return errc.WithStack(err)
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNShardCatalog, bson.M{
+		"module_name": src.ModuleName,
+		"database":    src.Database,
+		"collection":  src.Collection,
+	}, bson.M{"$set": bson.M{"migrated_to": dbName}}, false)
+	return errc.WithStack(err)
+}
+
+// UpdateShardStats shard count and storage size
+func (d *Dao) UpdateShardStats(ctx context.Context, moduleName, dbName, cName string, count, size int64) error {
+	filter := bson.M{
+		"module_name": moduleName,
+		"database":    dbName,
+		"collection":  cName,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"count":            count,
+			"size":             size,
+			"stats_updated_at": time.Now(),
+		},
+	}
+	_, err := d.admin.UpdateOne(ctx, model.CNShardCatalog, filter, update, false)
+	return errc.WithStack(err)
+}
+
+// DeleteShard common db CRUD operation
+func (d *Dao) DeleteShard(ctx context.Context, doc *model.Shard) error {
+	err := d.admin.DeleteOne(ctx, model.CNShardCatalog, bson.M{"_id": doc.ID})
+	return errc.WithStack(err)
+}
+
+// GetShard common db CRUD operation
+func (d *Dao) GetShard(ctx context.Context, filter bson.M) (bool, *model.Shard, error) {
+	doc := &model.Shard{}
+	exists, err := d.admin.FindOne(ctx, model.CNShardCatalog, filter, doc)
+	return exists, doc, errc.WithStack(err)
+}
+
+// FindShard common db CRUD operation, order by begin time
+func (d *Dao) FindShard(ctx context.Context, filter bson.M) ([]*model.Shard, error) {
+	docs := make([]*model.Shard, 0)
+	err := d.admin.Find(ctx, model.CNShardCatalog, filter, &docs, options.Find().SetSort(bson.M{"begin_ts": 1}))
+	return docs, errc.WithStack(err)
+}
+
+// FindShardByTime shards of module time range overlapped, migrated copy included if database specified
+func (d *Dao) FindShardByTime(ctx context.Context, moduleName, dbName string, beginTsSec, endTsSec int64) ([]*model.Shard, error) {
+	filter := bson.M{
+		"module_name": moduleName,
+		"begin_ts":    bson.M{"$lt": endTsSec},
+		"end_ts":      bson.M{"$gt": beginTsSec},
+	}
+	if dbName != "" {
+		filter["database"] = dbName
+	} else {
+		filter["migrated_to"] = ""
+	}
+	return d.FindShard(ctx, filter)
+}
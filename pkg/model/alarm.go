@@ -12,22 +12,61 @@ import (
 )
 
 const (
-	CNAlarmRule = "alarm_rule"
-	CNHookURL   = "hook_url"
+	CNAlarmRule    = "alarm_rule"
+	CNHookURL      = "hook_url"
+	CNAlarmCounter = "alarm_counter"
+	CNAlarmLease   = "alarm_lease"
 )
 
 const (
 	MethodDingDing = iota + 1
 	MethodTelegram
+	MethodWebhook
+	MethodSlack
+	MethodEmail
+	MethodFeishu
+	MethodWeCom
 )
 
+// alarm rule type, exact short message and level equal, other types level at or above rule level
+const (
+	RuleExact      = "exact"
+	RuleShortRegex = "short_regex" // pattern regex on short message
+	RuleFullRegex  = "full_regex"  // pattern regex on full message
+	RuleLevel      = "level"       // only level at or above
+	RuleCondition  = "condition"   // field c1 | c2 | c3 | ip equal pattern
+	RuleJSONPath   = "json_path"   // field json path in full message, e.g. $.user.id, equal pattern, empty pattern exists
+)
+
+// alarm rule condition, hit alert matched logging limited by rate sec.
+// other conditions evaluated by matched count in window, counted by all receivers
+const (
+	ConditionHit       = "hit"
+	ConditionThreshold = "threshold" // matched count in window above threshold
+	ConditionRatio     = "ratio"     // matched percent of module logging in window above threshold
+	ConditionAbsence   = "absence"   // no matched logging in window
+)
+
+// AlarmCounterStepSec window counter bucket
+const AlarmCounterStepSec = 10
+
+// legacy unique index, before rule type
+const alarmRuleLegacyIndexName = "module_name_1_short_1_level_1"
+
 // AlarmRule alarm rule collection
 type AlarmRule struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty"`
 	Enable     bool               `bson:"enable"`
 	ModuleName string             `bson:"module_name" `
+	Type       string             `bson:"type"`
 	Short      string             `bson:"short"`
+	Field      string             `bson:"field"`
+	Pattern    string             `bson:"pattern"`
 	Level      types.Level        `bson:"level"`
+	Condition  string             `bson:"condition"`
+	WindowSec  int64              `bson:"window_sec"`
+	Threshold  float64            `bson:"threshold"`
+	Template   string             `bson:"template"` // alarm message text/template, empty hook template used
 	Tag        string             `bson:"tag"`
 	RateSec    int64              `bson:"rate_sec"`
 	Method     Method             `bson:"method"`
@@ -39,24 +78,95 @@ func (AlarmRule) CollectionName() string {
 	return CNAlarmRule
 }
 
+// RuleType rule created before rule type exact
+func (ar AlarmRule) RuleType() string {
+	if ar.Type == "" {
+		return RuleExact
+	}
+	return ar.Type
+}
+
+// AlarmCondition rule created before condition hit
+func (ar AlarmRule) AlarmCondition() string {
+	if ar.Condition == "" {
+		return ConditionHit
+	}
+	return ar.Condition
+}
+
+// Windowed condition evaluated by window count
+func (ar AlarmRule) Windowed() bool {
+	return ar.AlarmCondition() != ConditionHit
+}
+
+// Key exact rule same as logging key
 func (ar AlarmRule) Key() string {
-	return fmt.Sprintf("%s_%s_%s", ar.ModuleName, ar.Short, ar.Level)
+	if ar.RuleType() == RuleExact {
+		return fmt.Sprintf("%s_%s_%s", ar.ModuleName, ar.Short, ar.Level)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s", ar.ModuleName, ar.Type, ar.Field, ar.Pattern, ar.Level)
 }
 
 type HookURL struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Name      string             `bson:"name"`
-	URL       string             `bson:"url"`
-	Method    Method             `bson:"method"`
-	KeyWord   string             `bson:"key_word"`
-	HideText  []string           `bson:"hide_text"`
-	UpdatedAt time.Time          `bson:"updated_at"`
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Name     string             `bson:"name"`
+	URL      string             `bson:"url"`
+	Method   Method             `bson:"method"`
+	KeyWord  string             `bson:"key_word"`
+	HideText []string           `bson:"hide_text"`
+	// BodyTemplate webhook method request json body, text/template
+	BodyTemplate string    `bson:"body_template"`
+	Template     string    `bson:"template"` // alarm message text/template of rules, rule template first
+	UpdatedAt    time.Time `bson:"updated_at"`
 }
 
 func (HookURL) CollectionName() string {
 	return CNHookURL
 }
 
+// AlarmCounter matched and module logging count of windowed rule in bucket, incremented by every receiver
+type AlarmCounter struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	RuleID    string             `bson:"rule_id"`
+	BucketTs  int64              `bson:"bucket_ts"`
+	Hits      int64              `bson:"hits"`
+	Total     int64              `bson:"total"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+func (AlarmCounter) CollectionName() string {
+	return CNAlarmCounter
+}
+
+func AlarmCounterIndexMany() []mongo.Index {
+	return []mongo.Index{
+		{
+			Collection: CNAlarmCounter,
+			Keys: bson.D{
+				{
+					Key: "rule_id", Value: 1,
+				},
+				{
+					Key: "bucket_ts", Value: 1,
+				},
+			},
+			Unique:     true,
+			Background: true,
+		},
+		// ttl 2 days, window max 1 day
+		{
+			Collection: CNAlarmCounter,
+			Keys: bson.D{
+				{
+					Key: "created_at", Value: 1,
+				},
+			},
+			Background:         true,
+			ExpireAfterSeconds: 86400 * 2,
+		},
+	}
+}
+
 type Method int32
 
 func (m Method) Int32() int32 {
@@ -69,10 +179,25 @@ func (m Method) String() string {
 		v = "DingDing"
 	case MethodTelegram:
 		v = "Telegram"
+	case MethodWebhook:
+		v = "Webhook"
+	case MethodSlack:
+		v = "Slack"
+	case MethodEmail:
+		v = "Email"
+	case MethodFeishu:
+		v = "Feishu"
+	case MethodWeCom:
+		v = "WeCom"
 	}
 	return v
 }
 
+// AlarmRuleLegacyIndex index replaced by rule type unique index, dropped before created
+func AlarmRuleLegacyIndex() (collection, name string) {
+	return CNAlarmRule, alarmRuleLegacyIndexName
+}
+
 func AlarmRuleIndexMany() []mongo.Index {
 	return []mongo.Index{{
 		Collection: CNAlarmRule,
@@ -86,6 +211,15 @@ func AlarmRuleIndexMany() []mongo.Index {
 			{
 				Key: "level", Value: 1,
 			},
+			{
+				Key: "type", Value: 1,
+			},
+			{
+				Key: "field", Value: 1,
+			},
+			{
+				Key: "pattern", Value: 1,
+			},
 		},
 		Unique:     true,
 		Background: true,
@@ -100,3 +234,35 @@ func AlarmRuleIndexMany() []mongo.Index {
 		Background: true,
 	}}
 }
+
+// AlarmLease send lease of rule, held by one receiver until expire, hits of all receivers merged.
+// hits not sent = hits - sent_hits
+type AlarmLease struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	RuleID      string             `bson:"rule_id"`
+	Owner       string             `bson:"owner"`
+	Node        string             `bson:"node"`
+	ExpireTsSec int64              `bson:"expire_ts"`
+	Hits        int64              `bson:"hits"`
+	SentHits    int64              `bson:"sent_hits"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+func (AlarmLease) CollectionName() string {
+	return CNAlarmLease
+}
+
+func AlarmLeaseIndexMany() []mongo.Index {
+	return []mongo.Index{
+		{
+			Collection: CNAlarmLease,
+			Keys: bson.D{
+				{
+					Key: "rule_id", Value: 1,
+				},
+			},
+			Unique:     true,
+			Background: true,
+		},
+	}
+}
@@ -8,26 +8,43 @@ type FindAlarmRuleListReq struct {
 }
 
 type FindAlarmRuleList struct {
-	ID           string `json:"id"`
-	Enable       bool   `json:"enable"`
-	ModuleName   string `json:"moduleName"`
-	Short        string `json:"short"`
-	Level        int32  `json:"level"`
-	Tag          string `json:"tag"`
-	RateSec      int64  `json:"rateSec"`
-	Method       int32  `json:"method"`
-	HookID       string `json:"hookId"`
-	UpdatedTsSec int64  `json:"updatedTsSec"`
+	ID           string  `json:"id"`
+	Enable       bool    `json:"enable"`
+	ModuleName   string  `json:"moduleName"`
+	Type         string  `json:"type"`
+	Short        string  `json:"short"`
+	Field        string  `json:"field"`
+	Pattern      string  `json:"pattern"`
+	Level        int32   `json:"level"`
+	Condition    string  `json:"condition"`
+	WindowSec    int64   `json:"windowSec"`
+	Threshold    float64 `json:"threshold"`
+	Template     string  `json:"template"`
+	Tag          string  `json:"tag"`
+	RateSec      int64   `json:"rateSec"`
+	Method       int32   `json:"method"`
+	HookID       string  `json:"hookId"`
+	UpdatedTsSec int64   `json:"updatedTsSec"`
 }
 
 type CreateAlarmRuleReq struct {
 	ModuleName string `json:"moduleName" binding:"required"`
-	Short      string `json:"short" binding:"required"`
-	Level      int32  `json:"level" binding:"min=-1,max=8"`
-	Tag        string `json:"tag" binding:"omitempty,gte=1,lte=128"`
-	RateSec    int64  `json:"rateSec" binding:"min=0"`
-	Method     int32  `json:"method" binding:"required,min=1"`
-	HookID     string `json:"hookId" binding:"required,len=24"`
+	// default exact, short required. other type level at or above
+	Type    string `json:"type" binding:"omitempty,oneof=exact short_regex full_regex level condition json_path"`
+	Short   string `json:"short"`
+	Field   string `json:"field" binding:"omitempty,lte=128"`
+	Pattern string `json:"pattern" binding:"omitempty,lte=512"`
+	Level   int32  `json:"level" binding:"min=-1,max=8"`
+	// default hit, other condition window required, threshold count or percent
+	Condition string  `json:"condition" binding:"omitempty,oneof=hit threshold ratio absence"`
+	WindowSec int64   `json:"windowSec" binding:"omitempty,min=10,max=86400"`
+	Threshold float64 `json:"threshold" binding:"omitempty,min=0"`
+	// message text/template, empty hook template or default
+	Template string `json:"template" binding:"omitempty,lte=4096"`
+	Tag      string `json:"tag" binding:"omitempty,gte=1,lte=128"`
+	RateSec  int64  `json:"rateSec" binding:"min=0"`
+	Method   int32  `json:"method" binding:"required,min=1"`
+	HookID   string `json:"hookId" binding:"required,len=24"`
 }
 
 type UpdateAlarmRuleReq struct {
@@ -52,6 +69,8 @@ type FindHookURLList struct {
 	Method       int32    `json:"method"`
 	KeyWord      string   `json:"keyWord"`
 	HideText     []string `json:"hideText"`
+	BodyTemplate string   `json:"bodyTemplate"`
+	Template     string   `json:"template"`
 	UpdatedTsSec int64    `json:"updatedTsSec"`
 }
 
@@ -61,6 +80,10 @@ type CreateHookURLReq struct {
 	Method   int32    `json:"method" binding:"required"`
 	KeyWord  string   `json:"keyWord" binding:"omitempty,lte=24"`
 	HideText []string `json:"hideText"`
+	// webhook method json body, default {"content":{{json .Content}}}
+	BodyTemplate string `json:"bodyTemplate" binding:"omitempty,lte=4096"`
+	// alarm message text/template of rules without template
+	Template string `json:"template" binding:"omitempty,lte=4096"`
 }
 
 type UpdateHookURLReq struct {
@@ -79,3 +102,15 @@ type DelAlarmRuleReq struct {
 type PingHookURLReq struct {
 	ObjectIDReq
 }
+
+type PreviewAlarmTemplateReq struct {
+	// empty template of rule, hook or default
+	Template string `json:"template" binding:"omitempty,lte=4096"`
+	// optional, message of rule and hook, hook key word and hide text
+	RuleID string `json:"ruleId" binding:"omitempty,len=24"`
+	HookID string `json:"hookId" binding:"omitempty,len=24"`
+}
+
+type PreviewAlarmTemplateResp struct {
+	Content string `json:"content"`
+}
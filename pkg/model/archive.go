@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CNArchive = "archive"
+)
+
+// restore status of archive, empty never restored
+const (
+	ArchiveRestoreRunning   = "running"
+	ArchiveRestoreSucceeded = "succeeded"
+	ArchiveRestoreFailed    = "failed"
+)
+
+// ArchiveFormat logging per line mongo extended json, gzip compressed, same as mongoexport output
+const ArchiveFormat = "ndjson.gz"
+
+// Archive manifest of archived shard collection, one shard one record.
+// manifest also written as json object beside archived data object, restore without admin database
+type Archive struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ModuleName  string             `bson:"module_name" json:"moduleName"`
+	Database    string             `bson:"database" json:"database"`
+	Collection  string             `bson:"collection" json:"collection"`
+	Target      string             `bson:"target" json:"target"` // oneof dir | s3
+	Key         string             `bson:"key" json:"key"`       // data object key
+	Format      string             `bson:"format" json:"format"`
+	Rows        int64              `bson:"rows" json:"rows"`
+	Bytes       int64              `bson:"bytes" json:"bytes"` // compressed object size
+	SHA256      string             `bson:"sha256" json:"sha256"`
+	MinTsSec    int64              `bson:"min_ts" json:"minTsSec"`
+	MaxTsSec    int64              `bson:"max_ts" json:"maxTsSec"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	RestoredAt  time.Time          `bson:"restored_at" json:"-"`
+	RestoredDB  string             `bson:"restored_db" json:"-"`
+	KeepUntilAt time.Time          `bson:"keep_until_at" json:"-"` // restored shard not dropped before it
+	// restored in background, status of last restore
+	RestoreStatus string `bson:"restore_status" json:"-"`
+	RestoreError  string `bson:"restore_error" json:"-"`
+	RestoredRows  int64  `bson:"restored_rows" json:"-"`
+}
+
+func (Archive) CollectionName() string {
+	return CNArchive
+}
+
+// ManifestKey manifest object key beside data object
+func (a Archive) ManifestKey() string {
+	return a.Key + ".manifest.json"
+}
+
+func ArchiveIndexMany() []mongo.Index {
+	return []mongo.Index{{
+		Collection: CNArchive,
+		Keys: bson.D{
+			{
+				Key: "module_name", Value: 1,
+			},
+			{
+				Key: "database", Value: 1,
+			},
+			{
+				Key: "collection", Value: 1,
+			},
+		},
+		Unique:     true,
+		Background: true,
+	}}
+}
@@ -0,0 +1,40 @@
+package model
+
+type FindArchiveListReq struct {
+	ModuleName string `json:"moduleName" form:"moduleName" binding:"required"`
+	PageReq
+}
+
+type FindArchiveList struct {
+	ID               string `json:"id"`
+	ModuleName       string `json:"moduleName"`
+	Database         string `json:"database"`
+	Collection       string `json:"collection"`
+	Target           string `json:"target"`
+	Key              string `json:"key"`
+	Rows             int64  `json:"rows"`
+	Bytes            int64  `json:"bytes"`
+	MinTsSec         int64  `json:"minTsSec"`
+	MaxTsSec         int64  `json:"maxTsSec"`
+	CreatedTsSec     int64  `json:"createdTsSec"`
+	RestoredTsSec    int64  `json:"restoredTsSec"` // 0 never restored
+	RestoredDatabase string `json:"restoredDatabase"`
+	KeepUntilTsSec   int64  `json:"keepUntilTsSec"`
+	RestoreStatus    string `json:"restoreStatus"` // empty never restored
+	RestoreError     string `json:"restoreError"`
+	RestoredRows     int64  `json:"restoredRows"`
+}
+
+type RestoreArchiveReq struct {
+	ObjectIDReq
+	// receiver database restored to, empty archived database
+	Database string `json:"database"`
+	// restored shard kept days before dropped again, default 7
+	KeepDays int `json:"keepDays" binding:"omitempty,gte=1,lte=365"`
+}
+
+// RestoreArchiveResp restored in background, status and rows in archive list
+type RestoreArchiveResp struct {
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+}
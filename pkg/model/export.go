@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CNExportAudit = "export_audit"
+)
+
+const (
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatCSV    = "csv"
+)
+
+// ExportAudit who exported logging, and how many
+type ExportAudit struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Operator   string             `bson:"operator"`
+	IP         string             `bson:"ip"`
+	ModuleName string             `bson:"module_name"`
+	Condition  string             `bson:"condition"` // export request json
+	Format     string             `bson:"format"`
+	Gzip       bool               `bson:"gzip"`
+	Rows       int64              `bson:"rows"`
+	Bytes      int64              `bson:"bytes"` // before gzip
+	Truncated  bool               `bson:"truncated"`
+	Error      string             `bson:"error"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+func (ExportAudit) CollectionName() string {
+	return CNExportAudit
+}
+
+func ExportAuditIndexMany() []mongo.Index {
+	return []mongo.Index{{
+		Collection: CNExportAudit,
+		Keys: bson.D{
+			{
+				Key: "module_name", Value: 1,
+			},
+			{
+				Key: "created_at", Value: -1,
+			},
+		},
+		Background: true,
+	}}
+}
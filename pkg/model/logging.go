@@ -1,6 +1,8 @@
 package model
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 
 	"github.com/bbdshow/bkit/db/mongo"
@@ -31,6 +33,75 @@ func (l Logging) Key() string {
 	return fmt.Sprintf("%s_%s_%s", l.Module, l.Short, l.Level)
 }
 
+// LoggingShard logging collection position, module database may be moved, shard in history database
+type LoggingShard struct {
+	Database   string
+	Collection string
+}
+
+// LoggingCursor search after position, logging sort by ts desc, _id desc.
+// ts not unique, _id as tie-breaker make position stable.
+// Prev true, search newer logging before position, new written logging not shift page
+type LoggingCursor struct {
+	TsSec int64              `json:"ts"`
+	ID    primitive.ObjectID `json:"id"`
+	Prev  bool               `json:"prev,omitempty"`
+}
+
+func NewLoggingCursor(l *Logging, prev bool) *LoggingCursor {
+	return &LoggingCursor{TsSec: l.TimeSec, ID: l.ID, Prev: prev}
+}
+
+// Encode opaque token, client should not depend on its content
+func (c LoggingCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeLoggingCursor(token string) (*LoggingCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	c := &LoggingCursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.ID.IsZero() {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// Filter logging after cursor position, prev before position
+func (c LoggingCursor) Filter() bson.A {
+	op := "$lt"
+	if c.Prev {
+		op = "$gt"
+	}
+	return bson.A{
+		bson.M{"ts": bson.M{op: c.TsSec}},
+		bson.M{"ts": c.TsSec, "_id": bson.M{op: c.ID}},
+	}
+}
+
+// Sort nearest cursor position first, prev asc
+func (c LoggingCursor) Sort() bson.D {
+	v := -1
+	if c.Prev {
+		v = 1
+	}
+	return bson.D{{Key: "ts", Value: v}, {Key: "_id", Value: v}}
+}
+
+// LoggingLess order by ts desc, _id desc, same as query sort
+func LoggingLess(a, b *Logging) bool {
+	if a.TimeSec != b.TimeSec {
+		return a.TimeSec > b.TimeSec
+	}
+	return a.ID.Hex() > b.ID.Hex()
+}
+
+// LoggingTextIndexName full-text search index name
+const LoggingTextIndexName = "s_text_f_text"
+
 // LoggingIndexMany
 // only one joint index is created. reduce the index size, written performance
 // optimize query conditions to ensure index matching.
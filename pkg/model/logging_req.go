@@ -8,13 +8,61 @@ type FindLoggingListReq struct {
 	ConditionOne   string `json:"conditionOne"`
 	ConditionTwo   string `json:"conditionTwo"`
 	ConditionThree string `json:"conditionThree"`
+	// full-text search short and full message, module full text enabled
+	Keyword string `json:"keyword" binding:"omitempty,lte=128"`
 	// force collection name, used to when data is migrated shard
 	ForceCollectionName string `json:"forceCollectionName"`
 	ForceDatabase       string `json:"forceDatabase"`
+	// next page cursor returned by last query, priority over page
+	Cursor string `json:"cursor"`
 	TimeReq
 	PageReq
 }
 
+type QueryLoggingReq struct {
+	ModuleName string `json:"moduleName" binding:"required"`
+	// eg: level>=WARN AND c1="order" AND full~"timeout" AND ip IN ("127.0.0.1")
+	Query  string `json:"query" binding:"required"`
+	Cursor string `json:"cursor"`
+	TimeReq
+	PageReq
+}
+
+// QueryLoggingErr query text parse or validate error position
+type QueryLoggingErr struct {
+	Pos     int    `json:"pos"`
+	Message string `json:"message"`
+}
+
+// ExportLoggingReq export all logging match condition, page ignored
+type ExportLoggingReq struct {
+	FindLoggingListReq
+	Format string `json:"format" binding:"omitempty,oneof=ndjson csv"`
+	Gzip   bool   `json:"gzip"`
+	// default and max limit by server
+	MaxRows int64 `json:"maxRows" binding:"omitempty,min=1"`
+}
+
+// TailLoggingReq live tail condition, same as FindLoggingListReq without time range
+type TailLoggingReq struct {
+	ModuleName     string `json:"moduleName" form:"moduleName" binding:"required"`
+	Short          string `json:"short" form:"short"`
+	Level          int32  `json:"level" form:"level,default=-2" binding:"omitempty,min=-2,max=5"`
+	IP             string `json:"ip" form:"ip"`
+	ConditionOne   string `json:"conditionOne" form:"conditionOne"`
+	ConditionTwo   string `json:"conditionTwo" form:"conditionTwo"`
+	ConditionThree string `json:"conditionThree" form:"conditionThree"`
+	TraceID        string `json:"traceId" form:"traceId"`
+}
+
+type FindLoggingListResp struct {
+	ListResp
+	// older logging page, empty no more data
+	NextCursor string `json:"nextCursor"`
+	// newer logging page, logging written after first query can be read by it
+	PrevCursor string `json:"prevCursor"`
+}
+
 type FindLoggingByTraceIDReq struct {
 	ModuleName          string `json:"moduleName" binding:"required"`
 	TraceID             string `json:"traceId" binding:"required,gte=19"`
@@ -38,4 +86,6 @@ type FindLoggingList struct {
 type DropLoggingCollectionReq struct {
 	ModuleName string `json:"moduleName" binding:"required"`
 	Collection string `json:"collection" binding:"required"`
+	// empty shard located database
+	Database string `json:"database"`
 }
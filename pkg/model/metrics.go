@@ -142,6 +142,7 @@ type CollStats struct {
 	Capped         bool               `bson:"capped"`
 	TotalIndexSize int64              `bson:"total_index_size"`
 	IndexSizes     map[string]int64   `bson:"index_sizes"`
+	TextIndexSize  int64              `bson:"text_index_size"` // full-text index storage cost
 	UpdatedAt      time.Time          `bson:"updated_at"`
 	CreatedAt      time.Time          `bson:"created_at"`
 }
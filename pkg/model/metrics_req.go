@@ -35,6 +35,7 @@ type CollStat struct {
 	Capped         bool             `json:"capped"`
 	TotalIndexSize int64            `json:"totalIndexSize"`
 	IndexSizes     map[string]int64 `json:"indexSizes"`
+	TextIndexSize  int64            `json:"textIndexSize"`
 	UpdatedTsSec   int64            `json:"updatedTsSec"`
 	CreatedTsSec   int64            `json:"createdTsSec"`
 }
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CNMigrationJob = "migration_job"
+)
+
+// migration job status
+const (
+	MigrationPending   = "pending"
+	MigrationRunning   = "running"
+	MigrationSucceeded = "succeeded"
+	MigrationFailed    = "failed"
+	MigrationCanceled  = "canceled"
+)
+
+// migration shard status, switched reads routed to target database by shard catalog
+const (
+	MigrationShardPending  = "pending"
+	MigrationShardCopying  = "copying"
+	MigrationShardSwitched = "switched"
+	MigrationShardDropped  = "dropped"
+)
+
+// MigrationJob copy module shard collections from one receiver database to another.
+// each shard copied, counts verified, then reads switched by shard catalog, source dropped optional
+type MigrationJob struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	ModuleName   string             `bson:"module_name"`
+	FromDatabase string             `bson:"from_database"`
+	ToDatabase   string             `bson:"to_database"`
+	DropSource   bool               `bson:"drop_source"`
+	Status       string             `bson:"status"`
+	Shards       []MigrationShard   `bson:"shards"`
+	Error        string             `bson:"error"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	StartedAt    time.Time          `bson:"started_at"`
+	FinishedAt   time.Time          `bson:"finished_at"`
+}
+
+func (MigrationJob) CollectionName() string {
+	return CNMigrationJob
+}
+
+func (j MigrationJob) Finished() bool {
+	return j.Status == MigrationSucceeded || j.Status == MigrationFailed || j.Status == MigrationCanceled
+}
+
+type MigrationShard struct {
+	Collection string `bson:"collection" json:"collection"`
+	Status     string `bson:"status" json:"status"`
+	SrcCount   int64  `bson:"src_count" json:"srcCount"`
+	Copied     int64  `bson:"copied" json:"copied"`
+	DstCount   int64  `bson:"dst_count" json:"dstCount"`
+}
+
+func MigrationJobIndexMany() []mongo.Index {
+	return []mongo.Index{{
+		Collection: CNMigrationJob,
+		Keys: bson.D{
+			{
+				Key: "module_name", Value: 1,
+			},
+			{
+				Key: "created_at", Value: -1,
+			},
+		},
+		Background: true,
+	}}
+}
@@ -0,0 +1,42 @@
+package model
+
+type CreateMigrationJobReq struct {
+	ModuleName   string `json:"moduleName" binding:"required"`
+	FromDatabase string `json:"fromDatabase" binding:"required"`
+	ToDatabase   string `json:"toDatabase" binding:"required,nefield=FromDatabase"`
+	// empty all module shards located in from database
+	Collections []string `json:"collections"`
+	DropSource  bool     `json:"dropSource"`
+}
+
+type CreateMigrationJobResp struct {
+	ID string `json:"id"`
+}
+
+type FindMigrationJobListReq struct {
+	ModuleName string `json:"moduleName" form:"moduleName" binding:"required"`
+	PageReq
+}
+
+type GetMigrationJobReq struct {
+	ObjectIDReq
+}
+
+type CancelMigrationJobReq struct {
+	ObjectIDReq
+}
+
+type FindMigrationJobList struct {
+	ID            string           `json:"id"`
+	ModuleName    string           `json:"moduleName"`
+	FromDatabase  string           `json:"fromDatabase"`
+	ToDatabase    string           `json:"toDatabase"`
+	DropSource    bool             `json:"dropSource"`
+	Status        string           `json:"status"`
+	Shards        []MigrationShard `json:"shards"`
+	Progress      float64          `json:"progress"` // copied / source count of all shards, 0-1
+	Error         string           `json:"error"`
+	CreatedTsSec  int64            `json:"createdTsSec"`
+	StartedTsSec  int64            `json:"startedTsSec"`
+	FinishedTsSec int64            `json:"finishedTsSec"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"github.com/bbdshow/bkit/db/mongo"
 	"time"
@@ -14,28 +15,79 @@ const (
 	CNModule = "module"
 )
 
+// module logging storage type, empty as mongo, module created by old version
+const (
+	StorageMongo      = "mongo"
+	StorageClickHouse = "clickhouse"
+	// StorageLocal embedded mode, not module option
+	StorageLocal = "local"
+)
+
+// module archive policy, expired shard archived to target before dropped
+const (
+	ArchiveNone = "none"
+	ArchiveDir  = "dir"
+	ArchiveS3   = "s3"
+)
+
 // Module 接入应用模块初始化
 type Module struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name      string             `bson:"name" json:"name"`
-	Desc      string             `bson:"desc" json:"desc"`
-	Bucket    string             `bson:"bucket" json:"bucket"`
-	Database  string             `bson:"database" json:"database"`
-	DaySpan   int                `bson:"day_span" json:"day_span"`
-	MaxMonth  int                `bson:"max_month" json:"max_month"`
-	Prefix    string             `bson:"prefix" json:"prefix"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name               string             `bson:"name" json:"name"`
+	Desc               string             `bson:"desc" json:"desc"`
+	Bucket             string             `bson:"bucket" json:"bucket"`
+	Database           string             `bson:"database" json:"database"`
+	DaySpan            int                `bson:"day_span" json:"day_span"`
+	MaxMonth           int                `bson:"max_month" json:"max_month"`
+	Prefix             string             `bson:"prefix" json:"prefix"`
+	FullText           bool               `bson:"full_text" json:"full_text"`                         // logging collection create text index, support keyword search
+	Storage            string             `bson:"storage" json:"storage"`                             // logging storage type, oneof mongo | clickhouse
+	Archive            string             `bson:"archive" json:"archive"`                             // expired shard archive policy, oneof none | dir | s3
+	AccessToken        string             `bson:"access_token" json:"access_token"`                   // empty not verify, module created by old version
+	PrevAccessToken    string             `bson:"prev_access_token" json:"prev_access_token"`         // after rotated, still valid in grace period
+	PrevTokenExpiredAt time.Time          `bson:"prev_token_expired_at" json:"prev_token_expired_at"` // grace period end
+	UpdatedAt          time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 func (m Module) CollectionName() string {
 	return CNModule
 }
 
+func (m Module) StorageType() string {
+	if m.Storage == "" {
+		return StorageMongo
+	}
+	return m.Storage
+}
+
+func (m Module) ArchiveType() string {
+	if m.Archive == "" {
+		return ArchiveNone
+	}
+	return m.Archive
+}
+
 func (m Module) LoggingPrefix() string {
 	sc := mongo.NewShardCollection(m.Prefix, m.DaySpan)
 	return fmt.Sprintf("%s%s%s", sc.Prefix, sc.Sep, m.Bucket)
 }
 
+// VerifyAccessToken token match current token, or previous token in grace period
+func (m Module) VerifyAccessToken(token string) bool {
+	if m.AccessToken == "" {
+		return true
+	}
+	if tokenEqual(m.AccessToken, token) {
+		return true
+	}
+	return m.PrevAccessToken != "" && time.Now().Before(m.PrevTokenExpiredAt) &&
+		tokenEqual(m.PrevAccessToken, token)
+}
+
+func tokenEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
 func ModuleIndexMany() []mongo.Index {
 	return []mongo.Index{
 		{
@@ -5,6 +5,9 @@ type CreateModuleReq struct {
 	Desc     string `json:"desc" binding:"omitempty,gte=1,lte=128"`
 	DaySpan  int    `json:"daySpan" binding:"omitempty,gte=1,lte=31"`
 	MaxMonth int    `json:"maxMonth" binding:"omitempty,gte=1"`
+	FullText bool   `json:"fullText"`
+	Storage  string `json:"storage" binding:"omitempty,oneof=mongo clickhouse"`
+	Archive  string `json:"archive" binding:"omitempty,oneof=none dir s3"`
 }
 
 type FindModuleListReq struct {
@@ -13,15 +16,20 @@ type FindModuleListReq struct {
 }
 
 type FindModuleList struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Desc         string `json:"desc"`
-	Bucket       string `json:"bucket"`
-	DaySpan      int    `json:"daySpan"`
-	MaxMonth     int    `json:"maxMonth"`
-	Database     string `json:"database"`
-	Prefix       string `json:"prefix"`
-	UpdatedTsSec int64  `json:"updatedTsSec"`
+	ID                    string `json:"id"`
+	Name                  string `json:"name"`
+	Desc                  string `json:"desc"`
+	Bucket                string `json:"bucket"`
+	DaySpan               int    `json:"daySpan"`
+	MaxMonth              int    `json:"maxMonth"`
+	Database              string `json:"database"`
+	Prefix                string `json:"prefix"`
+	FullText              bool   `json:"fullText"`
+	Storage               string `json:"storage"`
+	Archive               string `json:"archive"`
+	AccessToken           string `json:"accessToken"`
+	PrevTokenExpiredTsSec int64  `json:"prevTokenExpiredTsSec"` // 0 no previous token valid
+	UpdatedTsSec          int64  `json:"updatedTsSec"`
 }
 
 type UpdateModuleReq struct {
@@ -32,9 +40,24 @@ type UpdateModuleReq struct {
 	Database string `json:"database" binding:"omitempty,gte=1"`
 	Prefix   string `json:"prefix" binding:"omitempty,gte=1,lte=12"`
 	Desc     string `json:"desc" binding:"omitempty,gte=1,lte=128"`
+	FullText *bool  `json:"fullText"` // nil not changed
+	Storage  string `json:"storage" binding:"omitempty,oneof=mongo clickhouse"`
+	Archive  string `json:"archive" binding:"omitempty,oneof=none dir s3"`
 }
 
 type DelModuleReq struct {
 	ObjectIDReq
 	Name string `json:"name" binding:"required"`
 }
+
+type RotateModuleTokenReq struct {
+	ObjectIDReq
+	Name string `json:"name" binding:"required"`
+	// previous token still valid grace period, 0 previous token invalid immediately
+	GraceSec int64 `json:"graceSec" binding:"omitempty,gte=0,lte=2592000"`
+}
+
+type RotateModuleTokenResp struct {
+	AccessToken           string `json:"accessToken"`
+	PrevTokenExpiredTsSec int64  `json:"prevTokenExpiredTsSec"`
+}
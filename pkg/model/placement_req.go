@@ -0,0 +1,39 @@
+package model
+
+// receiver database placement of new module
+const (
+	PlacementRandom  = "random"
+	PlacementStorage = "storage"
+	PlacementIngest  = "ingest"
+	PlacementWeight  = "weight"
+)
+
+type RebalanceAdviceReq struct {
+	By    string  `json:"by" form:"by" binding:"omitempty,oneof=storage ingest"` // default storage
+	Ratio float64 `json:"ratio" form:"ratio" binding:"omitempty,gt=1"`           // database load above mean ratio, default 1.5
+}
+
+type RebalanceAdviceResp struct {
+	By        string           `json:"by"`
+	Ratio     float64          `json:"ratio"`
+	Mean      float64          `json:"mean"`
+	Databases []*DatabaseLoad  `json:"databases"`
+	Moves     []*RebalanceMove `json:"moves"`
+}
+
+type DatabaseLoad struct {
+	Database     string `json:"database"`
+	StorageSize  int64  `json:"storageSize"`
+	IngestNumber int64  `json:"ingestNumber"` // logging number of recent 2 days
+	Modules      int    `json:"modules"`
+	Weight       int    `json:"weight"`
+}
+
+// RebalanceMove update module database to target, then migrate history shards by migration job
+type RebalanceMove struct {
+	ModuleName   string `json:"moduleName"`
+	FromDatabase string `json:"fromDatabase"`
+	ToDatabase   string `json:"toDatabase"`
+	StorageSize  int64  `json:"storageSize"`
+	IngestNumber int64  `json:"ingestNumber"`
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CNShardCatalog = "shard_catalog"
+)
+
+// Shard catalog of logging shard collection, registered by receiver before collection created.
+// time range fixed when registered, module day span, prefix or database changed later not affect it.
+// shard migrated, reads routed to target database, source copy kept as migrated until dropped
+type Shard struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	ModuleName     string             `bson:"module_name"`
+	Database       string             `bson:"database"`
+	Collection     string             `bson:"collection"`
+	BeginTsSec     int64              `bson:"begin_ts"`
+	EndTsSec       int64              `bson:"end_ts"`      // exclusive
+	MigratedTo     string             `bson:"migrated_to"` // not empty, stale copy not read
+	Count          int64              `bson:"count"`
+	Size           int64              `bson:"size"`
+	StatsUpdatedAt time.Time          `bson:"stats_updated_at"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
+
+func (Shard) CollectionName() string {
+	return CNShardCatalog
+}
+
+func (s Shard) Readable() bool {
+	return s.MigratedTo == ""
+}
+
+// NewShard shard of module collection, time range by module collection naming rule
+func NewShard(m *Module, dbName, cName string) (*Shard, error) {
+	b, e, err := ShardTimeRange(m.Prefix, m.DaySpan, cName)
+	if err != nil {
+		return nil, err
+	}
+	return &Shard{
+		ModuleName: m.Name,
+		Database:   dbName,
+		Collection: cName,
+		BeginTsSec: b.Unix(),
+		EndTsSec:   e.Unix(),
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// ShardTimeRange time range of collection named by prefix and day span, end exclusive
+func ShardTimeRange(prefix string, daySpan int, cName string) (begin, end time.Time, err error) {
+	sc := mongo.NewShardCollection(prefix, daySpan)
+	// decode not check year month length
+	if parts := strings.Split(cName, sc.Sep); len(parts) != 4 || len(parts[2]) != 6 {
+		return begin, end, fmt.Errorf("invalid shard collection name %s", cName)
+	}
+	_, _, year, month, span, err := sc.DecodeCollName(cName)
+	if err != nil {
+		return begin, end, err
+	}
+	first, last := 0, 0
+	for d, s := range sc.DaySpan() {
+		if s != span {
+			continue
+		}
+		if first == 0 || d < first {
+			first = d
+		}
+		if d > last {
+			last = d
+		}
+	}
+	if year <= 0 || month < time.January || month > time.December || first == 0 {
+		return begin, end, fmt.Errorf("invalid shard collection name %s", cName)
+	}
+	begin = time.Date(year, month, first, 0, 0, 0, 0, time.Local)
+	end = time.Date(year, month, last+1, 0, 0, 0, 0, time.Local)
+	// span days over end of month
+	if next := time.Date(year, month+1, 1, 0, 0, 0, 0, time.Local); end.After(next) {
+		end = next
+	}
+	return begin, end, nil
+}
+
+func ShardCatalogIndexMany() []mongo.Index {
+	return []mongo.Index{
+		{
+			Collection: CNShardCatalog,
+			Keys: bson.D{
+				{
+					Key: "module_name", Value: 1,
+				},
+				{
+					Key: "database", Value: 1,
+				},
+				{
+					Key: "collection", Value: 1,
+				},
+			},
+			Unique:     true,
+			Background: true,
+		},
+		{
+			Collection: CNShardCatalog,
+			Keys: bson.D{
+				{
+					Key: "module_name", Value: 1,
+				},
+				{
+					Key: "begin_ts", Value: 1,
+				},
+			},
+			Background: true,
+		},
+	}
+}
@@ -0,0 +1,18 @@
+package model
+
+type FindShardListReq struct {
+	ModuleName string `json:"moduleName" form:"moduleName" binding:"required"`
+	TimeReq
+}
+
+type FindShardList struct {
+	Database       string `json:"database"`
+	Collection     string `json:"collection"`
+	BeginTsSec     int64  `json:"beginTsSec"`
+	EndTsSec       int64  `json:"endTsSec"`
+	MigratedTo     string `json:"migratedTo"`
+	Count          int64  `json:"count"`
+	Size           int64  `json:"size"`
+	StatsUpdatedTs int64  `json:"statsUpdatedTsSec"`
+	CreatedTsSec   int64  `json:"createdTsSec"`
+}
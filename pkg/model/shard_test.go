@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShardTimeRange(t *testing.T) {
+	cases := []struct {
+		daySpan    int
+		cName      string
+		begin, end time.Time
+	}{
+		{
+			daySpan: 0, cName: "lg_abc_202102_01",
+			begin: time.Date(2021, 2, 1, 0, 0, 0, 0, time.Local),
+			end:   time.Date(2021, 3, 1, 0, 0, 0, 0, time.Local),
+		},
+		{
+			daySpan: 7, cName: "lg_abc_202103_02",
+			begin: time.Date(2021, 3, 8, 0, 0, 0, 0, time.Local),
+			end:   time.Date(2021, 3, 15, 0, 0, 0, 0, time.Local),
+		},
+		{
+			// last span over end of month
+			daySpan: 10, cName: "lg_abc_202102_03",
+			begin: time.Date(2021, 2, 21, 0, 0, 0, 0, time.Local),
+			end:   time.Date(2021, 3, 1, 0, 0, 0, 0, time.Local),
+		},
+	}
+	for _, c := range cases {
+		b, e, err := ShardTimeRange("lg", c.daySpan, c.cName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.Equal(c.begin) || !e.Equal(c.end) {
+			t.Fatalf("%s expect %s - %s, got %s - %s", c.cName, c.begin, c.end, b, e)
+		}
+	}
+
+	for _, v := range []string{"lg_abc_2021_01", "lg_abc_202113_01", "lg_abc_202101_09", "lg_abc"} {
+		if _, _, err := ShardTimeRange("lg", 7, v); err == nil {
+			t.Fatalf("%s expect invalid", v)
+		}
+	}
+}
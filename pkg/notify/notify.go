@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/bbdshow/bkit/util/alert"
+	"github.com/bbdshow/qelog/pkg/model"
+)
+
+// Notifier alarm method, content sent to hook
+type Notifier interface {
+	Send(ctx context.Context, content string) error
+	Method() string
+}
+
+// Factory notifier of hook, hook address or template invalid returns error
+type Factory func(hook *model.HookURL) (Notifier, error)
+
+var (
+	mutex    sync.RWMutex
+	registry = map[model.Method]Factory{}
+)
+
+func init() {
+	Register(model.MethodDingDing, func(hook *model.HookURL) (Notifier, error) {
+		v := alert.NewDingDing()
+		v.SetHookURL(hook.URL)
+		return v, nil
+	})
+	Register(model.MethodTelegram, func(hook *model.HookURL) (Notifier, error) {
+		v := alert.NewTelegram()
+		v.SetHookURL(hook.URL)
+		return v, nil
+	})
+	Register(model.MethodWebhook, NewWebhook)
+	Register(model.MethodSlack, NewSlack)
+	Register(model.MethodEmail, NewEmail)
+	Register(model.MethodFeishu, NewFeishu)
+	Register(model.MethodWeCom, NewWeCom)
+}
+
+// Register alarm method factory, registered before replaced
+func Register(method model.Method, f Factory) {
+	mutex.Lock()
+	registry[method] = f
+	mutex.Unlock()
+}
+
+// Registered alarm method supported
+func Registered(method model.Method) bool {
+	mutex.RLock()
+	_, ok := registry[method]
+	mutex.RUnlock()
+	return ok
+}
+
+// Methods registered alarm methods in order
+func Methods() []model.Method {
+	mutex.RLock()
+	methods := make([]model.Method, 0, len(registry))
+	for k := range registry {
+		methods = append(methods, k)
+	}
+	mutex.RUnlock()
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i] < methods[j]
+	})
+	return methods
+}
+
+// New notifier of method, hook nil as empty address
+func New(method model.Method, hook *model.HookURL) (Notifier, error) {
+	mutex.RLock()
+	f, ok := registry[method]
+	mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("alarm method %d not supported", method)
+	}
+	if hook == nil {
+		hook = &model.HookURL{}
+	}
+	return f(hook)
+}
+
+// postJSON body posted, response status not 2xx returns error, body decoded to resp if not nil
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, resp interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return fmt.Errorf("status %s %s", r.Status, bytes.TrimSpace(b))
+	}
+	if resp != nil {
+		if err := json.Unmarshal(b, resp); err != nil {
+			return fmt.Errorf("response %s %v", bytes.TrimSpace(b), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bbdshow/qelog/pkg/model"
+)
+
+// robot chat group incoming webhook, text message
+type robot struct {
+	method model.Method
+	url    string
+	client *http.Client
+	// message body of content
+	body func(content string) interface{}
+	// response body error, nil not checked
+	check func(b []byte) error
+}
+
+func newRobot(method model.Method, hook *model.HookURL, body func(string) interface{}, check func([]byte) error) (Notifier, error) {
+	if hook.URL == "" {
+		return nil, fmt.Errorf("%s hook url required", method.String())
+	}
+	return &robot{method: method, url: hook.URL, client: &http.Client{}, body: body, check: check}, nil
+}
+
+// NewSlack slack incoming webhook, https://hooks.slack.com/services/xxx
+func NewSlack(hook *model.HookURL) (Notifier, error) {
+	return newRobot(model.MethodSlack, hook, func(content string) interface{} {
+		return map[string]string{"text": content}
+	}, nil)
+}
+
+// NewFeishu feishu or lark custom bot, https://open.feishu.cn/open-apis/bot/v2/hook/xxx
+func NewFeishu(hook *model.HookURL) (Notifier, error) {
+	return newRobot(model.MethodFeishu, hook, func(content string) interface{} {
+		return map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": content},
+		}
+	}, func(b []byte) error {
+		resp := struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}{}
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return err
+		}
+		if resp.Code != 0 {
+			return fmt.Errorf("%d %s", resp.Code, resp.Msg)
+		}
+		return nil
+	})
+}
+
+// NewWeCom wecom group robot, https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx
+func NewWeCom(hook *model.HookURL) (Notifier, error) {
+	return newRobot(model.MethodWeCom, hook, func(content string) interface{} {
+		return map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": content},
+		}
+	}, func(b []byte) error {
+		resp := struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}{}
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return err
+		}
+		if resp.ErrCode != 0 {
+			return fmt.Errorf("%d %s", resp.ErrCode, resp.ErrMsg)
+		}
+		return nil
+	})
+}
+
+func (r *robot) Send(ctx context.Context, content string) error {
+	body, err := json.Marshal(r.body(content))
+	if err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	var resp interface{}
+	if r.check != nil {
+		resp = &raw
+	}
+	if err := postJSON(ctx, r.client, r.url, body, resp); err != nil {
+		return fmt.Errorf("%s method error %v", r.Method(), err)
+	}
+	if r.check != nil {
+		if err := r.check(raw); err != nil {
+			return fmt.Errorf("%s method error %v", r.Method(), err)
+		}
+	}
+	return nil
+}
+
+func (r *robot) Method() string {
+	return r.method.String()
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/bbdshow/qelog/pkg/model"
+)
+
+// DefaultWebhookBody webhook body template not set
+const DefaultWebhookBody = `{"content":{{json .Content}}}`
+
+// WebhookData data of webhook body template
+type WebhookData struct {
+	Name    string
+	KeyWord string
+	Content string
+}
+
+var webhookFuncs = template.FuncMap{
+	// json string or value quoted and escaped as json
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Webhook generic json webhook, request body rendered by hook body template
+type Webhook struct {
+	url    string
+	name   string
+	key    string
+	body   *template.Template
+	client *http.Client
+}
+
+func NewWebhook(hook *model.HookURL) (Notifier, error) {
+	if hook.URL == "" {
+		return nil, fmt.Errorf("webhook url required")
+	}
+	text := hook.BodyTemplate
+	if text == "" {
+		text = DefaultWebhookBody
+	}
+	tpl, err := template.New("body").Funcs(webhookFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook body template %v", err)
+	}
+	w := &Webhook{url: hook.URL, name: hook.Name, key: hook.KeyWord, body: tpl, client: &http.Client{}}
+	// rendered body must be json
+	if _, err := w.render(`sample "content"`); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Webhook) render(content string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := w.body.Execute(buf, WebhookData{Name: w.name, KeyWord: w.key, Content: content}); err != nil {
+		return nil, fmt.Errorf("webhook body template %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook body template rendered not json")
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *Webhook) Send(ctx context.Context, content string) error {
+	body, err := w.render(content)
+	if err != nil {
+		return err
+	}
+	if err := postJSON(ctx, w.client, w.url, body, nil); err != nil {
+		return fmt.Errorf("%s method error %v", w.Method(), err)
+	}
+	return nil
+}
+
+func (w *Webhook) Method() string {
+	return model.Method(model.MethodWebhook).String()
+}
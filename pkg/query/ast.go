@@ -0,0 +1,82 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node query syntax tree node
+type Node interface {
+	Pos() int
+	String() string
+}
+
+// BinaryExpr AND OR expression
+type BinaryExpr struct {
+	Op    string // AND | OR
+	Left  Node
+	Right Node
+	pos   int
+}
+
+func (e *BinaryExpr) Pos() int { return e.pos }
+
+func (e *BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+// NotExpr negate expression
+type NotExpr struct {
+	X   Node
+	pos int
+}
+
+func (e *NotExpr) Pos() int { return e.pos }
+
+func (e *NotExpr) String() string {
+	return fmt.Sprintf("NOT %s", e.X)
+}
+
+// Value literal value, keep position used to report error
+type Value struct {
+	Text string
+	Pos  int
+}
+
+// Comparison field compared with values. Op: = != ~ !~ > >= < <= IN NOT_IN
+type Comparison struct {
+	Field  string
+	Op     string
+	Values []Value
+	pos    int
+}
+
+func (c *Comparison) Pos() int { return c.pos }
+
+func (c *Comparison) String() string {
+	if c.Op == OpIn || c.Op == OpNotIn {
+		vs := make([]string, 0, len(c.Values))
+		for _, v := range c.Values {
+			vs = append(vs, fmt.Sprintf("%q", v.Text))
+		}
+		return fmt.Sprintf("%s %s (%s)", c.Field, strings.Replace(c.Op, "_", " ", 1), strings.Join(vs, ", "))
+	}
+	return fmt.Sprintf("%s%s%q", c.Field, c.Op, c.Values[0].Text)
+}
+
+const (
+	OpAnd   = "AND"
+	OpOr    = "OR"
+	OpIn    = "IN"
+	OpNotIn = "NOT_IN"
+)
+
+// Error query parse or validate error, Pos byte offset in query text
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
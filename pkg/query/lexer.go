@@ -0,0 +1,158 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of query"
+	case tokIdent:
+		return "identifier"
+	case tokString:
+		return "string"
+	case tokOp:
+		return "operator"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokComma:
+		return "','"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	case tokIn:
+		return "IN"
+	}
+	return "unknown"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // byte offset in query text
+}
+
+var keywords = map[string]tokenKind{
+	"AND": tokAnd,
+	"OR":  tokOr,
+	"NOT": tokNot,
+	"IN":  tokIn,
+}
+
+// lexer split query text to tokens
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && isSpace(l.src[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case c == ',':
+		l.pos++
+		return token{kind: tokComma, text: ",", pos: start}, nil
+	case c == '"' || c == '\'':
+		return l.quoted(c)
+	case strings.IndexByte("=!~<>", c) >= 0:
+		return l.operator()
+	case isIdent(c):
+		for l.pos < len(l.src) && isIdent(l.src[l.pos]) {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		if k, ok := keywords[strings.ToUpper(text)]; ok {
+			return token{kind: k, text: text, pos: start}, nil
+		}
+		return token{kind: tokIdent, text: text, pos: start}, nil
+	}
+	return token{}, &Error{Pos: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+func (l *lexer) operator() (token, error) {
+	start := l.pos
+	for _, op := range []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, &Error{Pos: start, Msg: fmt.Sprintf("unexpected character %q", l.src[start])}
+}
+
+// quoted string support escape \" \' \\
+func (l *lexer) quoted(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+	b := strings.Builder{}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, &Error{Pos: l.pos, Msg: "unterminated escape"}
+			}
+			next := l.src[l.pos+1]
+			if next != quote && next != '\\' {
+				// keep regex escape as is, eg \d
+				b.WriteByte(c)
+			}
+			b.WriteByte(next)
+			l.pos += 2
+			continue
+		case quote:
+			l.pos++
+			return token{kind: tokString, text: b.String(), pos: start}, nil
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return token{}, &Error{Pos: start, Msg: "unterminated string"}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isIdent bare word, non-ASCII bytes as part of word
+func isIdent(c byte) bool {
+	return c >= utf8.RuneSelf || c == '_' || c == '-' || c == '.' || c == ':' ||
+		unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
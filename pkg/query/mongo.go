@@ -0,0 +1,80 @@
+package query
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Compile validate syntax tree and compile to mongo filter
+func Compile(node Node) (bson.M, *Analysis, error) {
+	a, err := Validate(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	return toMongo(node), a, nil
+}
+
+func toMongo(node Node) bson.M {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		op := "$and"
+		if n.Op == OpOr {
+			op = "$or"
+		}
+		// flatten same operator chain
+		conds := make(bson.A, 0, 2)
+		for _, x := range flatten(n, n.Op) {
+			conds = append(conds, toMongo(x))
+		}
+		return bson.M{op: conds}
+	case *NotExpr:
+		return bson.M{"$nor": bson.A{toMongo(n.X)}}
+	case *Comparison:
+		return comparison(n)
+	}
+	return bson.M{}
+}
+
+func flatten(node Node, op string) []Node {
+	if n, ok := node.(*BinaryExpr); ok && n.Op == op {
+		return append(flatten(n.Left, op), flatten(n.Right, op)...)
+	}
+	return []Node{node}
+}
+
+func comparison(c *Comparison) bson.M {
+	f := fields[c.Field]
+	values := make(bson.A, 0, len(c.Values))
+	for _, v := range c.Values {
+		// validated, ignore error
+		val, _ := fieldValue(f, c.Op, v)
+		values = append(values, val)
+	}
+	if f.key == textKey {
+		return bson.M{textKey: bson.M{"$search": values[0]}}
+	}
+	var cond interface{}
+	switch c.Op {
+	case "=":
+		cond = values[0]
+	case "!=":
+		cond = bson.M{"$ne": values[0]}
+	case "~":
+		cond = primitive.Regex{Pattern: c.Values[0].Text, Options: "i"}
+	case "!~":
+		cond = bson.M{"$not": primitive.Regex{Pattern: c.Values[0].Text, Options: "i"}}
+	case ">":
+		cond = bson.M{"$gt": values[0]}
+	case ">=":
+		cond = bson.M{"$gte": values[0]}
+	case "<":
+		cond = bson.M{"$lt": values[0]}
+	case "<=":
+		cond = bson.M{"$lte": values[0]}
+	case OpIn:
+		cond = bson.M{"$in": values}
+	case OpNotIn:
+		cond = bson.M{"$nin": values}
+	}
+	return bson.M{f.key: cond}
+}
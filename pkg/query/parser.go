@@ -0,0 +1,198 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxQueryLength query text length limit
+const MaxQueryLength = 4096
+
+// Parse query text to syntax tree
+//
+//	expr       = and { OR and }
+//	and        = unary { AND unary }
+//	unary      = NOT unary | "(" expr ")" | comparison
+//	comparison = field op value | field [NOT] IN "(" value { "," value } ")"
+//	op         = "=" | "!=" | "~" | "!~" | ">" | ">=" | "<" | "<="
+func Parse(src string) (Node, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &Error{Pos: 0, Msg: "empty query"}
+	}
+	if len(src) > MaxQueryLength {
+		return nil, &Error{Pos: MaxQueryLength, Msg: fmt.Sprintf("query length exceeded %d", MaxQueryLength)}
+	}
+	p := &parser{lex: &lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected("AND, OR or end of query")
+	}
+	return node, nil
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+	// nested parentheses depth
+	depth int
+}
+
+const maxDepth = 32
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected(want string) error {
+	got := p.tok.kind.String()
+	if p.tok.kind != tokEOF {
+		got = fmt.Sprintf("%s %q", got, p.tok.text)
+	}
+	return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf("expected %s, found %s", want, got)}
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: OpOr, Left: left, Right: right, pos: pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd {
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: OpAnd, Left: left, Right: right, pos: pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	switch p.tok.kind {
+	case tokNot:
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{X: x, pos: pos}, nil
+	case tokLParen:
+		if p.depth++; p.depth > maxDepth {
+			return nil, &Error{Pos: p.tok.pos, Msg: "too many nested parentheses"}
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.unexpected("')'")
+		}
+		p.depth--
+		return x, p.advance()
+	case tokIdent:
+		return p.parseComparison()
+	}
+	return nil, p.unexpected("field, NOT or '('")
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	c := &Comparison{Field: strings.ToLower(p.tok.text), pos: p.tok.pos}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	switch p.tok.kind {
+	case tokOp:
+		c.Op = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c.Values = []Value{v}
+		return c, nil
+	case tokNot:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokIn {
+			return nil, p.unexpected("IN")
+		}
+		c.Op = OpNotIn
+	case tokIn:
+		c.Op = OpIn
+	default:
+		return nil, p.unexpected("operator")
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokLParen {
+		return nil, p.unexpected("'('")
+	}
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c.Values = append(c.Values, v)
+		if p.tok.kind == tokRParen {
+			return c, p.advance()
+		}
+		if p.tok.kind != tokComma {
+			return nil, p.unexpected("',' or ')'")
+		}
+	}
+}
+
+func (p *parser) parseValue() (Value, error) {
+	if p.tok.kind != tokString && p.tok.kind != tokIdent {
+		return Value{}, p.unexpected("value")
+	}
+	v := Value{Text: p.tok.text, Pos: p.tok.pos}
+	return v, p.advance()
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		Query  string
+		String string
+	}{
+		{Query: `level>=WARN`, String: `level>="WARN"`},
+		{Query: `level>=WARN AND c1="order" AND full~"timeout"`, String: `((level>="WARN" AND c1="order") AND full~"timeout")`},
+		{Query: `ip IN ("10.0.0.1", 10.0.0.2)`, String: `ip IN ("10.0.0.1", "10.0.0.2")`},
+		{Query: `a=1 OR b=2 AND c=3`, String: `(a="1" OR (b="2" AND c="3"))`},
+		{Query: `(a=1 OR b=2) and not c!=3`, String: `((a="1" OR b="2") AND NOT c!="3")`},
+		{Query: `ip not in ('a', 'b')`, String: `ip NOT IN ("a", "b")`},
+		{Query: `short~"say \"hi\" \d+"`, String: `short~"say \"hi\" \\d+"`},
+		{Query: `short="订单 失败"`, String: `short="订单 失败"`},
+	}
+	for i, v := range testCases {
+		node, err := Parse(v.Query)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if node.String() != v.String {
+			t.Fatalf("case %d: %s, want %s", i, node.String(), v.String)
+		}
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	testCases := []struct {
+		Query string
+		Pos   int
+	}{
+		{Query: ``, Pos: 0},
+		{Query: `level>=`, Pos: 7},
+		{Query: `level WARN`, Pos: 6},
+		{Query: `level=WARN AND`, Pos: 14},
+		{Query: `(level=WARN`, Pos: 11},
+		{Query: `short="abc`, Pos: 6},
+		{Query: `ip IN ("a" "b")`, Pos: 11},
+		{Query: `ip NOT ("a")`, Pos: 7},
+		{Query: `level=WARN )`, Pos: 11},
+		{Query: `level=WARN & c1=a`, Pos: 11},
+	}
+	for i, v := range testCases {
+		_, err := Parse(v.Query)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("case %d: should be parse error, but %v", i, err)
+		}
+		if e.Pos != v.Pos {
+			t.Fatalf("case %d: pos %d, want %d, %v", i, e.Pos, v.Pos, e)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		Query    string
+		Pos      int // -1 valid
+		Indexed  bool
+		FullText bool
+	}{
+		{Query: `level>=WARN AND c1="order" AND full~"timeout"`, Pos: -1, Indexed: true},
+		{Query: `full~"timeout" AND ip IN ("a","b")`, Pos: -1, Indexed: false},
+		{Query: `level=ERROR OR ip="a"`, Pos: -1, Indexed: false},
+		{Query: `level=ERROR OR traceid="a"`, Pos: -1, Indexed: true},
+		{Query: `NOT level=ERROR`, Pos: -1, Indexed: false},
+		{Query: `text="timeout" AND c2="x"`, Pos: -1, Indexed: true, FullText: true},
+		{Query: `unknown="x"`, Pos: 0},
+		{Query: `level=VERBOSE`, Pos: 6},
+		{Query: `level=9`, Pos: 6},
+		{Query: `full>"a"`, Pos: 0},
+		{Query: `short~"(a"`, Pos: 6},
+		{Query: `level=ERROR OR text="a"`, Pos: 15},
+		{Query: `text="a" AND text="b"`, Pos: 13},
+	}
+	for i, v := range testCases {
+		node, err := Parse(v.Query)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		a, err := Validate(node)
+		if v.Pos >= 0 {
+			e, ok := err.(*Error)
+			if !ok || e.Pos != v.Pos {
+				t.Fatalf("case %d: error %v, want position %d", i, err, v.Pos)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if a.Indexed != v.Indexed || a.FullText != v.FullText {
+			t.Fatalf("case %d: analysis %+v", i, a)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	node, err := Parse(`level>=WARN AND c1="order" AND (full~"timeout" OR ip NOT IN ("a")) AND NOT s!="x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, _, err := Compile(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{"$and": bson.A{
+		bson.M{"l": bson.M{"$gte": int32(1)}},
+		bson.M{"c1": "order"},
+		bson.M{"$or": bson.A{
+			bson.M{"f": primitive.Regex{Pattern: "timeout", Options: "i"}},
+			bson.M{"ip": bson.M{"$nin": bson.A{"a"}}},
+		}},
+		bson.M{"$nor": bson.A{bson.M{"s": bson.M{"$ne": "x"}}}},
+	}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Fatalf("filter %v, want %v", filter, want)
+	}
+}
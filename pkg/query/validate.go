@@ -0,0 +1,171 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/types"
+)
+
+const (
+	// maxInValues IN operator max values
+	maxInValues = 100
+	// maxRegexLength regex pattern length limit, avoid expensive pattern
+	maxRegexLength = 256
+	// textKey full-text search pseudo field
+	textKey = "$text"
+)
+
+var (
+	equalOps  = []string{"=", "!=", OpIn, OpNotIn}
+	stringOps = append([]string{"~", "!~"}, equalOps...)
+	levelOps  = append([]string{">", ">=", "<", "<="}, equalOps...)
+)
+
+type field struct {
+	key string // logging document bson key
+	ops []string
+}
+
+// fields query field name or alias, map to model.Logging bson key
+var fields = map[string]field{
+	"level":   {key: "l", ops: levelOps},
+	"l":       {key: "l", ops: levelOps},
+	"short":   {key: "s", ops: stringOps},
+	"s":       {key: "s", ops: stringOps},
+	"full":    {key: "f", ops: stringOps},
+	"f":       {key: "f", ops: stringOps},
+	"ip":      {key: "ip", ops: stringOps},
+	"c1":      {key: "c1", ops: stringOps},
+	"c2":      {key: "c2", ops: stringOps},
+	"c3":      {key: "c3", ops: stringOps},
+	"traceid": {key: "ti", ops: []string{"=", OpIn}},
+	"ti":      {key: "ti", ops: []string{"=", OpIn}},
+	"text":    {key: textKey, ops: []string{"="}},
+}
+
+// indexedKeys keys can be filtered by index, from logging index layout. m, ts always required by query
+var indexedKeys = func() map[string]bool {
+	keys := map[string]bool{textKey: true}
+	for _, index := range model.LoggingIndexMany("") {
+		for _, k := range index.Keys {
+			if k.Key != "m" && k.Key != "ts" {
+				keys[k.Key] = true
+			}
+		}
+	}
+	return keys
+}()
+
+// Analysis validated query info
+type Analysis struct {
+	// Indexed at least one condition filtered by index, otherwise only time range bounded, scan all documents
+	Indexed bool
+	// FullText query used full-text search, module text index required
+	FullText bool
+}
+
+// Validate check fields, operators, values, and analysis index used
+func Validate(node Node) (*Analysis, error) {
+	a := &Analysis{}
+	if err := validate(node, true, a); err != nil {
+		return nil, err
+	}
+	a.Indexed = indexed(node)
+	return a, nil
+}
+
+// validate topAnd: node in top level AND chain, full-text search only allowed there
+func validate(node Node, topAnd bool, a *Analysis) error {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		and := topAnd && n.Op == OpAnd
+		if err := validate(n.Left, and, a); err != nil {
+			return err
+		}
+		return validate(n.Right, and, a)
+	case *NotExpr:
+		return validate(n.X, false, a)
+	case *Comparison:
+		f, ok := fields[n.Field]
+		if !ok {
+			return &Error{Pos: n.pos, Msg: fmt.Sprintf("unknown field %q", n.Field)}
+		}
+		if !contains(f.ops, n.Op) {
+			return &Error{Pos: n.pos, Msg: fmt.Sprintf("field %q not support operator %s", n.Field, n.Op)}
+		}
+		if len(n.Values) > maxInValues {
+			return &Error{Pos: n.Values[maxInValues].Pos, Msg: fmt.Sprintf("IN values exceeded %d", maxInValues)}
+		}
+		if f.key == textKey {
+			if !topAnd {
+				return &Error{Pos: n.pos, Msg: "text search only allowed in top level AND condition"}
+			}
+			if a.FullText {
+				return &Error{Pos: n.pos, Msg: "text search only allowed once"}
+			}
+			a.FullText = true
+		}
+		for _, v := range n.Values {
+			if _, err := fieldValue(f, n.Op, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return &Error{Pos: node.Pos(), Msg: "unknown expression"}
+}
+
+// indexed AND any branch indexed, OR all branch indexed
+func indexed(node Node) bool {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		if n.Op == OpAnd {
+			return indexed(n.Left) || indexed(n.Right)
+		}
+		return indexed(n.Left) && indexed(n.Right)
+	case *Comparison:
+		f := fields[n.Field]
+		switch n.Op {
+		case "=", OpIn, ">", ">=", "<", "<=":
+			return indexedKeys[f.key]
+		}
+	}
+	// negate and regex can not use index bounds
+	return false
+}
+
+// fieldValue convert value to bson value by field type
+func fieldValue(f field, op string, v Value) (interface{}, error) {
+	switch {
+	case f.key == "l":
+		lvl := types.String2Level(v.Text)
+		if lvl == -2 {
+			i, err := strconv.Atoi(v.Text)
+			if err != nil || i < -1 || i > 5 {
+				return nil, &Error{Pos: v.Pos, Msg: fmt.Sprintf("invalid level %q", v.Text)}
+			}
+			lvl = types.Level(i)
+		}
+		return lvl.Int32(), nil
+	case op == "~" || op == "!~":
+		if len(v.Text) > maxRegexLength {
+			return nil, &Error{Pos: v.Pos, Msg: fmt.Sprintf("regex length exceeded %d", maxRegexLength)}
+		}
+		if _, err := regexp.Compile(v.Text); err != nil {
+			return nil, &Error{Pos: v.Pos, Msg: fmt.Sprintf("invalid regex %v", err)}
+		}
+	}
+	return v.Text, nil
+}
+
+func contains(ops []string, op string) bool {
+	for _, v := range ops {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
@@ -2,16 +2,16 @@ package alarm
 
 import (
 	"context"
-	"fmt"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/bbdshow/bkit/logs"
-	"github.com/bbdshow/bkit/util/alert"
 	"github.com/bbdshow/bkit/util/inet"
 	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/notify"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
 
@@ -20,19 +20,51 @@ var (
 	machineIP, _  = inet.GetLocalIPV4()
 )
 
+// CounterStore window count of rules, shared by all receivers
+type CounterStore interface {
+	IncrAlarmCounter(ctx context.Context, ruleID string, bucketTs, hits, total int64) error
+	SumAlarmCounter(ctx context.Context, ruleID string, beginTs, endTs int64) (int64, int64, error)
+}
+
+// LeaseStore send lease of rules, one receiver send in rate window, hits of all receivers merged
+type LeaseStore interface {
+	IncrAlarmLease(ctx context.Context, ruleID string, hits int64) error
+	AcquireAlarmLease(ctx context.Context, ruleID, owner, node string, hits, leaseSec int64) (bool, int64, error)
+}
+
+// Store alarm state shared by all receivers
+type Store interface {
+	CounterStore
+	LeaseStore
+}
+
 type Alarm struct {
 	mutex     sync.RWMutex
 	ruleState map[string]*RuleState
-	hooks     map[string]*model.HookURL
-	modules   map[string]bool
+	// not exact rules of module, matched by compiled matcher
+	patterns map[string][]*RuleState
+	// windowed rules of module, every logging of module counted as total
+	windows map[string][]*RuleState
+	// nil, windowed rules not evaluated, rules sent by every receiver
+	store Store
+	// lease owner of this receiver
+	owner string
+	// admin external url, logging link of message
+	adminURL string
+	hooks    map[string]*model.HookURL
+	modules  map[string]bool
 	// hide some text
 	hideTexts []string
 }
 
-func NewAlarm() *Alarm {
+func NewAlarm(store Store) *Alarm {
 	a := &Alarm{
 		mutex:     sync.RWMutex{},
 		ruleState: make(map[string]*RuleState, 0),
+		patterns:  make(map[string][]*RuleState, 0),
+		windows:   make(map[string][]*RuleState, 0),
+		store:     store,
+		owner:     primitive.NewObjectID().Hex(),
 		hooks:     make(map[string]*model.HookURL, 0),
 		modules:   make(map[string]bool),
 		hideTexts: make([]string, 0),
@@ -48,6 +80,13 @@ func (a *Alarm) AddHideText(txt []string) {
 	}
 }
 
+// SetAdminURL admin address reachable by alarm receiver, link of logging in message
+func (a *Alarm) SetAdminURL(url string) {
+	a.mutex.Lock()
+	a.adminURL = url
+	a.mutex.Unlock()
+}
+
 // ModuleIsEnable check module alarm is enable
 func (a *Alarm) ModuleIsEnable(name string) bool {
 	a.mutex.RLock()
@@ -56,13 +95,89 @@ func (a *Alarm) ModuleIsEnable(name string) bool {
 	return ok && enable
 }
 
+// IsAlarm exact rule found by logging key, not exact rules of module matched one by one
 func (a *Alarm) IsAlarm(docs []*model.Logging) {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 	for _, v := range docs {
+		for _, state := range a.windows[v.Module] {
+			atomic.AddInt64(&state.total, 1)
+		}
 		state, ok := a.ruleState[v.Key()]
-		if ok {
-			state.Send(v)
+		if ok && state.exact {
+			state.Hit(v)
+		}
+		patterns := a.patterns[v.Module]
+		if len(patterns) == 0 {
+			continue
+		}
+		full := NewPayload(v.Full)
+		for _, state := range patterns {
+			if state.matcher.Match(v, full) {
+				state.Hit(v)
+			}
+		}
+	}
+}
+
+// EvalWindow windowed rules count of this receiver flushed to bucket of shared counter,
+// then window summed of all receivers, condition reached send.
+// only completed buckets summed, current bucket still flushed by other receivers
+func (a *Alarm) EvalWindow(ctx context.Context) {
+	a.evalWindow(ctx, time.Now())
+}
+
+func (a *Alarm) evalWindow(ctx context.Context, now time.Time) {
+	if a.store == nil {
+		return
+	}
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	bucket := now.Unix() / model.AlarmCounterStepSec * model.AlarmCounterStepSec
+	for _, states := range a.windows {
+		for _, state := range states {
+			ruleID := state.rule.ID.Hex()
+			hits, total := atomic.SwapInt64(&state.hits, 0), atomic.SwapInt64(&state.total, 0)
+			if hits > 0 || total > 0 {
+				if err := a.store.IncrAlarmCounter(ctx, ruleID, bucket, hits, total); err != nil {
+					// counted next time
+					atomic.AddInt64(&state.hits, hits)
+					atomic.AddInt64(&state.total, total)
+					logs.Qezap.Error("AlarmWindow", zap.String("key", state.key), zap.Error(err))
+					continue
+				}
+			}
+			// completed buckets of window, current excluded
+			hits, total, err := a.store.SumAlarmCounter(ctx, ruleID, bucket-state.rule.WindowSec, bucket)
+			if err != nil {
+				logs.Qezap.Error("AlarmWindow", zap.String("key", state.key), zap.Error(err))
+				continue
+			}
+			if state.Reached(hits, total, now) {
+				state.SendWindow(hits, total)
+			}
+		}
+	}
+}
+
+// FlushHits hits of receiver not sent merged to lease, sent by lease owner
+func (a *Alarm) FlushHits(ctx context.Context) {
+	if a.store == nil {
+		return
+	}
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	for _, state := range a.ruleState {
+		if state.windowed || state.rateSec() <= 0 {
+			continue
+		}
+		hits := atomic.SwapInt32(&state.count, 0)
+		if hits <= 0 {
+			continue
+		}
+		if err := a.store.IncrAlarmLease(ctx, state.rule.ID.Hex(), int64(hits)); err != nil {
+			atomic.AddInt32(&state.count, hits)
+			logs.Qezap.Error("AlarmLease", zap.String("key", state.key), zap.Error(err))
 		}
 	}
 }
@@ -88,18 +203,58 @@ func (a *Alarm) InitRuleState(rules []*model.AlarmRule, hooks []*model.HookURL)
 		}
 	}
 
+	patterns := make(map[string][]*RuleState)
+	windows := make(map[string][]*RuleState)
+	for _, state := range ruleState {
+		state.lease, state.owner = a.store, a.owner
+		state.adminURL = a.adminURL
+		if !state.exact && state.matcher != nil {
+			patterns[state.rule.ModuleName] = append(patterns[state.rule.ModuleName], state)
+		}
+		if state.windowed {
+			windows[state.rule.ModuleName] = append(windows[state.rule.ModuleName], state)
+		}
+	}
+
 	a.ruleState = ruleState
+	a.patterns = patterns
+	a.windows = windows
 	a.modules = modules
 	a.hooks = hooksMap
 }
 
 type RuleState struct {
-	key            string
-	hook           *model.HookURL
-	rule           *model.AlarmRule
+	key  string
+	hook *model.HookURL
+	rule *model.AlarmRule
+	// hits not merged to lease
 	count          int32
 	latestSendTime int64
-	method         alert.Alarm
+	method         notify.Notifier
+	exact          bool
+	matcher        Matcher
+	// windowed rule, matched and module logging count not flushed, latest matched logging
+	windowed bool
+	hits     int64
+	total    int64
+	latest   atomic.Value
+	loadedAt int64
+	// nil, sent without lease
+	lease LeaseStore
+	owner string
+	// message template of rule or hook, admin external url of logging link
+	tpl      *template.Template
+	adminURL string
+}
+
+// Hit matched logging, windowed rule counted and evaluated by window, other send limited by rate
+func (rs *RuleState) Hit(v *model.Logging) {
+	if rs.windowed {
+		atomic.AddInt64(&rs.hits, 1)
+		rs.latest.Store(v)
+		return
+	}
+	rs.Send(v)
 }
 
 func (rs *RuleState) Send(v *model.Logging) {
@@ -107,53 +262,143 @@ func (rs *RuleState) Send(v *model.Logging) {
 		return
 	}
 	atomic.AddInt32(&rs.count, 1)
-	isSend := false
-	if atomic.LoadInt64(&rs.latestSendTime) == 0 {
-		isSend = true
-	} else if time.Now().Unix()-atomic.LoadInt64(&rs.latestSendTime) > rs.rule.RateSec {
-		// over interval
-		isSend = true
-	}
-	if isSend {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		err := rs.method.Send(ctx, rs.parsingContent(v))
-		if err != nil {
-			logs.Qezap.Error("AlarmSend", zap.String(rs.method.Method(), err.Error()), zap.Any("content", rs.parsingContent(v)))
-		} else {
-			atomic.StoreInt32(&rs.count, 0)
-			// if interval time <= 0, send at once
-			latestSendTime := int64(0)
-			if rs.rule.RateSec > 0 {
-				latestSendTime = time.Now().Unix()
-			}
-			atomic.StoreInt64(&rs.latestSendTime, latestSendTime)
-		}
-	} else {
-		atomic.AddInt32(&rs.count, 1)
+	if !rs.allowSend() {
+		return
+	}
+	count := int64(atomic.SwapInt32(&rs.count, 0))
+	count, ok := rs.acquire(count)
+	if !ok {
+		return
+	}
+	if !rs.send(rs.parsingContent(v, count)) {
+		// not sent, counted next time
+		atomic.AddInt32(&rs.count, int32(count))
 	}
-	return
 }
 
-func (rs *RuleState) parsingContent(v *model.Logging) string {
-	str := fmt.Sprintf(`%s
-Tag: %s
-IP: %s
-Time: %s
-Level: %s
-Msg: %s
-Detial: %s
-Rate: %d/%ds
-ReportNode: %s`, rs.KeyWord(), rs.rule.Tag, v.IP, time.Unix(v.TimeSec, 0).Format("2006-01-02 15:04:05"), v.Level.String(),
-		v.Short, v.Full, atomic.LoadInt32(&rs.count), rs.rule.RateSec, machineIP)
+// Reached window count of all receivers reached condition.
+// absence evaluated after rule loaded one window, not alarm as soon as receiver started
+func (rs *RuleState) Reached(hits, total int64, now time.Time) bool {
+	switch rs.rule.AlarmCondition() {
+	case model.ConditionThreshold:
+		return float64(hits) >= rs.rule.Threshold
+	case model.ConditionRatio:
+		return total > 0 && float64(hits)*100/float64(total) >= rs.rule.Threshold
+	case model.ConditionAbsence:
+		// whole window of completed buckets counted after loaded
+		return hits == 0 && now.Unix()/model.AlarmCounterStepSec*model.AlarmCounterStepSec-rs.rule.WindowSec >= rs.loadedAt
+	}
+	return false
+}
 
-	// hide text
-	if rs.hook != nil {
-		for _, hide := range rs.hook.HideText {
-			str = strings.ReplaceAll(str, hide, "****")
-		}
+// SendWindow window condition reached, rate sec not set limited by window
+func (rs *RuleState) SendWindow(hits, total int64) {
+	if !rs.allowSend() {
+		return
+	}
+	// window summed, hits not merged
+	if _, ok := rs.acquire(0); ok {
+		rs.send(rs.parsingWindowContent(hits, total))
+	}
+}
+
+// acquire send lease of rule for rate sec, hits merged. sent at once or lease not used, local hits returned.
+// lease held by other receiver, not try again in rate sec
+func (rs *RuleState) acquire(hits int64) (int64, bool) {
+	rate := rs.rateSec()
+	if rs.lease == nil || rate <= 0 {
+		return hits, true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok, merged, err := rs.lease.AcquireAlarmLease(ctx, rs.rule.ID.Hex(), rs.owner, machineIP, hits, rate)
+	if err != nil {
+		logs.Qezap.Error("AlarmLease", zap.String("key", rs.key), zap.Error(err))
+		// hits not merged, send by receiver self
+		return hits, true
+	}
+	if !ok {
+		atomic.StoreInt64(&rs.latestSendTime, time.Now().Unix())
+		return 0, false
+	}
+	return merged, true
+}
+
+func (rs *RuleState) rateSec() int64 {
+	if rs.windowed && rs.rule.RateSec <= 0 {
+		return rs.rule.WindowSec
+	}
+	return rs.rule.RateSec
+}
+
+func (rs *RuleState) allowSend() bool {
+	latestSendTime := atomic.LoadInt64(&rs.latestSendTime)
+	// over interval
+	return latestSendTime == 0 || time.Now().Unix()-latestSendTime > rs.rateSec()
+}
+
+func (rs *RuleState) send(content string) bool {
+	if rs.method == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := rs.method.Send(ctx, content); err != nil {
+		logs.Qezap.Error("AlarmSend", zap.String(rs.method.Method(), err.Error()), zap.Any("content", content))
+		return false
+	}
+	// if interval time <= 0, send at once
+	latestSendTime := int64(0)
+	if rs.rateSec() > 0 {
+		latestSendTime = time.Now().Unix()
+	}
+	atomic.StoreInt64(&rs.latestSendTime, latestSendTime)
+	return true
+}
+
+func (rs *RuleState) parsingContent(v *model.Logging, count int64) string {
+	msg := rs.message(v)
+	msg.Count = count
+	return rs.render(msg)
+}
+
+func (rs *RuleState) parsingWindowContent(hits, total int64) string {
+	var v *model.Logging
+	if latest, ok := rs.latest.Load().(*model.Logging); ok && hits > 0 {
+		v = latest
+	}
+	msg := rs.message(v)
+	msg.Hits, msg.Total = hits, total
+	return rs.render(msg)
+}
+
+func (rs *RuleState) message(v *model.Logging) *Message {
+	return &Message{
+		KeyWord:    rs.KeyWord(),
+		Rule:       rs.rule,
+		Logging:    v,
+		Condition:  conditionText(rs.rule),
+		Link:       loggingLink(rs.adminURL, v),
+		ReportNode: machineIP,
 	}
-	return str
+}
+
+// render message template rendered, then hide text. template failed rendered by default
+func (rs *RuleState) render(msg *Message) string {
+	tpl := rs.tpl
+	if tpl == nil {
+		tpl = defaultTemplate
+	}
+	str, err := RenderMessage(tpl, msg)
+	if err != nil {
+		logs.Qezap.Error("AlarmTemplate", zap.String("key", rs.key), zap.Error(err))
+		str, _ = RenderMessage(defaultTemplate, msg)
+	}
+	return rs.hideText(str)
+}
+
+func (rs *RuleState) hideText(str string) string {
+	return HideText(str, rs.hook)
 }
 
 func (rs *RuleState) Key() string {
@@ -175,18 +420,33 @@ func (rs *RuleState) UpsertRule(new *model.AlarmRule, hook *model.HookURL) *Rule
 		rs.hook = hook
 		rs.key = new.Key()
 		rs.latestSendTime = 0
-		switch rs.rule.Method {
-		case model.MethodDingDing:
-			rs.method = alert.NewDingDing()
-			if rs.hook != nil {
-				rs.method.SetHookURL(rs.hook.URL)
-			}
-		case model.MethodTelegram:
-			rs.method = alert.NewTelegram()
-			if rs.hook != nil {
-				rs.method.SetHookURL(rs.hook.URL)
+		rs.exact = new.RuleType() == model.RuleExact
+		rs.windowed = new.Windowed()
+		rs.loadedAt = time.Now().Unix()
+		atomic.StoreInt64(&rs.hits, 0)
+		atomic.StoreInt64(&rs.total, 0)
+		rs.matcher = nil
+		if !rs.exact {
+			m, err := NewMatcher(new)
+			if err != nil {
+				logs.Qezap.Error("AlarmRule", zap.String("key", rs.key), zap.Error(err))
 			}
+			rs.matcher = m
+		}
+		text := new.Template
+		if text == "" && hook != nil {
+			text = hook.Template
+		}
+		tpl, err := ParseTemplate(text)
+		if err != nil {
+			logs.Qezap.Error("AlarmTemplate", zap.String("key", rs.key), zap.Error(err))
+		}
+		rs.tpl = tpl
+		method, err := notify.New(rs.rule.Method, rs.hook)
+		if err != nil {
+			logs.Qezap.Error("AlarmMethod", zap.String("key", rs.key), zap.Error(err))
 		}
+		rs.method = method
 	}
 	return rs
 }
@@ -0,0 +1,176 @@
+package alarm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bbdshow/qelog/pkg/model"
+)
+
+// Matcher compiled condition of not exact rule, matched every logging of module
+type Matcher interface {
+	Match(v *model.Logging, full *Payload) bool
+}
+
+// Payload full message json parsed once for all json path rules of logging
+type Payload struct {
+	raw    string
+	parsed bool
+	val    interface{}
+}
+
+func NewPayload(full string) *Payload {
+	return &Payload{raw: full}
+}
+
+func (p *Payload) value() interface{} {
+	if !p.parsed {
+		p.parsed = true
+		dec := json.NewDecoder(strings.NewReader(p.raw))
+		dec.UseNumber()
+		if err := dec.Decode(&p.val); err != nil {
+			p.val = nil
+		}
+	}
+	return p.val
+}
+
+type matchFunc func(v *model.Logging, full *Payload) bool
+
+func (f matchFunc) Match(v *model.Logging, full *Payload) bool {
+	return f(v, full)
+}
+
+// NewMatcher compile rule condition, pattern or field invalid returns error
+func NewMatcher(rule *model.AlarmRule) (Matcher, error) {
+	level := rule.Level
+	switch rule.RuleType() {
+	case model.RuleExact:
+		key := rule.Key()
+		return matchFunc(func(v *model.Logging, _ *Payload) bool {
+			return v.Key() == key
+		}), nil
+	case model.RuleShortRegex, model.RuleFullRegex:
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("regex pattern required")
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("regex pattern %v", err)
+		}
+		full := rule.RuleType() == model.RuleFullRegex
+		return matchFunc(func(v *model.Logging, _ *Payload) bool {
+			if v.Level < level {
+				return false
+			}
+			if full {
+				return re.MatchString(v.Full)
+			}
+			return re.MatchString(v.Short)
+		}), nil
+	case model.RuleLevel:
+		return matchFunc(func(v *model.Logging, _ *Payload) bool {
+			return v.Level >= level
+		}), nil
+	case model.RuleCondition:
+		field, err := conditionField(rule.Field)
+		if err != nil {
+			return nil, err
+		}
+		value := rule.Pattern
+		return matchFunc(func(v *model.Logging, _ *Payload) bool {
+			return v.Level >= level && field(v) == value
+		}), nil
+	case model.RuleJSONPath:
+		path, err := parseJSONPath(rule.Field)
+		if err != nil {
+			return nil, err
+		}
+		value := rule.Pattern
+		return matchFunc(func(v *model.Logging, full *Payload) bool {
+			if v.Level < level {
+				return false
+			}
+			val, ok := lookupJSONPath(full.value(), path)
+			if !ok {
+				return false
+			}
+			// empty pattern, path exists
+			return value == "" || jsonValueString(val) == value
+		}), nil
+	}
+	return nil, fmt.Errorf("rule type %s not supported", rule.Type)
+}
+
+func conditionField(name string) (func(v *model.Logging) string, error) {
+	switch name {
+	case "c1":
+		return func(v *model.Logging) string { return v.Condition1 }, nil
+	case "c2":
+		return func(v *model.Logging) string { return v.Condition2 }, nil
+	case "c3":
+		return func(v *model.Logging) string { return v.Condition3 }, nil
+	case "ip":
+		return func(v *model.Logging) string { return v.IP }, nil
+	}
+	return nil, fmt.Errorf("condition field %s not one of c1 c2 c3 ip", name)
+}
+
+// parseJSONPath dotted path, array index by [n], e.g. $.items[0].id or items.0.id
+func parseJSONPath(path string) ([]string, error) {
+	p := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if p == "" {
+		return nil, fmt.Errorf("json path required")
+	}
+	p = strings.NewReplacer("[", ".", "]", "").Replace(p)
+	keys := strings.Split(p, ".")
+	for _, k := range keys {
+		if k == "" {
+			return nil, fmt.Errorf("json path %s invalid", path)
+		}
+	}
+	return keys, nil
+}
+
+func lookupJSONPath(val interface{}, keys []string) (interface{}, bool) {
+	cur := val
+	for _, k := range keys {
+		switch d := cur.(type) {
+		case map[string]interface{}:
+			v, ok := d[k]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(d) {
+				return nil, false
+			}
+			cur = d[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// jsonValueString string raw, number as written, object and array compact json
+func jsonValueString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	}
+	b, _ := json.Marshal(val)
+	return string(bytes.TrimSpace(b))
+}
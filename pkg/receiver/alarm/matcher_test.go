@@ -0,0 +1,81 @@
+package alarm
+
+import (
+	"testing"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/types"
+)
+
+func TestNewMatcher(t *testing.T) {
+	lg := &model.Logging{
+		Module:     "order",
+		Level:      types.Level(2),
+		Short:      "pay timeout 3s",
+		Full:       `{"user":{"id":42,"vip":true},"items":[{"sku":"A1"}],"msg":"upstream timeout"}`,
+		Condition1: "pay",
+		IP:         "10.0.0.1",
+	}
+	testCases := []struct {
+		rule  model.AlarmRule
+		match bool
+	}{
+		{rule: model.AlarmRule{ModuleName: "order", Short: "pay timeout 3s", Level: 2}, match: true},
+		{rule: model.AlarmRule{ModuleName: "order", Short: "pay timeout", Level: 2}, match: false},
+		{rule: model.AlarmRule{Type: model.RuleShortRegex, Pattern: `^pay timeout \d+s$`, Level: 1}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleShortRegex, Pattern: `timeout`, Level: 3}, match: false},
+		{rule: model.AlarmRule{Type: model.RuleFullRegex, Pattern: `upstream\s+timeout`, Level: 2}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleLevel, Level: 2}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleLevel, Level: 3}, match: false},
+		{rule: model.AlarmRule{Type: model.RuleCondition, Field: "c1", Pattern: "pay", Level: 0}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleCondition, Field: "ip", Pattern: "10.0.0.2", Level: 0}, match: false},
+		{rule: model.AlarmRule{Type: model.RuleJSONPath, Field: "$.user.id", Pattern: "42", Level: 0}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleJSONPath, Field: "user.vip", Pattern: "true", Level: 0}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleJSONPath, Field: "$.items[0].sku", Pattern: "A1", Level: 0}, match: true},
+		{rule: model.AlarmRule{Type: model.RuleJSONPath, Field: "$.items[1].sku", Level: 0}, match: false},
+		{rule: model.AlarmRule{Type: model.RuleJSONPath, Field: "$.user", Level: 0}, match: true},
+	}
+	for i, c := range testCases {
+		m, err := NewMatcher(&c.rule)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if m.Match(lg, NewPayload(lg.Full)) != c.match {
+			t.Fatalf("case %d: %+v match want %v", i, c.rule, c.match)
+		}
+	}
+
+	// full message not json, json path not matched
+	m, _ := NewMatcher(&model.AlarmRule{Type: model.RuleJSONPath, Field: "$.user.id"})
+	if m.Match(&model.Logging{Full: "plain text"}, NewPayload("plain text")) {
+		t.Fatal("plain text matched json path")
+	}
+
+	for i, rule := range []model.AlarmRule{
+		{Type: model.RuleShortRegex},
+		{Type: model.RuleFullRegex, Pattern: "(unclosed"},
+		{Type: model.RuleCondition, Field: "c4"},
+		{Type: model.RuleJSONPath, Field: "$"},
+		{Type: model.RuleJSONPath, Field: "a..b"},
+		{Type: "unknown"},
+	} {
+		if _, err := NewMatcher(&rule); err == nil {
+			t.Fatalf("invalid case %d compiled", i)
+		}
+	}
+}
+
+func BenchmarkAlarm_IsAlarm(b *testing.B) {
+	a := NewAlarm(nil)
+	rules := []*model.AlarmRule{
+		{ModuleName: "order", Short: "pay failed", Level: 2},
+		{ModuleName: "order", Type: model.RuleShortRegex, Pattern: `timeout \d+s`, Level: 3},
+		{ModuleName: "order", Type: model.RuleJSONPath, Field: "$.user.id", Pattern: "7", Level: 2},
+	}
+	a.InitRuleState(rules, nil)
+	docs := []*model.Logging{{Module: "order", Level: 2, Short: "pay timeout 3s", Full: `{"user":{"id":42}}`}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.IsAlarm(docs)
+	}
+}
@@ -0,0 +1,174 @@
+package alarm
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/types"
+)
+
+// DefaultTemplate alarm message of rule and hook template not set
+const DefaultTemplate = `{{.KeyWord}}
+Tag: {{.Rule.Tag}}
+{{- if .Rule.Windowed}}
+Condition: {{.Condition}}
+Window: {{.Hits}}/{{.Total}} in {{.Rule.WindowSec}}s
+{{- end}}
+{{- with .Logging}}
+IP: {{.IP}}
+Time: {{ts .TimeSec}}
+Level: {{.Level}}
+Msg: {{.Short}}
+{{- if not $.Rule.Windowed}}
+Detail: {{.Full}}
+Rate: {{$.Count}}/{{$.Rule.RateSec}}s
+{{- end}}
+{{- end}}
+{{- if .Link}}
+Link: {{.Link}}
+{{- end}}
+ReportNode: {{.ReportNode}}`
+
+var (
+	templateFuncs = template.FuncMap{
+		// ts unix second formatted
+		"ts": func(sec int64) string {
+			return time.Unix(sec, 0).Format("2006-01-02 15:04:05")
+		},
+		// truncate string max n runes
+		"truncate": func(n int, s string) string {
+			r := []rune(s)
+			if n < 0 || len(r) <= n {
+				return s
+			}
+			return string(r[:n]) + "..."
+		},
+	}
+	defaultTemplate = template.Must(template.New("alarm").Funcs(templateFuncs).Parse(DefaultTemplate))
+)
+
+// Message data of alarm message template
+type Message struct {
+	KeyWord string
+	Rule    *model.AlarmRule
+	// Logging matched, windowed rule latest matched, nil absence of matched
+	Logging *model.Logging
+	// Count hit rule, merged hits of receivers since latest sent
+	Count int64
+	// Hits Total windowed rule, matched and module logging count in window
+	Hits      int64
+	Total     int64
+	Condition string
+	// Link admin logging view of logging, admin external url not set empty
+	Link       string
+	ReportNode string
+}
+
+// ParseTemplate alarm message template, empty default. rendered by sample message of hit and windowed rule,
+// absence alarm without logging, template failed rendered by default
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return defaultTemplate, nil
+	}
+	tpl, err := template.New("alarm").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, cond := range []string{model.ConditionHit, model.ConditionThreshold} {
+		rule := &model.AlarmRule{ModuleName: "example", Condition: cond}
+		if _, err := RenderMessage(tpl, SampleMessage(rule, nil, "")); err != nil {
+			return nil, err
+		}
+	}
+	return tpl, nil
+}
+
+// RenderMessage message rendered by template
+func RenderMessage(tpl *template.Template, msg *Message) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, msg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// SampleMessage message of rule with sample logging, previewed before template saved
+func SampleMessage(rule *model.AlarmRule, hook *model.HookURL, adminURL string) *Message {
+	now := time.Now()
+	lg := &model.Logging{
+		Module:     rule.ModuleName,
+		IP:         "127.0.0.1",
+		Level:      types.Level(rule.Level),
+		Short:      rule.Short,
+		Full:       `{"sample":"full message"}`,
+		Condition1: "c1",
+		Condition2: "c2",
+		Condition3: "c3",
+		TraceID:    "5f6b1a2c3d4e5f60718293a4",
+		TimeMill:   now.UnixNano() / int64(time.Millisecond),
+		TimeSec:    now.Unix(),
+	}
+	if lg.Short == "" {
+		lg.Short = "sample short message"
+	}
+	msg := &Message{
+		KeyWord:    ContentPrefix,
+		Rule:       rule,
+		Logging:    lg,
+		Count:      1,
+		Condition:  conditionText(rule),
+		Link:       loggingLink(adminURL, lg),
+		ReportNode: machineIP,
+	}
+	if hook != nil && hook.KeyWord != "" {
+		msg.KeyWord = hook.KeyWord
+	}
+	if rule.Windowed() {
+		msg.Count, msg.Hits, msg.Total = 0, 10, 100
+		if rule.AlarmCondition() == model.ConditionAbsence {
+			msg.Hits, msg.Logging, msg.Link = 0, nil, ""
+		}
+	}
+	return msg
+}
+
+// HideText hide text of hook masked in rendered message
+func HideText(str string, hook *model.HookURL) string {
+	if hook != nil {
+		for _, hide := range hook.HideText {
+			str = strings.ReplaceAll(str, hide, "****")
+		}
+	}
+	return str
+}
+
+func conditionText(rule *model.AlarmRule) string {
+	cond := rule.AlarmCondition()
+	switch cond {
+	case model.ConditionThreshold:
+		cond = fmt.Sprintf("%s >= %v", cond, rule.Threshold)
+	case model.ConditionRatio:
+		cond = fmt.Sprintf("%s >= %v%%", cond, rule.Threshold)
+	}
+	return cond
+}
+
+// loggingLink admin logging view of module around logging time, trace id query if exists
+func loggingLink(adminURL string, v *model.Logging) string {
+	if adminURL == "" || v == nil {
+		return ""
+	}
+	q := url.Values{}
+	q.Set("moduleName", v.Module)
+	q.Set("beginTsSec", fmt.Sprintf("%d", v.TimeSec-60))
+	q.Set("endTsSec", fmt.Sprintf("%d", v.TimeSec+60))
+	if v.TraceID != "" {
+		q.Set("traceId", v.TraceID)
+	}
+	return fmt.Sprintf("%s/admin/#/logging/index?%s", strings.TrimRight(adminURL, "/"), q.Encode())
+}
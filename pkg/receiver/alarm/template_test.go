@@ -0,0 +1,57 @@
+package alarm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/types"
+)
+
+func TestRuleState_Render(t *testing.T) {
+	lg := &model.Logging{Module: "order", IP: "10.0.0.1", Level: types.Level(2), Short: "pay failed",
+		Full: `{"token":"secret-token"}`, TraceID: "5f6b1a2c3d4e5f60718293a4", TimeSec: 1600000000}
+	hook := &model.HookURL{KeyWord: "[ORDER]", HideText: []string{"secret-token"}}
+	rule := &model.AlarmRule{ModuleName: "order", Short: "pay failed", Level: 2, Tag: "pay", RateSec: 60}
+
+	rs := new(RuleState).UpsertRule(rule, hook)
+	rs.adminURL = "https://qelog.example.com/"
+	content := rs.parsingContent(lg, 3)
+	for _, want := range []string{
+		"[ORDER]\nTag: pay\nIP: 10.0.0.1\n",
+		"Level: ERROR\nMsg: pay failed\nDetail: {\"token\":\"****\"}\nRate: 3/60s\n",
+		"Link: https://qelog.example.com/admin/#/logging/index?beginTsSec=1599999940&endTsSec=1600000060&moduleName=order&traceId=5f6b1a2c3d4e5f60718293a4\n",
+		"ReportNode: ",
+	} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("content not contains %q\n%s", want, content)
+		}
+	}
+
+	// rule template first, hide text masked after rendered
+	hook.Template = "hook {{.Rule.Tag}}"
+	rule.Template = "### {{.Rule.Tag}} x{{.Count}}\n`{{truncate 12 .Logging.Full}}`"
+	rs = new(RuleState).UpsertRule(rule, hook)
+	if content := rs.parsingContent(lg, 2); content != "### pay x2\n`{\"token\":\"se...`" {
+		t.Fatalf("rule template content %q", content)
+	}
+	rule.Template = ""
+	rs = new(RuleState).UpsertRule(rule, hook)
+	if content := rs.parsingContent(lg, 1); content != "hook pay" {
+		t.Fatalf("hook template content %q", content)
+	}
+
+	// absence without logging
+	rule = &model.AlarmRule{ModuleName: "order", Type: model.RuleLevel, Level: 3, Tag: "silent",
+		Condition: model.ConditionAbsence, WindowSec: 300}
+	rs = new(RuleState).UpsertRule(rule, nil)
+	if content := rs.parsingWindowContent(0, 10); !strings.HasPrefix(content, "[QELOG]\nTag: silent\nCondition: absence\nWindow: 0/10 in 300s\nReportNode: ") {
+		t.Fatalf("window content %q", content)
+	}
+
+	for i, text := range []string{"{{.Rule.Tag", "{{.Unknown}}", "{{nofunc .Count}}"} {
+		if _, err := ParseTemplate(text); err == nil {
+			t.Fatalf("invalid template %d parsed", i)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package alarm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memStore struct {
+	mutex   sync.Mutex
+	buckets map[string]map[int64][2]int64
+	leases  map[string]*model.AlarmLease
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		buckets: map[string]map[int64][2]int64{},
+		leases:  map[string]*model.AlarmLease{},
+	}
+}
+
+func (c *memStore) IncrAlarmCounter(_ context.Context, ruleID string, bucketTs, hits, total int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.buckets[ruleID] == nil {
+		c.buckets[ruleID] = map[int64][2]int64{}
+	}
+	v := c.buckets[ruleID][bucketTs]
+	c.buckets[ruleID][bucketTs] = [2]int64{v[0] + hits, v[1] + total}
+	return nil
+}
+
+func (c *memStore) SumAlarmCounter(_ context.Context, ruleID string, beginTs, endTs int64) (int64, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	hits, total := int64(0), int64(0)
+	for ts, v := range c.buckets[ruleID] {
+		if ts >= beginTs && ts < endTs {
+			hits += v[0]
+			total += v[1]
+		}
+	}
+	return hits, total, nil
+}
+
+func (c *memStore) IncrAlarmLease(_ context.Context, ruleID string, hits int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.leases[ruleID] == nil {
+		c.leases[ruleID] = &model.AlarmLease{RuleID: ruleID}
+	}
+	c.leases[ruleID].Hits += hits
+	return nil
+}
+
+func (c *memStore) AcquireAlarmLease(ctx context.Context, ruleID, owner, node string, hits, leaseSec int64) (bool, int64, error) {
+	_ = c.IncrAlarmLease(ctx, ruleID, hits)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	l := c.leases[ruleID]
+	now := time.Now().Unix()
+	if l.ExpireTsSec > now && l.Owner != owner {
+		return false, 0, nil
+	}
+	l.Owner, l.Node, l.ExpireTsSec = owner, node, now+leaseSec
+	merged := l.Hits - l.SentHits
+	l.SentHits = l.Hits
+	return true, merged, nil
+}
+
+type sentMethod struct {
+	contents []string
+}
+
+func (m *sentMethod) SetHookURL(string) {}
+func (m *sentMethod) Send(_ context.Context, content string) error {
+	m.contents = append(m.contents, content)
+	return nil
+}
+func (m *sentMethod) Method() string { return "test" }
+
+func TestAlarm_EvalWindow(t *testing.T) {
+	store := newMemStore()
+	rules := []*model.AlarmRule{
+		{ID: primitive.NewObjectID(), ModuleName: "order", Short: "pay failed", Level: 2,
+			Condition: model.ConditionThreshold, WindowSec: 60, Threshold: 3},
+		{ID: primitive.NewObjectID(), ModuleName: "order", Type: model.RuleLevel, Level: 3,
+			Condition: model.ConditionRatio, WindowSec: 60, Threshold: 50},
+		{ID: primitive.NewObjectID(), ModuleName: "order", Type: model.RuleShortRegex, Pattern: "^paid", Level: 1,
+			Condition: model.ConditionAbsence, WindowSec: 60},
+	}
+	// two receivers share store
+	receivers := []*Alarm{NewAlarm(store), NewAlarm(store)}
+	methods := make([]map[string]*sentMethod, len(receivers))
+	for i, a := range receivers {
+		a.InitRuleState(rules, nil)
+		methods[i] = map[string]*sentMethod{}
+		for _, r := range rules {
+			m := &sentMethod{}
+			a.ruleState[r.Key()].method = m
+			methods[i][r.Condition] = m
+		}
+	}
+	sent := func(cond string) int {
+		n := 0
+		for _, m := range methods {
+			n += len(m[cond].contents)
+		}
+		return n
+	}
+
+	now := time.Now()
+	step := model.AlarmCounterStepSec * time.Second
+	// 2 hits each receiver, 4 reached threshold, error logging 1/5 under ratio
+	for _, a := range receivers {
+		a.IsAlarm([]*model.Logging{
+			{Module: "order", Level: 2, Short: "pay failed"},
+			{Module: "order", Level: 2, Short: "pay failed"},
+		})
+	}
+	receivers[0].IsAlarm([]*model.Logging{{Module: "order", Level: 3, Short: "db down"}})
+	for _, a := range receivers {
+		a.evalWindow(context.Background(), now)
+	}
+	// current bucket not completed, other receivers may not flushed
+	if sent(model.ConditionThreshold) != 0 {
+		t.Fatalf("threshold sent %d, want 0 in current bucket", sent(model.ConditionThreshold))
+	}
+	// both receivers summed same completed buckets, sent once by lease
+	for _, a := range receivers {
+		a.evalWindow(context.Background(), now.Add(step))
+	}
+	if sent(model.ConditionThreshold) != 1 || len(methods[0][model.ConditionThreshold].contents) != 1 {
+		t.Fatalf("threshold sent %d, want 1 by first receiver", sent(model.ConditionThreshold))
+	}
+	if sent(model.ConditionRatio) != 0 {
+		t.Fatalf("ratio sent %d, want 0", sent(model.ConditionRatio))
+	}
+	// absence waits one window since loaded
+	if sent(model.ConditionAbsence) != 0 {
+		t.Fatalf("absence sent %d, want 0", sent(model.ConditionAbsence))
+	}
+
+	// error logging 4/8 reached ratio, after bucket completed
+	receivers[1].IsAlarm([]*model.Logging{
+		{Module: "order", Level: 3, Short: "db down"},
+		{Module: "order", Level: 3, Short: "db down"},
+		{Module: "order", Level: 3, Short: "db down"},
+	})
+	receivers[1].evalWindow(context.Background(), now.Add(step))
+	if sent(model.ConditionRatio) != 0 {
+		t.Fatalf("ratio sent %d, want 0 in current bucket", sent(model.ConditionRatio))
+	}
+	receivers[1].evalWindow(context.Background(), now.Add(2*step))
+	if sent(model.ConditionRatio) != 1 {
+		t.Fatalf("ratio sent %d, want 1", sent(model.ConditionRatio))
+	}
+	if sent(model.ConditionThreshold) != 1 {
+		t.Fatalf("threshold sent %d, want 1 lease held by first receiver", sent(model.ConditionThreshold))
+	}
+
+	for _, a := range receivers {
+		for _, state := range a.ruleState {
+			state.loadedAt = now.Unix() - 60 - model.AlarmCounterStepSec
+		}
+	}
+	receivers[0].evalWindow(context.Background(), now.Add(2*step))
+	if sent(model.ConditionAbsence) != 1 {
+		t.Fatalf("absence sent %d, want 1", sent(model.ConditionAbsence))
+	}
+}
+
+func TestAlarm_Lease(t *testing.T) {
+	store := newMemStore()
+	rule := &model.AlarmRule{ID: primitive.NewObjectID(), ModuleName: "order", Short: "pay failed", Level: 2, RateSec: 60}
+	receivers := []*Alarm{NewAlarm(store), NewAlarm(store), NewAlarm(store)}
+	methods := make([]*sentMethod, len(receivers))
+	for i, a := range receivers {
+		a.InitRuleState([]*model.AlarmRule{rule}, nil)
+		methods[i] = &sentMethod{}
+		a.ruleState[rule.Key()].method = methods[i]
+	}
+	lg := &model.Logging{Module: "order", Level: 2, Short: "pay failed"}
+	for i := 0; i < 3; i++ {
+		for _, a := range receivers {
+			a.IsAlarm([]*model.Logging{lg})
+		}
+	}
+	sent := 0
+	for _, m := range methods {
+		sent += len(m.contents)
+	}
+	if sent != 1 || len(methods[0].contents) != 1 {
+		t.Fatalf("sent %d, want 1 by first receiver", sent)
+	}
+
+	// hits of other receivers merged, sent by owner after lease expired
+	for _, a := range receivers {
+		a.FlushHits(context.Background())
+	}
+	store.leases[rule.ID.Hex()].ExpireTsSec = 0
+	receivers[0].ruleState[rule.Key()].latestSendTime = 0
+	receivers[0].IsAlarm([]*model.Logging{lg})
+	if len(methods[0].contents) != 2 || !strings.Contains(methods[0].contents[1], "Rate: 9/60s") {
+		t.Fatalf("merged content %v", methods[0].contents)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/bbdshow/bkit/db/mongo"
 	"github.com/bbdshow/bkit/logs"
 	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.uber.org/zap"
 )
 
@@ -28,6 +29,40 @@ func (svc *Service) updateModuleSetting() error {
 	}
 	return nil
 }
+
+// token mismatch module reload interval
+const moduleReloadInterval = 5 * time.Second
+
+// reloadModule module reloaded at most once in moduleReloadInterval, invalid token not query db every request
+func (svc *Service) reloadModule(ctx context.Context, name string) (*module, bool) {
+	svc.lock.Lock()
+	if time.Since(svc.reloadedAt[name]) < moduleReloadInterval {
+		svc.lock.Unlock()
+		return nil, false
+	}
+	svc.reloadedAt[name] = time.Now()
+	svc.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	exists, doc, err := svc.d.GetModule(ctx, bson.M{"name": name})
+	if err != nil {
+		logs.Qezap.Error("reloadModule", zap.String("name", name), zap.Error(err))
+		return nil, false
+	}
+	if !exists {
+		return nil, false
+	}
+	v := &module{
+		m:  doc,
+		sc: mongo.NewShardCollection(doc.Prefix, doc.DaySpan),
+	}
+	svc.lock.Lock()
+	svc.modules[name] = v
+	svc.lock.Unlock()
+	return v, true
+}
+
 func (svc *Service) bgSyncModuleSetting() {
 	tick := time.NewTicker(30 * time.Second)
 	for range tick.C {
@@ -70,3 +105,15 @@ func (svc *Service) bgSyncAlarmRuleSetting() {
 		time.Sleep(time.Minute)
 	}
 }
+
+// bgSyncAlarmState windowed rules counted by all receivers evaluated every counter bucket,
+// hits not sent merged to lease of rule
+func (svc *Service) bgSyncAlarmState() {
+	tick := time.NewTicker(model.AlarmCounterStepSec * time.Second)
+	for range tick.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		svc.alarm.EvalWindow(ctx)
+		svc.alarm.FlushHits(ctx)
+		cancel()
+	}
+}
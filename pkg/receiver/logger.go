@@ -12,9 +12,11 @@ import (
 	"github.com/bbdshow/qelog/api/receiverpb"
 	"github.com/bbdshow/qelog/pkg/model"
 	"github.com/bbdshow/qelog/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (svc *Service) JSONPacketToLogging(ctx context.Context, ip string, in *api.JSONPacket) error {
+// JSONPacketToLogging token is module access token, verify before written
+func (svc *Service) JSONPacketToLogging(ctx context.Context, ip, token string, in *api.JSONPacket) error {
 	if len(in.Data) <= 0 {
 		return nil
 	}
@@ -25,6 +27,10 @@ func (svc *Service) JSONPacketToLogging(ctx context.Context, ip string, in *api.
 	if !ok {
 		return errc.ErrNotFound.MultiMsg("module unregistered")
 	}
+	m, err := svc.verifyAccessToken(ctx, m, token)
+	if err != nil {
+		return err
+	}
 
 	docs := svc.decodeJSONPacket(ip, in)
 
@@ -36,10 +42,11 @@ func (svc *Service) JSONPacketToLogging(ctx context.Context, ip string, in *api.
 		go svc.metrics.Statistics(in.Module, ip, docs)
 	}
 
-	return svc.createLogging(ctx, m, docs)
+	return svc.writeLogging(ctx, m, docs)
 }
 
-func (svc *Service) PacketToLogging(ctx context.Context, ip string, in *receiverpb.Packet) error {
+// PacketToLogging token is module access token, verify before written
+func (svc *Service) PacketToLogging(ctx context.Context, ip, token string, in *receiverpb.Packet) error {
 	if len(in.Data) <= 0 {
 		return nil
 	}
@@ -49,8 +56,15 @@ func (svc *Service) PacketToLogging(ctx context.Context, ip string, in *receiver
 	if !ok {
 		return errc.ErrNotFound.MultiMsg("module unregistered")
 	}
+	m, err := svc.verifyAccessToken(ctx, m, token)
+	if err != nil {
+		return err
+	}
 
-	docs := svc.decodePacket(ip, in)
+	docs, err := svc.decodePacket(ip, in)
+	if err != nil {
+		return err
+	}
 
 	if svc.cfg.Receiver.AlarmEnable && svc.alarm.ModuleIsEnable(in.Module) {
 		go svc.alarm.IsAlarm(docs)
@@ -60,11 +74,38 @@ func (svc *Service) PacketToLogging(ctx context.Context, ip string, in *receiver
 		go svc.metrics.Statistics(in.Module, ip, docs)
 	}
 
+	return svc.writeLogging(ctx, m, docs)
+}
+
+// writeLogging queue enabled published to queue, else written db synchronous
+func (svc *Service) writeLogging(ctx context.Context, m *module, docs []*model.Logging) error {
+	if svc.queue != nil {
+		return svc.publishLogging(ctx, m, docs)
+	}
 	return svc.createLogging(ctx, m, docs)
 }
 
-func (svc *Service) decodePacket(ip string, in *receiverpb.Packet) []*model.Logging {
-	byteItems := bytes.Split(in.Data, []byte{'\n'})
+// verifyAccessToken token mismatch module reloaded before rejected,
+// token rotated accepted before next module setting sync
+func (svc *Service) verifyAccessToken(ctx context.Context, m *module, token string) (*module, error) {
+	if m.m.VerifyAccessToken(token) {
+		return m, nil
+	}
+	if token == "" {
+		return m, errc.ErrAuthRequired.MultiMsg("module access token")
+	}
+	if v, ok := svc.reloadModule(ctx, m.m.Name); ok && v.m.VerifyAccessToken(token) {
+		return v, nil
+	}
+	return m, errc.ErrAuthInvalid.MultiMsg("module access token")
+}
+
+func (svc *Service) decodePacket(ip string, in *receiverpb.Packet) ([]*model.Logging, error) {
+	data, err := receiverpb.Decompress(in.Compression, in.Data)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	byteItems := bytes.Split(data, []byte{'\n'})
 	records := make([]*model.Logging, 0, len(byteItems))
 
 	for i, v := range byteItems {
@@ -72,6 +113,8 @@ func (svc *Service) decodePacket(ip string, in *receiverpb.Packet) []*model.Logg
 			continue
 		}
 		r := &model.Logging{
+			// assigned id, written db idempotent when retried
+			ID:        primitive.NewObjectID(),
 			Module:    in.Module,
 			IP:        ip,
 			Full:      string(v),
@@ -93,7 +136,7 @@ func (svc *Service) decodePacket(ip string, in *receiverpb.Packet) []*model.Logg
 		}
 		records = append(records, r)
 	}
-	return records
+	return records, nil
 }
 
 func (svc *Service) decodeJSONPacket(ip string, in *api.JSONPacket) []*model.Logging {
@@ -104,6 +147,8 @@ func (svc *Service) decodeJSONPacket(ip string, in *api.JSONPacket) []*model.Log
 			continue
 		}
 		r := &model.Logging{
+			// assigned id, written db idempotent when retried
+			ID:        primitive.NewObjectID(),
 			Module:    in.Module,
 			IP:        ip,
 			Full:      v,
@@ -217,11 +262,11 @@ func (svc *Service) loggerDataShardingByTimestamp(m *module, docs []*model.Loggi
 }
 
 // determine collection is exists, if not,it is created and index
-// collection name unique
+// collection name unique in database, module database or storage may be changed
 func (svc *Service) ifCreateCollIndex(ctx context.Context, m *module, collectionName string) error {
 	svc.lock.Lock()
 	defer svc.lock.Unlock()
-	if _, ok := svc.collections[collectionName]; ok {
+	if _, ok := svc.collections[m.m.Database+"."+collectionName]; ok {
 		return nil
 	}
 	names, err := svc.d.ListCollectionNames(ctx, m.m.Database, m.m.LoggingPrefix())
@@ -233,12 +278,20 @@ func (svc *Service) ifCreateCollIndex(ctx context.Context, m *module, collection
 		if n == collectionName {
 			exists = true
 		}
-		svc.collections[n] = struct{}{}
 	}
-
+	// registered before created, admin query shards from catalog
+	shard, err := model.NewShard(m.m, m.m.Database, collectionName)
+	if err != nil {
+		return err
+	}
+	if err := svc.d.RegisterShard(ctx, shard); err != nil {
+		return err
+	}
 	if !exists {
-		return svc.d.CreateLoggingIndex(m.m.Database, collectionName)
+		if err := svc.d.CreateLoggingIndex(m.m.Database, collectionName, m.m.FullText); err != nil {
+			return err
+		}
 	}
-
+	svc.collections[m.m.Database+"."+collectionName] = struct{}{}
 	return nil
 }
@@ -0,0 +1,364 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	diskSegmentExt    = ".seg"
+	diskCommitFile    = "commit"
+	diskRecordHeader  = 8 // length uint32 + crc32 uint32
+	diskSegmentBytes  = 64 << 20
+	diskMaxRecordSize = 64 << 20
+)
+
+// diskQueue append only segment files in dir, file name is start offset of it.
+// record: [length uint32][crc32 uint32][payload], fsync before publish returns.
+// fsync not under write mutex, concurrent publish written meanwhile share one fsync (group commit),
+// so publish throughput not limited to one message per fsync.
+// committed offset persisted in commit file, segments before it removed
+type diskQueue struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	mutex     sync.Mutex
+	segments  []int64 // sorted start offset
+	w         *os.File
+	wOffset   int64
+	synced    int64 // written before it fsynced, visible to receive
+	committed int64
+	closed    bool
+
+	// one fsync at a time, publish waiting it may be synced by others
+	syncMutex sync.Mutex
+
+	// receive serialized
+	rMutex  sync.Mutex
+	r       *os.File
+	rStart  int64
+	rOffset int64
+
+	acks   *ackTracker
+	notify chan struct{}
+	done   chan struct{}
+}
+
+// NewDiskQueue maxBytes limit not committed data size, <= 0 not limit
+func NewDiskQueue(dir string, maxBytes int64) (Queue, error) {
+	return newDiskQueue(dir, maxBytes, diskSegmentBytes)
+}
+
+func newDiskQueue(dir string, maxBytes, segmentBytes int64) (*diskQueue, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("disk queue dir required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &diskQueue{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: segmentBytes,
+		acks:         newAckTracker(),
+		notify:       make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *diskQueue) open() error {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), diskSegmentExt) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), diskSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, start)
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if b, err := ioutil.ReadFile(filepath.Join(q.dir, diskCommitFile)); err == nil {
+		q.committed, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+
+	if len(q.segments) == 0 {
+		q.segments = append(q.segments, q.committed)
+		q.wOffset = q.committed
+	} else {
+		// crashed when written, truncate incomplete record at tail
+		last := q.segments[len(q.segments)-1]
+		size, err := validSize(q.segmentName(last))
+		if err != nil {
+			return err
+		}
+		if err := os.Truncate(q.segmentName(last), size); err != nil {
+			return err
+		}
+		q.wOffset = last + size
+	}
+	if q.committed < q.segments[0] {
+		q.committed = q.segments[0]
+	}
+	if q.committed > q.wOffset {
+		q.committed = q.wOffset
+	}
+	q.rOffset = q.committed
+	q.synced = q.wOffset
+
+	w, err := os.OpenFile(q.segmentName(q.segments[len(q.segments)-1]), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	q.w = w
+	return nil
+}
+
+// validSize scan segment, returns size of complete records
+func validSize(name string) (int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	size := int64(0)
+	for {
+		_, n, err := readRecord(f, size)
+		if err != nil {
+			return size, nil
+		}
+		size += n
+	}
+}
+
+func readRecord(f io.ReaderAt, off int64) ([]byte, int64, error) {
+	header := make([]byte, diskRecordHeader)
+	if _, err := f.ReadAt(header, off); err != nil {
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > diskMaxRecordSize {
+		return nil, 0, fmt.Errorf("record length %d exceeded", length)
+	}
+	value := make([]byte, length)
+	if _, err := f.ReadAt(value, off+diskRecordHeader); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(value) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, fmt.Errorf("record crc mismatch")
+	}
+	return value, int64(diskRecordHeader + length), nil
+}
+
+func (q *diskQueue) segmentName(start int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", start, diskSegmentExt))
+}
+
+func (q *diskQueue) Publish(_ context.Context, value []byte) error {
+	if len(value) > diskMaxRecordSize {
+		return fmt.Errorf("message size %d exceeded", len(value))
+	}
+	buf := make([]byte, diskRecordHeader+len(value))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(value)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(value))
+	copy(buf[diskRecordHeader:], value)
+
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return ErrClosed
+	}
+	if q.maxBytes > 0 && q.wOffset-q.committed+int64(len(buf)) > q.maxBytes {
+		q.mutex.Unlock()
+		return ErrFull
+	}
+	if q.wOffset-q.segments[len(q.segments)-1] >= q.segmentBytes {
+		if err := q.rotate(); err != nil {
+			q.mutex.Unlock()
+			return err
+		}
+	}
+	if _, err := q.w.Write(buf); err != nil {
+		q.mutex.Unlock()
+		return err
+	}
+	q.wOffset += int64(len(buf))
+	end := q.wOffset
+	q.mutex.Unlock()
+
+	if err := q.sync(end); err != nil {
+		return err
+	}
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// sync fsync written data until end, all written when fsync started synced together
+func (q *diskQueue) sync(end int64) error {
+	q.syncMutex.Lock()
+	defer q.syncMutex.Unlock()
+	q.mutex.Lock()
+	if q.synced >= end {
+		q.mutex.Unlock()
+		return nil
+	}
+	w, wOffset := q.w, q.wOffset
+	q.mutex.Unlock()
+
+	err := w.Sync()
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if err != nil {
+		// segment rotated meanwhile, synced before closed
+		if q.synced >= end {
+			return nil
+		}
+		return err
+	}
+	if wOffset > q.synced {
+		q.synced = wOffset
+	}
+	return nil
+}
+
+// rotate written segment synced before closed
+func (q *diskQueue) rotate() error {
+	if err := q.w.Sync(); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(q.segmentName(q.wOffset), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_ = q.w.Close()
+	q.w = w
+	q.segments = append(q.segments, q.wOffset)
+	q.synced = q.wOffset
+	return nil
+}
+
+func (q *diskQueue) Receive(ctx context.Context) (*Message, error) {
+	q.rMutex.Lock()
+	defer q.rMutex.Unlock()
+	for {
+		q.mutex.Lock()
+		closed, synced := q.closed, q.synced
+		segStart := q.segments[0]
+		for _, v := range q.segments {
+			if v <= q.rOffset {
+				segStart = v
+			}
+		}
+		q.mutex.Unlock()
+		if closed {
+			return nil, ErrClosed
+		}
+		if q.rOffset < synced {
+			return q.read(segStart)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.done:
+			return nil, ErrClosed
+		case <-q.notify:
+		}
+	}
+}
+
+func (q *diskQueue) read(segStart int64) (*Message, error) {
+	if q.r == nil || q.rStart != segStart {
+		if q.r != nil {
+			_ = q.r.Close()
+		}
+		r, err := os.Open(q.segmentName(segStart))
+		if err != nil {
+			return nil, err
+		}
+		q.r, q.rStart = r, segStart
+	}
+	value, n, err := readRecord(q.r, q.rOffset-segStart)
+	if err != nil {
+		return nil, fmt.Errorf("disk queue read offset %d: %v", q.rOffset, err)
+	}
+	msg := &Message{Value: value, offset: q.rOffset}
+	q.acks.delivered(0, q.rOffset, q.rOffset+n)
+	q.rOffset += n
+	return msg, nil
+}
+
+func (q *diskQueue) Ack(_ context.Context, msg *Message) error {
+	next, ok := q.acks.ack(0, msg.offset)
+	if !ok {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if next <= q.committed {
+		return nil
+	}
+	q.committed = next
+	if err := q.writeCommit(); err != nil {
+		return err
+	}
+	// segment all committed, remove it, current written segment keep
+	for len(q.segments) > 1 && q.segments[1] <= q.committed {
+		if err := os.Remove(q.segmentName(q.segments[0])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+// writeCommit rename is atomic, commit file not be half written
+func (q *diskQueue) writeCommit() error {
+	tmp := filepath.Join(q.dir, diskCommitFile+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(q.committed, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, diskCommitFile))
+}
+
+func (q *diskQueue) Close() error {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.done)
+	err := q.w.Close()
+	q.mutex.Unlock()
+
+	q.rMutex.Lock()
+	if q.r != nil {
+		_ = q.r.Close()
+	}
+	q.rMutex.Unlock()
+	return err
+}
@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func receiveN(t *testing.T, q Queue, n int) []*Message {
+	msgs := make([]*Message, 0, n)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		msg, err := q.Receive(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func assertEmpty(t *testing.T, q Queue) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if msg, err := q.Receive(ctx); err == nil {
+		t.Fatalf("queue should empty, received %s", msg.Value)
+	}
+}
+
+func TestDiskQueue_AckOutOfOrder(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newDiskQueue(dir, 0, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := q.Publish(context.Background(), []byte(fmt.Sprintf("message-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := receiveN(t, q, 5)
+	for i, msg := range msgs {
+		if string(msg.Value) != fmt.Sprintf("message-%d", i) {
+			t.Fatalf("message %d %s", i, msg.Value)
+		}
+	}
+	// message-2 not acked, commit position stop at it
+	for _, i := range []int{4, 0, 1, 3} {
+		if err := q.Ack(context.Background(), msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = q.Close()
+
+	q, err = newDiskQueue(dir, 0, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs = receiveN(t, q, 3)
+	if string(msgs[0].Value) != "message-2" {
+		t.Fatalf("redelivered %s", msgs[0].Value)
+	}
+	for _, msg := range msgs {
+		if err := q.Ack(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEmpty(t, q)
+	// committed segments removed, only written segment left
+	if len(q.segments) != 1 {
+		t.Fatalf("segments %v", q.segments)
+	}
+	_ = q.Close()
+
+	q, err = newDiskQueue(dir, 0, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	assertEmpty(t, q)
+}
+
+func TestDiskQueue_TruncateIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newDiskQueue(dir, 0, diskSegmentBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Publish(context.Background(), []byte("complete")); err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Close()
+
+	// crashed when written
+	f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%020d%s", 0, diskSegmentExt)), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{0, 0, 0, 100, 1, 2})
+	_ = f.Close()
+
+	q, err = newDiskQueue(dir, 0, diskSegmentBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if err := q.Publish(context.Background(), []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	msgs := receiveN(t, q, 2)
+	if string(msgs[0].Value) != "complete" || string(msgs[1].Value) != "after" {
+		t.Fatalf("messages %s %s", msgs[0].Value, msgs[1].Value)
+	}
+}
+
+func TestDiskQueue_Full(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), 32, diskSegmentBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if err := q.Publish(context.Background(), make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Publish(context.Background(), make([]byte, 16)); err != ErrFull {
+		t.Fatalf("expect full, got %v", err)
+	}
+	msgs := receiveN(t, q, 1)
+	if err := q.Ack(context.Background(), msgs[0]); err != nil {
+		t.Fatal(err)
+	}
+	// acked, space released
+	if err := q.Publish(context.Background(), make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskQueue_ReceiveWaitPublish(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), 0, diskSegmentBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = q.Publish(context.Background(), []byte("later"))
+	}()
+	msgs := receiveN(t, q, 1)
+	if string(msgs[0].Value) != "later" {
+		t.Fatalf("message %s", msgs[0].Value)
+	}
+	_ = q.Close()
+	if _, err := q.Receive(context.Background()); err != ErrClosed {
+		t.Fatalf("expect closed, got %v", err)
+	}
+}
+
+func TestDiskQueue_ConcurrentPublish(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newDiskQueue(dir, 0, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, publisher := 50, 8
+	wg := sync.WaitGroup{}
+	for p := 0; p < publisher; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if err := q.Publish(context.Background(), []byte(fmt.Sprintf("message-%d-%d", p, i))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	if q.synced != q.wOffset {
+		t.Fatalf("synced %d written %d", q.synced, q.wOffset)
+	}
+	_ = q.Close()
+
+	// all published durable, received once after reopen
+	q, err = newDiskQueue(dir, 0, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	received := map[string]bool{}
+	for _, msg := range receiveN(t, q, n*publisher) {
+		if received[string(msg.Value)] {
+			t.Fatalf("duplicate %s", msg.Value)
+		}
+		received[string(msg.Value)] = true
+	}
+	assertEmpty(t, q)
+}
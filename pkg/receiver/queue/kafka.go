@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaWriter kafka.Writer, stub in testing
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaReader kafka.Reader of consumer group, stub in testing
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaQueue publish wait all in-sync replicas ack, consumer group commit offset after acked
+type kafkaQueue struct {
+	topic string
+	w     kafkaWriter
+	r     kafkaReader
+	acks  *ackTracker
+}
+
+func NewKafkaQueue(brokers []string, topic, groupID string) (Queue, error) {
+	if len(brokers) == 0 || topic == "" || groupID == "" {
+		return nil, fmt.Errorf("kafka brokers, topic and group id required")
+	}
+	q := &kafkaQueue{
+		topic: topic,
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.LeastBytes{},
+			RequiredAcks: kafka.RequireAll,
+			// publish synchronous, not wait batch filled
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		r: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: brokers,
+			Topic:   topic,
+			GroupID: groupID,
+		}),
+		acks: newAckTracker(),
+	}
+	return q, nil
+}
+
+func (q *kafkaQueue) Publish(ctx context.Context, value []byte) error {
+	return q.w.WriteMessages(ctx, kafka.Message{Value: value})
+}
+
+func (q *kafkaQueue) Receive(ctx context.Context) (*Message, error) {
+	m, err := q.r.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q.acks.delivered(m.Partition, m.Offset, m.Offset+1)
+	return &Message{Value: m.Value, partition: m.Partition, offset: m.Offset}, nil
+}
+
+func (q *kafkaQueue) Ack(ctx context.Context, msg *Message) error {
+	next, ok := q.acks.ack(msg.partition, msg.offset)
+	if !ok {
+		return nil
+	}
+	// commit offset is message offset + 1
+	return q.r.CommitMessages(ctx, kafka.Message{Topic: q.topic, Partition: msg.partition, Offset: next - 1})
+}
+
+func (q *kafkaQueue) Close() error {
+	err := q.w.Close()
+	if e := q.r.Close(); err == nil {
+		err = e
+	}
+	return err
+}
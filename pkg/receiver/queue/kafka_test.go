@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// stubKafka in memory partitions, written round robin, committed offset per partition
+type stubKafka struct {
+	mutex      sync.Mutex
+	partitions int
+	written    int
+	messages   chan kafka.Message
+	offsets    map[int]int64
+	committed  map[int]int64
+	closed     int
+}
+
+func newStubKafka(partitions int) *stubKafka {
+	return &stubKafka{
+		partitions: partitions,
+		messages:   make(chan kafka.Message, 100),
+		offsets:    map[int]int64{},
+		committed:  map[int]int64{},
+	}
+}
+
+func (s *stubKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, m := range msgs {
+		m.Partition = s.written % s.partitions
+		m.Offset = s.offsets[m.Partition]
+		s.offsets[m.Partition]++
+		s.written++
+		s.messages <- m
+	}
+	return nil
+}
+
+func (s *stubKafka) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case m := <-s.messages:
+		return m, nil
+	}
+}
+
+func (s *stubKafka) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, m := range msgs {
+		if m.Topic != "qelog" {
+			return fmt.Errorf("commit topic %s", m.Topic)
+		}
+		if m.Offset < s.committed[m.Partition]-1 {
+			return fmt.Errorf("partition %d commit offset %d went back", m.Partition, m.Offset)
+		}
+		s.committed[m.Partition] = m.Offset + 1
+	}
+	return nil
+}
+
+func (s *stubKafka) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed++
+	return nil
+}
+
+func (s *stubKafka) commit(partition int) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.committed[partition]
+}
+
+func TestNewKafkaQueue(t *testing.T) {
+	if _, err := NewKafkaQueue(nil, "qelog", "receiver"); err == nil {
+		t.Fatal("brokers required")
+	}
+	q, err := NewKafkaQueue([]string{"127.0.0.1:9092"}, "qelog", "receiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Close()
+}
+
+func TestKafkaQueue_AckOutOfOrder(t *testing.T) {
+	stub := newStubKafka(2)
+	q := &kafkaQueue{topic: "qelog", w: stub, r: stub, acks: newAckTracker()}
+	for i := 0; i < 6; i++ {
+		if err := q.Publish(context.Background(), []byte(fmt.Sprintf("message-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// partition 0: message 0 2 4, partition 1: message 1 3 5
+	msgs := receiveN(t, q, 6)
+	for i, msg := range msgs {
+		if string(msg.Value) != fmt.Sprintf("message-%d", i) || msg.partition != i%2 {
+			t.Fatalf("message %d %s partition %d", i, msg.Value, msg.partition)
+		}
+	}
+
+	// message-2 not acked, partition 0 commit stop at it, partition 1 not affected
+	for _, i := range []int{4, 0, 5, 1, 3} {
+		if err := q.Ack(context.Background(), msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stub.commit(0) != 1 || stub.commit(1) != 3 {
+		t.Fatalf("committed %v", stub.committed)
+	}
+	if err := q.Ack(context.Background(), msgs[2]); err != nil {
+		t.Fatal(err)
+	}
+	if stub.commit(0) != 3 {
+		t.Fatalf("committed %v", stub.committed)
+	}
+	assertEmpty(t, q)
+
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stub.closed != 2 {
+		t.Fatalf("writer and reader closed %d", stub.closed)
+	}
+}
@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bbdshow/qelog/pkg/conf"
+)
+
+const (
+	TypeDisk  = "disk"
+	TypeKafka = "kafka"
+)
+
+var (
+	ErrClosed = errors.New("queue closed")
+	ErrFull   = errors.New("queue full")
+)
+
+// Queue buffering stage between receiver and db.
+// Publish returns after message durable, so packet can be acked to client before written to db.
+// message delivered at least once, not acked message redelivered after restart
+type Queue interface {
+	Publish(ctx context.Context, value []byte) error
+	// Receive block until message available, ctx done or queue closed
+	Receive(ctx context.Context) (*Message, error)
+	// Ack message handled. may be acked out of order
+	Ack(ctx context.Context, msg *Message) error
+	Close() error
+}
+
+type Message struct {
+	Value []byte
+
+	partition int
+	offset    int64
+}
+
+// New queue by config type
+func New(c conf.ReceiverQueue) (Queue, error) {
+	switch c.Type {
+	case TypeDisk:
+		return NewDiskQueue(c.Dir, c.MaxBytes)
+	case TypeKafka:
+		return NewKafkaQueue(c.KafkaBrokers, c.KafkaTopic, c.KafkaGroupID)
+	}
+	return nil, fmt.Errorf("unsupported queue type '%s'", c.Type)
+}
+
+// ackTracker messages acked out of order by writer pool,
+// commit position only advance to the first not acked message of partition, not lost message after restart
+type ackTracker struct {
+	mutex   sync.Mutex
+	pending map[int][]*pendingAck
+}
+
+type pendingAck struct {
+	offset int64
+	next   int64
+	acked  bool
+}
+
+func newAckTracker() *ackTracker {
+	return &ackTracker{pending: map[int][]*pendingAck{}}
+}
+
+// delivered must be called by delivered order
+func (t *ackTracker) delivered(partition int, offset, next int64) {
+	t.mutex.Lock()
+	t.pending[partition] = append(t.pending[partition], &pendingAck{offset: offset, next: next})
+	t.mutex.Unlock()
+}
+
+// ack returns the position can be committed, ok false commit position not changed
+func (t *ackTracker) ack(partition int, offset int64) (next int64, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	list := t.pending[partition]
+	for _, v := range list {
+		if v.offset == offset {
+			v.acked = true
+			break
+		}
+	}
+	i := 0
+	for ; i < len(list) && list[i].acked; i++ {
+		next = list[i].next
+		ok = true
+	}
+	t.pending[partition] = list[i:]
+	return next, ok
+}
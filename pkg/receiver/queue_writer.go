@@ -0,0 +1,112 @@
+package receiver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/receiver/queue"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// loggingBatch queue message. logging id assigned when decoded, redelivered message written db idempotent
+type loggingBatch struct {
+	Module string           `bson:"m"`
+	Docs   []*model.Logging `bson:"docs"`
+}
+
+// publishLogging packet acked to client when logging durable in queue, written db by queue writers
+func (svc *Service) publishLogging(ctx context.Context, m *module, docs []*model.Logging) error {
+	b, err := bson.Marshal(&loggingBatch{Module: m.m.Name, Docs: docs})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	if err := svc.queue.Publish(ctx, b); err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	return nil
+}
+
+func (svc *Service) startQueueWriters(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	svc.queueCancel = cancel
+	for i := 0; i < n; i++ {
+		svc.queueWg.Add(1)
+		go svc.queueWriter(ctx)
+	}
+}
+
+// stopQueueWriters message writing not acked, redelivered after restart
+func (svc *Service) stopQueueWriters() {
+	if svc.queueCancel != nil {
+		svc.queueCancel()
+	}
+	svc.queueWg.Wait()
+}
+
+func (svc *Service) queueWriter(ctx context.Context) {
+	defer svc.queueWg.Done()
+	for {
+		msg, err := svc.queue.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, queue.ErrClosed) {
+				return
+			}
+			logs.Qezap.Error("QueueReceive", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if !svc.writeQueueMessage(ctx, msg) {
+			return
+		}
+		if err := svc.queue.Ack(ctx, msg); err != nil {
+			logs.Qezap.Error("QueueAck", zap.Error(err))
+		}
+	}
+}
+
+// writeQueueMessage retry until written, returns false when ctx done
+func (svc *Service) writeQueueMessage(ctx context.Context, msg *queue.Message) bool {
+	batch := &loggingBatch{}
+	if err := bson.Unmarshal(msg.Value, batch); err != nil {
+		logs.Qezap.Error("QueueMessageInvalid", zap.Error(err))
+		return true
+	}
+	svc.lock.RLock()
+	m, ok := svc.modules[batch.Module]
+	svc.lock.RUnlock()
+	if !ok {
+		logs.Qezap.Warn("QueueMessageDropped", zap.String("module", batch.Module), zap.Int("docs", len(batch.Docs)))
+		return true
+	}
+
+	backoff := time.Second
+	for {
+		wctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := svc.createLogging(wctx, m, batch.Docs)
+		cancel()
+		if err == nil {
+			return true
+		}
+		logs.Qezap.Error("QueueWriteLogging", zap.Error(err), zap.String("module", batch.Module), zap.Int("docs", len(batch.Docs)))
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
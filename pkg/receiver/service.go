@@ -1,7 +1,9 @@
 package receiver
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/bbdshow/bkit/db/mongo"
 	"github.com/bbdshow/qelog/pkg/conf"
@@ -9,6 +11,7 @@ import (
 	"github.com/bbdshow/qelog/pkg/model"
 	"github.com/bbdshow/qelog/pkg/receiver/alarm"
 	"github.com/bbdshow/qelog/pkg/receiver/metrics"
+	"github.com/bbdshow/qelog/pkg/receiver/queue"
 )
 
 // Service receiver
@@ -19,9 +22,16 @@ type Service struct {
 	lock        sync.RWMutex
 	modules     map[string]*module
 	collections map[string]struct{}
+	// module last reloaded on token mismatch
+	reloadedAt map[string]time.Time
 
 	alarm   *alarm.Alarm
 	metrics *metrics.Metrics
+
+	// optional, buffering between receiver and db
+	queue       queue.Queue
+	queueCancel context.CancelFunc
+	queueWg     sync.WaitGroup
 }
 
 type module struct {
@@ -36,6 +46,7 @@ func NewService(cfg *conf.Config) *Service {
 		lock:        sync.RWMutex{},
 		modules:     map[string]*module{},
 		collections: map[string]struct{}{},
+		reloadedAt:  map[string]time.Time{},
 	}
 	svc.metrics = metrics.NewMetrics(svc.d)
 
@@ -46,8 +57,10 @@ func NewService(cfg *conf.Config) *Service {
 	go svc.bgSyncModuleSetting()
 
 	if cfg.Receiver.AlarmEnable {
-		svc.alarm = alarm.NewAlarm()
+		svc.alarm = alarm.NewAlarm(svc.d)
+		svc.alarm.SetAdminURL(cfg.Admin.ExternalURL)
 		go svc.bgSyncAlarmRuleSetting()
+		go svc.bgSyncAlarmState()
 	}
 
 	if cfg.Receiver.MetricsEnable {
@@ -55,10 +68,23 @@ func NewService(cfg *conf.Config) *Service {
 		metrics.SetIncIntervalSec(30)
 	}
 
+	if cfg.Receiver.Queue.Type != "" {
+		q, err := queue.New(cfg.Receiver.Queue)
+		if err != nil {
+			panic(err)
+		}
+		svc.queue = q
+		svc.startQueueWriters(cfg.Receiver.Queue.Writers)
+	}
+
 	return svc
 }
 
 func (svc *Service) Close() {
+	if svc.queue != nil {
+		svc.stopQueueWriters()
+		_ = svc.queue.Close()
+	}
 	if svc.d != nil {
 		svc.d.Close()
 	}
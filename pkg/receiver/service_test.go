@@ -1,10 +1,14 @@
 package receiver
 
 import (
+	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/bbdshow/qelog/pkg/conf"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 var svc *Service
@@ -16,3 +20,37 @@ func TestMain(m *testing.M) {
 	svc = NewService(conf.Conf)
 	os.Exit(m.Run())
 }
+
+func TestService_VerifyRotatedAccessToken(t *testing.T) {
+	ctx := context.Background()
+	name := "rotated_token_testing"
+	doc := &model.Module{Name: name, Bucket: "rotate", Prefix: "lg", AccessToken: "previous", UpdatedAt: time.Now()}
+	if err := svc.d.CreateModule(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	defer svc.d.DelModule(ctx, bson.M{"name": name})
+	if err := svc.updateModuleSetting(); err != nil {
+		t.Fatal(err)
+	}
+	svc.lock.RLock()
+	m := svc.modules[name]
+	svc.lock.RUnlock()
+
+	// rotated without grace period, before next module setting sync
+	if err := svc.d.UpdateModuleToken(ctx, m.m, "current", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	v, err := svc.verifyAccessToken(ctx, m, "current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.m.AccessToken != "current" {
+		t.Fatalf("module not reloaded %v", v.m)
+	}
+	if _, err := svc.verifyAccessToken(ctx, v, "previous"); err == nil {
+		t.Fatal("previous token should invalid")
+	}
+	if _, ok := svc.reloadModule(ctx, name); ok {
+		t.Fatal("module reloaded again in reload interval")
+	}
+}
@@ -2,17 +2,25 @@ package grpc
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/bkit/logs"
 	"github.com/bbdshow/bkit/runner"
 	"github.com/bbdshow/bkit/util/inet"
+	"github.com/bbdshow/qelog/api"
 	"github.com/bbdshow/qelog/api/receiverpb"
 	"github.com/bbdshow/qelog/pkg/conf"
 	"github.com/bbdshow/qelog/pkg/receiver"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 )
 
@@ -20,24 +28,127 @@ var (
 	receiverSvc *receiver.Service
 )
 
+// ReceiverGrpc impl runner.Server, runner.GrpcServer not support server options, TLS required
 type ReceiverGrpc struct {
-	*runner.GrpcServer
+	cfg    *conf.Config
+	server *grpc.Server
 }
 
-func NewReceiverGRpc(_ *conf.Config, svc *receiver.Service) runner.Server {
+func NewReceiverGRpc(cfg *conf.Config, svc *receiver.Service) runner.Server {
 	receiverSvc = svc
-	rpc := &ReceiverGrpc{
-		GrpcServer: runner.NewGrpcServer(),
+	return &ReceiverGrpc{cfg: cfg}
+}
+
+func (rpc *ReceiverGrpc) Run(c *runner.Config) error {
+	tlsCfg, err := rpc.cfg.Receiver.TLSConfig()
+	if err != nil {
+		return err
+	}
+	opts := make([]grpc.ServerOption, 0, 1)
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+
+	listen, err := net.Listen("tcp", c.ListenAddr)
+	if err != nil {
+		return err
+	}
+	rpc.server = grpc.NewServer(opts...)
+	receiverpb.RegisterReceiverServer(rpc.server, rpc)
+
+	log.Printf("grpc server %s tls %t\n", c, tlsCfg != nil)
+	go func() {
+		if err := rpc.server.Serve(listen); err != nil {
+			panic(fmt.Sprintf("grpc serve listen %v", err))
+		}
+	}()
+	return nil
+}
+
+// Shutdown graceful stop, push stream keep open by client, force stop if timeout
+func (rpc *ReceiverGrpc) Shutdown(ctx context.Context) error {
+	if rpc.server != nil {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			rpc.server.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			rpc.server.Stop()
+		}
 	}
-	rpc.RunAfter(func(s *grpc.Server) error {
-		receiverpb.RegisterReceiverServer(s, rpc)
-		return nil
-	})
-	return rpc
+	log.Printf("grpc server shutdown \n")
+	return nil
 }
 
 func (rpc *ReceiverGrpc) PushPacket(ctx context.Context, in *receiverpb.Packet) (*receiverpb.BaseResp, error) {
-	if err := receiverSvc.PacketToLogging(ctx, clientIP(ctx), in); err != nil {
+	return packetResp(receiverSvc.PacketToLogging(ctx, clientIP(ctx), accessToken(ctx), in))
+}
+
+// packets of one stream written concurrently, client multiplex all pushes on a stream
+const streamConcurrent = 8
+
+// PushStream every packet received from stream, written and ack by packet id.
+// client can send continuously without waiting ack, save one round trip each packet.
+// packets written by bounded workers, ack not in received order
+func (rpc *ReceiverGrpc) PushStream(stream receiverpb.Receiver_PushStreamServer) error {
+	ctx := stream.Context()
+	ip, token := clientIP(ctx), accessToken(ctx)
+
+	var (
+		wg sync.WaitGroup
+		// grpc stream send not concurrent safe
+		sendMutex sync.Mutex
+		workers   = make(chan struct{}, streamConcurrent)
+	)
+	// acks of written packets sent before stream returned
+	defer wg.Wait()
+	for {
+		in, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(in *receiverpb.Packet) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			ack := &receiverpb.PacketAck{Id: in.Id}
+			resp, err := packetResp(receiverSvc.PacketToLogging(ctx, ip, token, in))
+			if err != nil {
+				// client should retry this packet
+				ack.Code = int32(errc.InternalErr)
+				ack.Message = err.Error()
+			} else {
+				ack.Code = resp.Code
+				ack.Message = resp.Message
+			}
+			sendMutex.Lock()
+			err = stream.Send(ack)
+			sendMutex.Unlock()
+			// stream broken, Recv returns error too
+			if err != nil {
+				logs.Qezap.Error("PushStream", zap.String("ack", err.Error()))
+			}
+		}(in)
+	}
+}
+
+func packetResp(err error) (*receiverpb.BaseResp, error) {
+	if err != nil {
 		e, ok := err.(errc.Error)
 		if ok {
 			if e.Code == errc.InternalErr {
@@ -57,6 +168,18 @@ func (rpc *ReceiverGrpc) PushPacket(ctx context.Context, in *receiverpb.Packet)
 	}, nil
 }
 
+// accessToken module access token from incoming metadata
+func accessToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(api.AccessTokenKey); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
 func clientIP(ctx context.Context) string {
 	ctxPeer, ok := peer.FromContext(ctx)
 	if ok && ctxPeer.Addr != nil {
@@ -1,12 +1,19 @@
 package http
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bbdshow/bkit/auth/jwt"
 	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/bkit/ginutil"
 	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/query"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,7 +29,8 @@ func login(c *gin.Context) {
 		return
 	}
 
-	claims := jwt.NewCustomClaims("", 72*time.Hour)
+	// username as claims data, record operator
+	claims := jwt.NewCustomClaims(in.Username, 72*time.Hour)
 	token, err := jwt.GenerateJWTToken(claims)
 	if err != nil {
 		ginutil.RespErr(c, errc.ErrAuthInternalErr.MultiMsg("system exception,try again"))
@@ -87,6 +95,20 @@ func delModule(c *gin.Context) {
 	ginutil.RespSuccess(c)
 }
 
+func rotateModuleToken(c *gin.Context) {
+	in := &model.RotateModuleTokenReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.RotateModuleTokenResp{}
+	if err := adminSvc.RotateModuleToken(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
 func findAlarmRuleList(c *gin.Context) {
 	in := &model.FindAlarmRuleListReq{}
 	if err := ginutil.ShouldBind(c, in); err != nil {
@@ -207,13 +229,27 @@ func pingHookURL(c *gin.Context) {
 	ginutil.RespSuccess(c)
 }
 
+func previewAlarmTemplate(c *gin.Context) {
+	in := &model.PreviewAlarmTemplateReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.PreviewAlarmTemplateResp{}
+	if err := adminSvc.PreviewAlarmTemplate(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
 func findLoggingList(c *gin.Context) {
 	in := &model.FindLoggingListReq{}
 	if err := ginutil.ShouldBind(c, in); err != nil {
 		ginutil.RespErr(c, err)
 		return
 	}
-	out := &model.ListResp{}
+	out := &model.FindLoggingListResp{}
 	if err := adminSvc.FindLoggingList(c.Request.Context(), in, out); err != nil {
 		ginutil.RespErr(c, err)
 		return
@@ -221,6 +257,146 @@ func findLoggingList(c *gin.Context) {
 	ginutil.RespData(c, out)
 }
 
+func queryLogging(c *gin.Context) {
+	in := &model.QueryLoggingReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.FindLoggingListResp{}
+	if err := adminSvc.QueryLogging(c.Request.Context(), in, out); err != nil {
+		// syntax error response position, so that the front end can mark it
+		var qErr *query.Error
+		if errors.As(err, &qErr) {
+			ginutil.Resp(c, http.StatusOK, &model.QueryLoggingErr{Pos: qErr.Pos, Message: qErr.Msg},
+				errc.ErrParamInvalid.MultiMsg(qErr.Error()))
+			return
+		}
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+// tailLogging live tail by Server-Sent Events, event id is last logging ts,
+// client reconnect with Last-Event-ID header continue tail
+func tailLogging(c *gin.Context) {
+	in := &model.TailLoggingReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	since, _ := strconv.ParseInt(c.GetHeader("Last-Event-ID"), 10, 64)
+
+	started := false
+	lastWrite := time.Now()
+	err := adminSvc.TailLogging(c.Request.Context(), in, since, func(list []*model.FindLoggingList, lastTsSec int64) error {
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			// disable nginx proxy buffering
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+		} else if len(list) == 0 && time.Since(lastWrite) < tailKeepAlive {
+			return nil
+		}
+		lastWrite = time.Now()
+		if len(list) == 0 {
+			if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+				return err
+			}
+		} else {
+			data, err := json.Marshal(list)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: logging\ndata: %s\n\n", lastTsSec, data); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil && !started {
+		ginutil.RespErr(c, err)
+	}
+}
+
+const tailKeepAlive = 15 * time.Second
+
+// exportLogging download logging file, rows and truncated set in http trailer, because response header sent before export finished.
+// error after file started set in X-Export-Error trailer, file incomplete
+func exportLogging(c *gin.Context) {
+	in := &model.ExportLoggingReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	operator, _ := ginutil.GetJWTDataFromContext(c)
+	audit := &model.ExportAudit{Operator: operator, IP: ginutil.ClientIP(c)}
+	w := &exportWriter{c: c, in: in}
+	err := adminSvc.ExportLogging(c.Request.Context(), in, w, audit)
+	if !w.started {
+		if err != nil {
+			ginutil.RespErr(c, err)
+			return
+		}
+		// empty file
+		w.start()
+	}
+	_ = w.Close()
+	c.Writer.Header().Set("X-Export-Rows", strconv.FormatInt(audit.Rows, 10))
+	c.Writer.Header().Set("X-Export-Truncated", strconv.FormatBool(audit.Truncated))
+	if err != nil {
+		c.Writer.Header().Set("X-Export-Error", err.Error())
+	}
+}
+
+// exportWriter response header written when first write, so error before it can response json
+type exportWriter struct {
+	c       *gin.Context
+	in      *model.ExportLoggingReq
+	started bool
+	gz      *gzip.Writer
+}
+
+func (w *exportWriter) start() {
+	w.started = true
+	contentType := "application/x-ndjson"
+	if w.in.Format == model.ExportFormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	filename := fmt.Sprintf("%s_%s.%s", w.in.ModuleName, time.Now().Format("20060102150405"), w.in.Format)
+	if w.in.Gzip {
+		contentType = "application/gzip"
+		filename += ".gz"
+		w.gz = gzip.NewWriter(w.c.Writer)
+	}
+	w.c.Header("Content-Type", contentType)
+	w.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.c.Header("Trailer", "X-Export-Rows, X-Export-Truncated, X-Export-Error")
+	w.c.Status(http.StatusOK)
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.start()
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.c.Writer.Write(p)
+}
+
+func (w *exportWriter) Close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
 func findLoggingByTraceId(c *gin.Context) {
 	in := &model.FindLoggingByTraceIDReq{}
 	if err := ginutil.ShouldBind(c, in); err != nil {
@@ -298,3 +474,114 @@ func dropLoggingCollection(c *gin.Context) {
 	}
 	ginutil.RespSuccess(c)
 }
+
+func findArchiveList(c *gin.Context) {
+	in := &model.FindArchiveListReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.ListResp{}
+	if err := adminSvc.FindArchiveList(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+func restoreArchive(c *gin.Context) {
+	in := &model.RestoreArchiveReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.RestoreArchiveResp{}
+	if err := adminSvc.RestoreArchive(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+func findMigrationJobList(c *gin.Context) {
+	in := &model.FindMigrationJobListReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.ListResp{}
+	if err := adminSvc.FindMigrationJobList(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+func getMigrationJob(c *gin.Context) {
+	in := &model.GetMigrationJobReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.FindMigrationJobList{}
+	if err := adminSvc.GetMigrationJob(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+func createMigrationJob(c *gin.Context) {
+	in := &model.CreateMigrationJobReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.CreateMigrationJobResp{}
+	if err := adminSvc.CreateMigrationJob(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+func cancelMigrationJob(c *gin.Context) {
+	in := &model.CancelMigrationJobReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	if err := adminSvc.CancelMigrationJob(c.Request.Context(), in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespSuccess(c)
+}
+
+func findShardList(c *gin.Context) {
+	in := &model.FindShardListReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.ListResp{}
+	if err := adminSvc.FindShardList(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
+
+func rebalanceAdvice(c *gin.Context) {
+	in := &model.RebalanceAdviceReq{}
+	if err := ginutil.ShouldBind(c, in); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	out := &model.RebalanceAdviceResp{}
+	if err := adminSvc.RebalanceAdvice(c.Request.Context(), in, out); err != nil {
+		ginutil.RespErr(c, err)
+		return
+	}
+	ginutil.RespData(c, out)
+}
@@ -1,6 +1,12 @@
 package http
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+
 	"github.com/bbdshow/bkit/ginutil"
 	"github.com/bbdshow/bkit/runner"
 	"github.com/bbdshow/qelog/pkg/admin"
@@ -23,8 +29,8 @@ func NewAdminHttpServer(c *conf.Config, svc *admin.Service) runner.Server {
 	if cfg.Release() {
 		midFlag = ginutil.MRelease | ginutil.MTraceId | ginutil.MRecoverLogger
 	}
-	// skip static file log
-	ginutil.AddSkipPaths("/static/*filepath", "/admin/*filepath")
+	// skip static file log, tail stream and export file not dump body
+	ginutil.AddSkipPaths("/static/*filepath", "/admin/*filepath", "/v1/logging/tail", "/v1/logging/export")
 
 	httpHandler := ginutil.DefaultEngine(midFlag)
 	registerAdminRouter(httpHandler)
@@ -41,6 +47,7 @@ func registerAdminRouter(e *gin.Engine) {
 		v1.POST("/module", createModule)
 		v1.PUT("/module", updateModule)
 		v1.DELETE("/module", delModule)
+		v1.PUT("/module/token", rotateModuleToken)
 	}
 
 	// alarm rule set
@@ -54,6 +61,7 @@ func registerAdminRouter(e *gin.Engine) {
 		v1.PUT("/alarmRule/hook", updateHookURL)
 		v1.DELETE("/alarmRule/hook", delHookURL)
 		v1.GET("/alarmRule/hook/ping", pingHookURL)
+		v1.POST("/alarmRule/template/preview", previewAlarmTemplate)
 	}
 
 	// log query
@@ -61,8 +69,28 @@ func registerAdminRouter(e *gin.Engine) {
 		// Why use POST as query request method? The query condition text may be truncated because it is too large
 		v1.POST("/logging/list", findLoggingList)
 		v1.POST("/logging/traceid", findLoggingByTraceId)
+		v1.POST("/logging/query", queryLogging)
+		v1.GET("/logging/tail", tailLogging)
+		v1.POST("/logging/export", exportLogging)
 		v1.DELETE("/logging/collection", dropLoggingCollection)
 	}
+	// expired shard archive
+	{
+		v1.GET("/archive/list", findArchiveList)
+		v1.POST("/archive/restore", restoreArchive)
+	}
+	// shard migration between receiver databases
+	{
+		v1.GET("/migration/list", findMigrationJobList)
+		v1.GET("/migration", getMigrationJob)
+		v1.POST("/migration", createMigrationJob)
+		v1.DELETE("/migration", cancelMigrationJob)
+	}
+	// shard catalog and receiver database placement
+	{
+		v1.GET("/shard/list", findShardList)
+		v1.GET("/placement/rebalance", rebalanceAdvice)
+	}
 	// log metrics
 	{
 		v1.GET("/metrics/dbStats", metricsDBStats)
@@ -88,9 +116,57 @@ func NewReceiverHttpServer(c *conf.Config, svc *receiver.Service) runner.Server
 	httpHandler := ginutil.DefaultEngine(midFlag)
 	registerReceiverRouter(httpHandler)
 
-	return runner.NewHttpServer(httpHandler)
+	return &tlsHttpServer{handler: httpHandler, tlsConfig: cfg.Receiver.TLSConfig}
+}
+
+// tlsHttpServer impl runner.Server, runner.HttpServer not support TLS
+type tlsHttpServer struct {
+	handler   http.Handler
+	tlsConfig func() (*tls.Config, error)
+	httpSrv   *http.Server
+}
+
+func (s *tlsHttpServer) Run(c *runner.Config) error {
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.httpSrv = &http.Server{
+		Addr:         c.ListenAddr,
+		Handler:      s.handler,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		TLSConfig:    tlsCfg,
+	}
+	log.Printf("http server %s tls %t\n", c, tlsCfg != nil)
+
+	go func() {
+		var err error
+		if tlsCfg != nil {
+			// certificate loaded in TLSConfig
+			err = s.httpSrv.ListenAndServeTLS("", "")
+		} else {
+			err = s.httpSrv.ListenAndServe()
+		}
+		if err != nil {
+			if err != http.ErrServerClosed {
+				panic(fmt.Sprintf("http ListenAndServe %v", err))
+			}
+			log.Printf("http ListenAndServe %v\n", err)
+		}
+	}()
+	return nil
+}
+
+func (s *tlsHttpServer) Shutdown(ctx context.Context) error {
+	var err error
+	if s.httpSrv != nil {
+		err = s.httpSrv.Shutdown(ctx)
+	}
+	log.Printf("http server shutdown %v\n", err)
+	return err
 }
 
 func registerReceiverRouter(e *gin.Engine) {
-	e.POST("/v1/receiver/packet", receiverPacket)
+	e.POST("/v1/receiver/packet", contentDecoding, receiverPacket)
 }
@@ -1,11 +1,41 @@
 package http
 
 import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/bbdshow/bkit/errc"
 	"github.com/bbdshow/bkit/ginutil"
 	"github.com/bbdshow/qelog/api"
+	"github.com/bbdshow/qelog/api/receiverpb"
 	"github.com/gin-gonic/gin"
 )
 
+// contentDecoding decompress request body, if Content-Encoding gzip
+func contentDecoding(c *gin.Context) {
+	if !strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		c.Next()
+		return
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		ginutil.RespErr(c, errc.ErrParamInvalid.MultiErr(err))
+		c.Abort()
+		return
+	}
+	data, err := receiverpb.Decompress(receiverpb.Compression_GZIP, body)
+	if err != nil {
+		ginutil.RespErr(c, errc.ErrParamInvalid.MultiErr(err))
+		c.Abort()
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	c.Request.ContentLength = int64(len(data))
+	c.Request.Header.Del("Content-Encoding")
+	c.Next()
+}
+
 func receiverPacket(c *gin.Context) {
 	in := &api.JSONPacket{}
 	if err := ginutil.ShouldBind(c, in); err != nil {
@@ -13,7 +43,7 @@ func receiverPacket(c *gin.Context) {
 		return
 	}
 
-	if err := receiverSvc.JSONPacketToLogging(c.Request.Context(), c.ClientIP(), in); err != nil {
+	if err := receiverSvc.JSONPacketToLogging(c.Request.Context(), c.ClientIP(), c.GetHeader(api.AccessTokenKey), in); err != nil {
 		ginutil.RespErr(c, err)
 		return
 	}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/runner"
+	"github.com/bbdshow/qelog/pkg/conf"
+	"github.com/bbdshow/qelog/pkg/receiver"
+	"github.com/bbdshow/qelog/pkg/server/grpc"
+	"github.com/bbdshow/qelog/pkg/server/http"
+)
+
+// ReceiverServer run grpc and http receiver server under one lifecycle.
+// listen address from conf.Receiver, if HttpListenAddr empty, http server disabled
+type ReceiverServer struct {
+	cfg *conf.Config
+
+	rpc  runner.Server
+	http runner.Server
+}
+
+func NewReceiverServer(cfg *conf.Config, svc *receiver.Service) runner.Server {
+	s := &ReceiverServer{
+		cfg: cfg,
+		rpc: grpc.NewReceiverGRpc(cfg, svc),
+	}
+	if cfg.Receiver.HttpListenAddr != "" {
+		s.http = http.NewReceiverHttpServer(cfg, svc)
+	}
+	return s
+}
+
+func (s *ReceiverServer) Run(c *runner.Config) error {
+	rpcCfg := *c
+	rpcCfg.ListenAddr = s.cfg.Receiver.RpcListenAddr
+	if err := s.rpc.Run(&rpcCfg); err != nil {
+		return err
+	}
+
+	if s.http == nil {
+		log.Printf("receiver http server disabled\n")
+		return nil
+	}
+	httpCfg := *c
+	httpCfg.ListenAddr = s.cfg.Receiver.HttpListenAddr
+	if err := s.http.Run(&httpCfg); err != nil {
+		_ = s.rpc.Shutdown(c.Context)
+		return err
+	}
+	return nil
+}
+
+// Shutdown http server graceful shutdown first, then grpc server
+func (s *ReceiverServer) Shutdown(ctx context.Context) error {
+	var err error
+	if s.http != nil {
+		err = s.http.Shutdown(ctx)
+	}
+	return errc.MultiError(err, s.rpc.Shutdown(ctx))
+}
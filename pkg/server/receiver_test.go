@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bbdshow/bkit/runner"
+	"github.com/bbdshow/qelog/pkg/admin"
+	"github.com/bbdshow/qelog/pkg/conf"
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/receiver"
+	"github.com/bbdshow/qelog/qezap"
+)
+
+func TestMain(m *testing.M) {
+	if err := conf.InitConf("../../configs/config.toml"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// TestReceiverServer_HttpPush qezap http transport push to live receiver http server, query by traceId
+func TestReceiverServer_HttpPush(t *testing.T) {
+	testPush(t, conf.Conf, "http_push_testing", qezap.TransportHTTP, 1)
+}
+
+// TestReceiverServer_Embedded same as http push, admin and receiver without mongo
+func TestReceiverServer_Embedded(t *testing.T) {
+	cfg := *conf.Conf
+	cfg.Storage.Dir = t.TempDir()
+	cfg.SetEmbedded()
+	testPush(t, &cfg, "http_push_testing", qezap.TransportHTTP, 1)
+
+	// restart read persisted module and logging
+	adminSvc := admin.NewService(&cfg)
+	defer adminSvc.Close()
+	modules := &model.ListResp{}
+	if err := adminSvc.FindModuleList(context.Background(), &model.FindModuleListReq{PageReq: model.PageReq{Page: 1, Limit: 10}}, modules); err != nil {
+		t.Fatal(err)
+	}
+	// self-access module and testing module
+	if modules.Count != 2 {
+		t.Fatalf("module count %d, want 2", modules.Count)
+	}
+	// shard registered by receiver
+	shards := &model.ListResp{}
+	if err := adminSvc.FindShardList(context.Background(), &model.FindShardListReq{ModuleName: "http_push_testing"}, shards); err != nil {
+		t.Fatal(err)
+	}
+	if shards.Count != 1 {
+		t.Fatalf("shard count %d, want 1", shards.Count)
+	}
+}
+
+// TestReceiverServer_EmbeddedGrpcStream many packets pushed on one grpc stream, written concurrently and all acked
+func TestReceiverServer_EmbeddedGrpcStream(t *testing.T) {
+	cfg := *conf.Conf
+	cfg.Storage.Dir = t.TempDir()
+	cfg.SetEmbedded()
+	testPush(t, &cfg, "grpc_push_testing", qezap.TransportGRPC, 200)
+}
+
+// testPush n logging pushed by transport, query by traceId
+func testPush(t *testing.T, c *conf.Config, name string, transport qezap.Transport, n int) {
+	ctx := context.Background()
+	adminSvc := admin.NewService(c)
+	defer adminSvc.Close()
+	_ = adminSvc.CreateModule(ctx, &model.CreateModuleReq{Name: name})
+	modules := &model.ListResp{}
+	if err := adminSvc.FindModuleList(ctx, &model.FindModuleListReq{Name: name, PageReq: model.PageReq{Page: 1, Limit: 1}}, modules); err != nil {
+		t.Fatal(err)
+	}
+	list := modules.List.([]*model.FindModuleList)
+	if len(list) != 1 {
+		t.Fatalf("module %s not found", name)
+	}
+
+	cfg := *c
+	cfg.Receiver.RpcListenAddr = freeAddr(t)
+	cfg.Receiver.HttpListenAddr = freeAddr(t)
+	receiverSvc := receiver.NewService(&cfg)
+	defer receiverSvc.Close()
+
+	srv := NewReceiverServer(&cfg, receiverSvc)
+	if err := srv.Run(new(runner.Config).Init()); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(ctx)
+
+	url := fmt.Sprintf("http://%s/v1/receiver/packet", cfg.Receiver.HttpListenAddr)
+	// wait http server listen
+	for i := 0; i < 50; i++ {
+		if resp, err := nethttp.Get(url); err == nil {
+			_ = resp.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	addr := url
+	if transport == qezap.TransportGRPC {
+		addr = cfg.Receiver.RpcListenAddr
+	}
+	lg := qezap.New(qezap.WithFilename(fmt.Sprintf("./log/%s.log", name)),
+		qezap.WithAddrsAndModuleName([]string{addr}, name),
+		qezap.WithTransport(transport),
+		// small packet, many packets on one stream
+		qezap.WithRemotePacketSize(1),
+		qezap.WithAccessToken(list[0].AccessToken))
+	defer os.RemoveAll("./log")
+	traceCtx := lg.WithTraceID(ctx)
+	for i := 0; i < n; i++ {
+		lg.Info(fmt.Sprintf("push testing %d", i), lg.FieldTraceID(traceCtx))
+	}
+	_ = lg.Sync()
+	defer lg.Close()
+
+	// pushed asynchronous, wait written
+	out := &model.ListResp{}
+	in := &model.FindLoggingByTraceIDReq{ModuleName: name, TraceID: lg.TraceID(traceCtx).Hex()}
+	for i := 0; i < 100; i++ {
+		if err := adminSvc.FindLoggingByTraceID(ctx, in, out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Count >= int64(n) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if out.Count != int64(n) {
+		t.Fatalf("logging count %d, want %d", out.Count, n)
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bolt file locked by process, admin and receiver service in same process share opened file
+var (
+	boltMutex sync.Mutex
+	boltFiles = map[string]*boltFile{}
+)
+
+type boltFile struct {
+	db   *bolt.DB
+	refs int
+}
+
+func openBolt(path string) (*bolt.DB, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	boltMutex.Lock()
+	defer boltMutex.Unlock()
+	if f, ok := boltFiles[path]; ok {
+		f.refs++
+		return f.db, nil
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	boltFiles[path] = &boltFile{db: db, refs: 1}
+	return db, nil
+}
+
+// closeBolt closed when last reference released
+func closeBolt(db *bolt.DB) error {
+	path, err := filepath.Abs(db.Path())
+	if err != nil {
+		return err
+	}
+	boltMutex.Lock()
+	defer boltMutex.Unlock()
+	f, ok := boltFiles[path]
+	if !ok || f.db != db {
+		return db.Close()
+	}
+	f.refs--
+	if f.refs > 0 {
+		return nil
+	}
+	delete(boltFiles, path)
+	return db.Close()
+}
@@ -0,0 +1,569 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const chTable = "logging"
+
+var chIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ClickHouseConfig http interface connection and insert batching
+type ClickHouseConfig struct {
+	Addr          string // e.g. http://127.0.0.1:8123
+	Username      string
+	Password      string
+	BatchSize     int           // rows merged in one insert
+	FlushInterval time.Duration // batch max waiting time
+}
+
+// ClickHouseStore logging of all modules in one table of database, partitioned by module and day.
+// shard collection name as column, so shard list, query and drop same as mongo collection.
+// concurrent writes merged in one insert batch, written returns after batch inserted
+type ClickHouseStore struct {
+	cfg    ClickHouseConfig
+	client *http.Client
+
+	mutex   sync.Mutex
+	batches map[string]*chBatch
+	tables  map[string]struct{}
+}
+
+type chBatch struct {
+	dbName string
+	rows   bytes.Buffer
+	n      int
+	once   sync.Once
+	done   chan struct{}
+	err    error
+}
+
+// chRow logging column, id hex of ObjectID
+type chRow struct {
+	Shard      string `json:"shard,omitempty"`
+	ID         string `json:"id"`
+	Module     string `json:"m"`
+	IP         string `json:"ip"`
+	Level      int32  `json:"l"`
+	Short      string `json:"s"`
+	Full       string `json:"f"`
+	Condition1 string `json:"c1"`
+	Condition2 string `json:"c2"`
+	Condition3 string `json:"c3"`
+	TraceID    string `json:"ti"`
+	TimeMill   int64  `json:"tm"`
+	TimeSec    int64  `json:"ts"`
+	MessageID  string `json:"mi"`
+}
+
+const chSelectColumns = "id, m, ip, l, s, f, c1, c2, c3, ti, tm, ts, mi"
+
+func (r chRow) Logging() (*model.Logging, error) {
+	id, err := primitive.ObjectIDFromHex(r.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Logging{
+		ID:         id,
+		Module:     r.Module,
+		IP:         r.IP,
+		Level:      types.Level(r.Level),
+		Short:      r.Short,
+		Full:       r.Full,
+		Condition1: r.Condition1,
+		Condition2: r.Condition2,
+		Condition3: r.Condition3,
+		TraceID:    r.TraceID,
+		TimeMill:   r.TimeMill,
+		TimeSec:    r.TimeSec,
+		MessageID:  r.MessageID,
+	}, nil
+}
+
+func NewClickHouseStore(cfg ClickHouseConfig) (*ClickHouseStore, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("clickhouse addr required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10000
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	cfg.Addr = strings.TrimRight(cfg.Addr, "/")
+	return &ClickHouseStore{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: time.Minute},
+		batches: map[string]*chBatch{},
+		tables:  map[string]struct{}{},
+	}, nil
+}
+
+func tableName(dbName string) (string, error) {
+	if !chIdentifier.MatchString(dbName) {
+		return "", fmt.Errorf("invalid database name '%s'", dbName)
+	}
+	return fmt.Sprintf("`%s`.%s", dbName, chTable), nil
+}
+
+// do execute query, body not nil, query in url and body as insert data. returns response body
+func (s *ClickHouseStore) do(ctx context.Context, query string, body io.Reader) ([]byte, error) {
+	params := url.Values{}
+	params.Set("output_format_json_quote_64bit_integers", "0")
+	if body == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Addr+"/?"+params.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Username != "" {
+		req.Header.Set("X-ClickHouse-User", s.cfg.Username)
+		req.Header.Set("X-ClickHouse-Key", s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("clickhouse %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// selectRows query FORMAT JSONEachRow, decode each line by fn
+func (s *ClickHouseStore) selectRows(ctx context.Context, query string, fn func(line []byte) error) error {
+	b, err := s.do(ctx, query+" FORMAT JSONEachRow", nil)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), len(b)+1)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *ClickHouseStore) selectLogging(ctx context.Context, query string) ([]*model.Logging, error) {
+	docs := make([]*model.Logging, 0)
+	err := s.selectRows(ctx, query, func(line []byte) error {
+		row := chRow{}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		l, err := row.Logging()
+		if err != nil {
+			return err
+		}
+		docs = append(docs, l)
+		return nil
+	})
+	return docs, err
+}
+
+// isUnknownTable table not created, no logging written
+func isUnknownTable(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, v := range []string{"UNKNOWN_TABLE", "UNKNOWN_DATABASE", "Code: 60.", "Code: 81."} {
+		if strings.Contains(err.Error(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateLoggingIndex create database and logging table, shard collection is column not need create.
+// full-text search by scan, not create text index
+func (s *ClickHouseStore) CreateLoggingIndex(ctx context.Context, dbName, _ string, _ bool) error {
+	s.mutex.Lock()
+	_, ok := s.tables[dbName]
+	s.mutex.Unlock()
+	if ok {
+		return nil
+	}
+	table, err := tableName(dbName)
+	if err != nil {
+		return err
+	}
+	if _, err := s.do(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", dbName), nil); err != nil {
+		return errc.WithStack(err)
+	}
+	// ReplacingMergeTree, redelivered logging same id merged
+	ddl := `CREATE TABLE IF NOT EXISTS ` + table + ` (
+	shard LowCardinality(String),
+	id FixedString(24),
+	m LowCardinality(String),
+	ip String,
+	l Int32,
+	s String,
+	f String CODEC(ZSTD(3)),
+	c1 String,
+	c2 String,
+	c3 String,
+	ti String,
+	tm Int64,
+	ts Int64,
+	mi String,
+	INDEX idx_ti ti TYPE bloom_filter GRANULARITY 4
+) ENGINE = ReplacingMergeTree
+PARTITION BY (m, toYYYYMMDD(toDateTime(ts)))
+ORDER BY (m, shard, ts, id)`
+	if _, err := s.do(ctx, ddl, nil); err != nil {
+		return errc.WithStack(err)
+	}
+	s.mutex.Lock()
+	s.tables[dbName] = struct{}{}
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *ClickHouseStore) CreateLoggingTextIndex(context.Context, string, string) error {
+	return nil
+}
+
+func (s *ClickHouseStore) DropLoggingTextIndex(context.Context, string, string) error {
+	return nil
+}
+
+// CreateManyLogging rows appended to database batch, flushed when batch size reached or flush interval
+func (s *ClickHouseStore) CreateManyLogging(ctx context.Context, dbName, cName string, docs []interface{}) error {
+	rows := bytes.Buffer{}
+	enc := json.NewEncoder(&rows)
+	for _, v := range docs {
+		l, err := toLogging(v)
+		if err != nil {
+			return errc.WithStack(err)
+		}
+		row := chRow{
+			Shard:      cName,
+			ID:         l.ID.Hex(),
+			Module:     l.Module,
+			IP:         l.IP,
+			Level:      int32(l.Level),
+			Short:      l.Short,
+			Full:       l.Full,
+			Condition1: l.Condition1,
+			Condition2: l.Condition2,
+			Condition3: l.Condition3,
+			TraceID:    l.TraceID,
+			TimeMill:   l.TimeMill,
+			TimeSec:    l.TimeSec,
+			MessageID:  l.MessageID,
+		}
+		if err := enc.Encode(row); err != nil {
+			return errc.WithStack(err)
+		}
+	}
+
+	s.mutex.Lock()
+	b, ok := s.batches[dbName]
+	if !ok {
+		b = &chBatch{dbName: dbName, done: make(chan struct{})}
+		s.batches[dbName] = b
+		time.AfterFunc(s.cfg.FlushInterval, func() { s.flush(b) })
+	}
+	b.rows.Write(rows.Bytes())
+	b.n += len(docs)
+	full := b.n >= s.cfg.BatchSize
+	if full {
+		delete(s.batches, dbName)
+	}
+	s.mutex.Unlock()
+	if full {
+		go s.flush(b)
+	}
+
+	select {
+	case <-b.done:
+		return errc.WithStack(b.err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *ClickHouseStore) flush(b *chBatch) {
+	b.once.Do(func() {
+		s.mutex.Lock()
+		if s.batches[b.dbName] == b {
+			delete(s.batches, b.dbName)
+		}
+		s.mutex.Unlock()
+
+		table, err := tableName(b.dbName)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			_, err = s.do(ctx, "INSERT INTO "+table+" FORMAT JSONEachRow", &b.rows)
+			cancel()
+		}
+		if err != nil {
+			logs.Qezap.Error("ClickHouseInsert", zap.String("database", b.dbName), zap.Int("rows", b.n), zap.Error(err))
+		}
+		b.err = err
+		close(b.done)
+	})
+}
+
+// FindLogging count and find query concurrently, count limited MaxCount
+func (s *ClickHouseStore) FindLogging(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, page model.PageReq) (int64, []*model.Logging, error) {
+	table, err := tableName(dbName)
+	if err != nil {
+		return 0, nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	where, err := sqlWhere(filter)
+	if err != nil {
+		return 0, nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	shard := "shard = " + sqlString(cName)
+	countResp := make(chan int64, 1)
+	go func() {
+		cctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+		c := int64(0)
+		err := s.selectRows(cctx, fmt.Sprintf("SELECT count() AS c FROM (SELECT 1 FROM %s WHERE %s AND %s LIMIT %d)", table, shard, where, MaxCount),
+			func(line []byte) error {
+				v := struct {
+					C int64 `json:"c"`
+				}{}
+				err := json.Unmarshal(line, &v)
+				c = v.C
+				return err
+			})
+		if err != nil && !isUnknownTable(err) {
+			logs.Qezap.Error("FindLoggingCount", zap.Error(err))
+		}
+		countResp <- c
+	}()
+
+	docs, err := s.findLogging(ctx, table, shard, withCursor(filter, cursor), cursor, page.Limit, (page.Page-1)*page.Limit)
+	if err != nil {
+		<-countResp
+		return 0, docs, err
+	}
+	c := <-countResp
+	if c <= 0 {
+		c = int64(len(docs))
+	}
+	return c, docs, nil
+}
+
+func (s *ClickHouseStore) findLogging(ctx context.Context, table, shard string, filter bson.M, cursor *model.LoggingCursor, limit, skip int64) ([]*model.Logging, error) {
+	where, err := sqlWhere(filter)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	order := "ts DESC, id DESC"
+	if cursor != nil {
+		skip = 0
+		if cursor.Prev {
+			order = "ts ASC, id ASC"
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s FINAL WHERE %s AND %s ORDER BY %s LIMIT %d",
+		chSelectColumns, table, shard, where, order, limit)
+	if skip > 0 {
+		query += fmt.Sprintf(" OFFSET %d", skip)
+	}
+	docs, err := s.selectLogging(ctx, query)
+	if err != nil {
+		if isUnknownTable(err) {
+			return []*model.Logging{}, nil
+		}
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	if cursor != nil && cursor.Prev {
+		reverse(docs)
+	}
+	return docs, nil
+}
+
+func (s *ClickHouseStore) FindLoggingAfter(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, limit int64) ([]*model.Logging, error) {
+	table, err := tableName(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	docs, err := s.findLogging(ctx, table, "shard = "+sqlString(cName), withCursor(filter, cursor), cursor, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	// keep cursor sort order
+	if cursor != nil && cursor.Prev {
+		reverse(docs)
+	}
+	return docs, nil
+}
+
+func (s *ClickHouseStore) FindLoggingByTraceID(ctx context.Context, dbName string, cNames []string, moduleName, traceID string) ([]*model.Logging, error) {
+	table, err := tableName(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	if len(cNames) == 0 {
+		return []*model.Logging{}, nil
+	}
+	shards := make([]string, 0, len(cNames))
+	for _, v := range cNames {
+		shards = append(shards, sqlString(v))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s FINAL WHERE shard IN (%s) AND m = %s AND ti = %s ORDER BY ts ASC",
+		chSelectColumns, table, strings.Join(shards, ", "), sqlString(moduleName), sqlString(traceID))
+	docs, err := s.selectLogging(ctx, query)
+	if err != nil {
+		if isUnknownTable(err) {
+			return []*model.Logging{}, nil
+		}
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return docs, nil
+}
+
+func (s *ClickHouseStore) ListCollectionNames(ctx context.Context, dbName string, prefix ...string) ([]string, error) {
+	table, err := tableName(dbName)
+	if err != nil {
+		return nil, err
+	}
+	where := "1"
+	if len(prefix) > 0 && prefix[0] != "" {
+		where = "startsWith(shard, " + sqlString(prefix[0]) + ")"
+	}
+	names := make([]string, 0)
+	err = s.selectRows(ctx, fmt.Sprintf("SELECT DISTINCT shard FROM %s WHERE %s ORDER BY shard", table, where), func(line []byte) error {
+		row := chRow{}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		names = append(names, row.Shard)
+		return nil
+	})
+	if err != nil && !isUnknownTable(err) {
+		return nil, err
+	}
+	return names, nil
+}
+
+// DropLoggingCollection partition only this shard dropped, shared partition deleted by mutation
+func (s *ClickHouseStore) DropLoggingCollection(ctx context.Context, dbName, cName string) error {
+	table, err := tableName(dbName)
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	type partition struct {
+		ID     string `json:"p"`
+		Shards int64  `json:"n"`
+	}
+	partitions := make([]partition, 0)
+	query := fmt.Sprintf("SELECT _partition_id AS p, uniqExact(shard) AS n FROM %s WHERE _partition_id IN (SELECT DISTINCT _partition_id FROM %s WHERE shard = %s) GROUP BY p",
+		table, table, sqlString(cName))
+	err = s.selectRows(ctx, query, func(line []byte) error {
+		p := partition{}
+		err := json.Unmarshal(line, &p)
+		partitions = append(partitions, p)
+		return err
+	})
+	if err != nil {
+		if isUnknownTable(err) {
+			return nil
+		}
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	shared := false
+	for _, p := range partitions {
+		if p.Shards > 1 {
+			shared = true
+			continue
+		}
+		if _, err := s.do(ctx, fmt.Sprintf("ALTER TABLE %s DROP PARTITION ID %s", table, sqlString(p.ID)), nil); err != nil {
+			return errc.ErrInternalErr.MultiErr(err)
+		}
+	}
+	if shared {
+		if _, err := s.do(ctx, fmt.Sprintf("ALTER TABLE %s DELETE WHERE shard = %s", table, sqlString(cName)), nil); err != nil {
+			return errc.ErrInternalErr.MultiErr(err)
+		}
+	}
+	return nil
+}
+
+// CollStats size of partitions contain this shard, partition shared by other shard is counted
+func (s *ClickHouseStore) CollStats(ctx context.Context, dbName, cName string) (*CollStats, error) {
+	table, err := tableName(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	stats := &CollStats{}
+	query := fmt.Sprintf(`SELECT
+	(SELECT count() FROM %s WHERE shard = %s) AS count,
+	sum(data_uncompressed_bytes) AS size,
+	sum(bytes_on_disk) AS storage_size
+FROM system.parts WHERE database = %s AND table = %s AND active
+	AND partition_id IN (SELECT DISTINCT _partition_id FROM %s WHERE shard = %s)`,
+		table, sqlString(cName), sqlString(dbName), sqlString(chTable), table, sqlString(cName))
+	err = s.selectRows(ctx, query, func(line []byte) error {
+		v := struct {
+			Count       int64 `json:"count"`
+			Size        int64 `json:"size"`
+			StorageSize int64 `json:"storage_size"`
+		}{}
+		if err := json.Unmarshal(line, &v); err != nil {
+			return err
+		}
+		stats.Count, stats.Size, stats.StorageSize = v.Count, v.Size, v.StorageSize
+		return nil
+	})
+	if err != nil && !isUnknownTable(err) {
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return stats, nil
+}
+
+// Close flush pending batch
+func (s *ClickHouseStore) Close() error {
+	s.mutex.Lock()
+	batches := make([]*chBatch, 0, len(s.batches))
+	for _, b := range s.batches {
+		batches = append(batches, b)
+	}
+	s.mutex.Unlock()
+	var err error
+	for _, b := range batches {
+		s.flush(b)
+		if b.err != nil {
+			err = b.err
+		}
+	}
+	return err
+}
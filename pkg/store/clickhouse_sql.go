@@ -0,0 +1,256 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// chColumns logging bson key to clickhouse column
+var chColumns = map[string]string{
+	"_id": "id",
+	"m":   "m",
+	"ip":  "ip",
+	"l":   "l",
+	"s":   "s",
+	"f":   "f",
+	"c1":  "c1",
+	"c2":  "c2",
+	"c3":  "c3",
+	"ti":  "ti",
+	"tm":  "tm",
+	"ts":  "ts",
+	"mi":  "mi",
+}
+
+var chCompareOperators = map[string]string{
+	"$eq":  "=",
+	"$ne":  "!=",
+	"$gt":  ">",
+	"$gte": ">=",
+	"$lt":  "<",
+	"$lte": "<=",
+}
+
+// sqlWhere translate mongo filter to clickhouse where expression, same semantics as matcher
+func sqlWhere(filter bson.M) (string, error) {
+	keys := make([]string, 0, len(filter))
+	for k := range filter {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	exprs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		expr, err := sqlKey(k, filter[k])
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, expr)
+	}
+	return joinExpr(exprs, "AND", "1"), nil
+}
+
+func joinExpr(exprs []string, op, empty string) string {
+	switch len(exprs) {
+	case 0:
+		return empty
+	case 1:
+		return exprs[0]
+	}
+	return "(" + strings.Join(exprs, " "+op+" ") + ")"
+}
+
+func sqlKey(key string, cond interface{}) (string, error) {
+	switch key {
+	case "$and", "$or", "$nor":
+		list, ok := toArray(cond)
+		if !ok {
+			return "", fmt.Errorf("%s required array", key)
+		}
+		exprs := make([]string, 0, len(list))
+		for _, v := range list {
+			sub, ok := v.(bson.M)
+			if !ok {
+				return "", fmt.Errorf("%s element required document", key)
+			}
+			expr, err := sqlWhere(sub)
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, expr)
+		}
+		switch key {
+		case "$and":
+			return joinExpr(exprs, "AND", "1"), nil
+		case "$or":
+			return joinExpr(exprs, "OR", "0"), nil
+		}
+		return "NOT " + joinExpr(exprs, "OR", "0"), nil
+	case "$text":
+		m, ok := cond.(bson.M)
+		if !ok {
+			return "", fmt.Errorf("$text required document")
+		}
+		search, _ := m["$search"].(string)
+		return sqlText(search), nil
+	}
+	col, ok := chColumns[key]
+	if !ok {
+		return "", fmt.Errorf("unsupported field '%s'", key)
+	}
+	return sqlValue(col, cond)
+}
+
+func sqlValue(col string, cond interface{}) (string, error) {
+	switch c := cond.(type) {
+	case primitive.Regex:
+		return sqlRegex(col, c), nil
+	case bson.M:
+		ops := make([]string, 0, len(c))
+		for op := range c {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		exprs := make([]string, 0, len(ops))
+		for _, op := range ops {
+			expr, err := sqlOperator(col, op, c[op])
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, expr)
+		}
+		return joinExpr(exprs, "AND", "1"), nil
+	}
+	v, err := sqlLiteral(cond)
+	if err != nil {
+		return "", err
+	}
+	return col + " = " + v, nil
+}
+
+func sqlOperator(col, op string, arg interface{}) (string, error) {
+	if sym, ok := chCompareOperators[op]; ok {
+		v, err := sqlLiteral(arg)
+		if err != nil {
+			return "", err
+		}
+		return col + " " + sym + " " + v, nil
+	}
+	switch op {
+	case "$in", "$nin":
+		list, ok := toArray(arg)
+		if !ok {
+			return "", fmt.Errorf("%s required array", op)
+		}
+		if len(list) == 0 {
+			if op == "$in" {
+				return "0", nil
+			}
+			return "1", nil
+		}
+		values := make([]string, 0, len(list))
+		for _, v := range list {
+			lit, err := sqlLiteral(v)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, lit)
+		}
+		expr := col + " IN (" + strings.Join(values, ", ") + ")"
+		if op == "$nin" {
+			expr = col + " NOT IN (" + strings.Join(values, ", ") + ")"
+		}
+		return expr, nil
+	case "$not":
+		expr, err := sqlValue(col, arg)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + expr, nil
+	}
+	return "", fmt.Errorf("unsupported operator '%s'", op)
+}
+
+// sqlRegex clickhouse re2 regexp, mongo options i m s supported as inline flags
+func sqlRegex(col string, r primitive.Regex) string {
+	pattern := r.Pattern
+	flags := ""
+	for _, o := range r.Options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	return "match(" + col + ", " + sqlString(pattern) + ")"
+}
+
+// sqlText same as textMatch, phrase must be contained, term is only used when no phrase, -term must not be contained
+func sqlText(search string) string {
+	const text = "concat(s, '\\n', f)"
+	contains := func(v string) string {
+		return "positionCaseInsensitiveUTF8(" + text + ", " + sqlString(v) + ") > 0"
+	}
+	must := make([]string, 0)
+	for {
+		i := strings.Index(search, `"`)
+		if i < 0 {
+			break
+		}
+		j := strings.Index(search[i+1:], `"`)
+		if j < 0 {
+			break
+		}
+		must = append(must, contains(search[i+1:i+1+j]))
+		search = search[:i] + " " + search[i+2+j:]
+	}
+	phrases := len(must)
+	terms := make([]string, 0)
+	for _, term := range strings.Fields(search) {
+		if strings.HasPrefix(term, "-") {
+			if len(term) > 1 {
+				must = append(must, "NOT "+contains(term[1:]))
+			}
+			continue
+		}
+		terms = append(terms, contains(term))
+	}
+	if phrases == 0 {
+		must = append(must, joinExpr(terms, "OR", "0"))
+	}
+	return joinExpr(must, "AND", "1")
+}
+
+func sqlLiteral(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return sqlString(val), nil
+	case primitive.ObjectID:
+		return sqlString(val.Hex()), nil
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+func sqlString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSqlWhere(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("5f8d0d55b54764421b7156c9")
+	tests := []struct {
+		filter bson.M
+		where  string
+	}{
+		{bson.M{}, "1"},
+		{bson.M{"m": "example", "ts": bson.M{"$gte": 100, "$lt": int64(200)}, "l": int32(2)},
+			"(l = 2 AND m = 'example' AND (ts >= 100 AND ts < 200))"},
+		{bson.M{"$or": bson.A{bson.M{"ts": bson.M{"$lt": 100}}, bson.M{"ts": 100, "_id": bson.M{"$lt": id}}}},
+			"(ts < 100 OR (id < '5f8d0d55b54764421b7156c9' AND ts = 100))"},
+		{bson.M{"s": primitive.Regex{Pattern: "it's", Options: "i"}}, `match(s, '(?i)it\'s')`},
+		{bson.M{"s": bson.M{"$not": primitive.Regex{Pattern: `\d`}}}, `NOT match(s, '\\d')`},
+		{bson.M{"l": bson.M{"$in": bson.A{1, 2}}, "c1": bson.M{"$nin": bson.A{"a"}}}, "(c1 NOT IN ('a') AND l IN (1, 2))"},
+		{bson.M{"l": bson.M{"$in": bson.A{}}}, "0"},
+		{bson.M{"$nor": bson.A{bson.M{"ip": "127.0.0.1"}}}, "NOT ip = '127.0.0.1'"},
+		{bson.M{"$text": bson.M{"$search": "timeout -retry"}},
+			`(NOT positionCaseInsensitiveUTF8(concat(s, '\n', f), 'retry') > 0 AND positionCaseInsensitiveUTF8(concat(s, '\n', f), 'timeout') > 0)`},
+		{bson.M{"$text": bson.M{"$search": `"dial tcp" refused`}}, `positionCaseInsensitiveUTF8(concat(s, '\n', f), 'dial tcp') > 0`},
+	}
+	for i, v := range tests {
+		where, err := sqlWhere(v.filter)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if where != v.where {
+			t.Fatalf("%d want %s, got %s", i, v.where, where)
+		}
+	}
+
+	if _, err := sqlWhere(bson.M{"x; DROP TABLE": 1}); err == nil {
+		t.Fatal("unknown field should error")
+	}
+	if _, err := sqlWhere(bson.M{"l": bson.M{"$where": 1}}); err == nil {
+		t.Fatal("unknown operator should error")
+	}
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeClickHouse record insert rows, select returns rows
+type fakeClickHouse struct {
+	mutex   sync.Mutex
+	inserts []int
+	queries []string
+	rows    string
+}
+
+func (f *fakeClickHouse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if q := r.URL.Query().Get("query"); strings.HasPrefix(q, "INSERT") {
+		f.inserts = append(f.inserts, bytes.Count(body, []byte("\n")))
+		return
+	}
+	f.queries = append(f.queries, string(body))
+	if strings.HasPrefix(string(body), "SELECT count()") {
+		_, _ = w.Write([]byte(`{"c":2}` + "\n"))
+		return
+	}
+	_, _ = w.Write([]byte(f.rows))
+}
+
+func newFakeClickHouse(t *testing.T, batchSize int) (*fakeClickHouse, *ClickHouseStore) {
+	f := &fakeClickHouse{}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	s, err := NewClickHouseStore(ClickHouseConfig{Addr: srv.URL, BatchSize: batchSize, FlushInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func TestClickHouseStore_BatchInsert(t *testing.T) {
+	f, s := newFakeClickHouse(t, 100)
+	ctx := context.Background()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			docs := []interface{}{&model.Logging{Module: "example", TimeSec: 100}, &model.Logging{Module: "example", TimeSec: 101}}
+			if err := s.CreateManyLogging(ctx, "qelog_logging", "lg_1_1", docs); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fmt.Sprint(f.inserts) != "[20]" {
+		t.Fatalf("concurrent written should merged in one insert, got %v", f.inserts)
+	}
+
+	// batch size reached flushed immediately
+	f.inserts = nil
+	docs := make([]interface{}, 0, 100)
+	for i := 0; i < 100; i++ {
+		docs = append(docs, &model.Logging{Module: "example", TimeSec: 100})
+	}
+	st := time.Now()
+	if err := s.CreateManyLogging(ctx, "qelog_logging", "lg_1_1", docs); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(st) >= 50*time.Millisecond || fmt.Sprint(f.inserts) != "[100]" {
+		t.Fatalf("full batch should flushed immediately, got %v", f.inserts)
+	}
+
+	if err := s.CreateManyLogging(ctx, "qelog-logging", "lg_1_1", docs); err == nil {
+		t.Fatal("invalid database name should error")
+	}
+}
+
+func TestClickHouseStore_FindLogging(t *testing.T) {
+	f, s := newFakeClickHouse(t, 100)
+	ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	f.rows = fmt.Sprintf(`{"id":"%s","m":"example","l":2,"s":"short","ts":101,"tm":101000}`+"\n"+
+		`{"id":"%s","m":"example","l":2,"s":"short","ts":100,"tm":100000}`+"\n", ids[1].Hex(), ids[0].Hex())
+
+	filter := bson.M{"m": "example", "ts": bson.M{"$gte": 100, "$lt": 200}}
+	c, docs, err := s.FindLogging(context.Background(), "qelog_logging", "lg_1_1", filter, nil, model.PageReq{Page: 2, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 2 || len(docs) != 2 || docs[0].ID != ids[1] || docs[1].TimeMill != 100000 {
+		t.Fatalf("count %d docs %v", c, docs)
+	}
+	find := ""
+	for _, q := range f.queries {
+		if strings.HasPrefix(q, "SELECT id") {
+			find = q
+		}
+	}
+	want := "WHERE shard = 'lg_1_1' AND (m = 'example' AND (ts >= 100 AND ts < 200)) ORDER BY ts DESC, id DESC LIMIT 10 OFFSET 10"
+	if !strings.Contains(find, want) {
+		t.Fatalf("find query %s", find)
+	}
+
+	// prev cursor query asc, returns desc
+	_, docs, err = s.FindLogging(context.Background(), "qelog_logging", "lg_1_1", filter,
+		&model.LoggingCursor{TsSec: 99, ID: ids[0], Prev: true}, model.PageReq{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs[0].TimeSec != 100 || !strings.Contains(f.queries[len(f.queries)-1]+f.queries[len(f.queries)-2], "ORDER BY ts ASC, id ASC") {
+		t.Fatalf("prev cursor %v", timeSecs(docs))
+	}
+}
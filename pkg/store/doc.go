@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DocStore admin document storage, filter and update is mongo syntax.
+// backend not mongo supported update operators: $set $inc $setOnInsert, find options: sort skip limit
+type DocStore interface {
+	UpsertCollectionIndexMany(indexMany ...[]mongo.Index) error
+	// DropIndex index or collection not exists ignored
+	DropIndex(ctx context.Context, collection, name string) error
+	Find(ctx context.Context, collection string, filter bson.M, docs interface{}, opt ...*options.FindOptions) error
+	FindOne(ctx context.Context, collection string, filter bson.M, doc interface{}) (bool, error)
+	// FindCount count of filter, skip and limit ignored
+	FindCount(ctx context.Context, collection string, filter bson.M, docs interface{}, opt *options.FindOptions) (int64, error)
+	CountDocuments(ctx context.Context, collection string, filter bson.M) (int64, error)
+	InsertOne(ctx context.Context, collection string, doc interface{}) error
+	// UpdateOne returns matched count, upsert not matched inserted
+	UpdateOne(ctx context.Context, collection string, filter, update bson.M, upsert bool) (int64, error)
+	UpdateMany(ctx context.Context, collection string, filter, update bson.M) error
+	DeleteOne(ctx context.Context, collection string, filter bson.M) error
+	Close() error
+}
+
+// MongoDocStore admin database of mongo
+type MongoDocStore struct {
+	db *mongo.Database
+}
+
+func NewMongoDocStore(db *mongo.Database) *MongoDocStore {
+	return &MongoDocStore{db: db}
+}
+
+func (s *MongoDocStore) UpsertCollectionIndexMany(indexMany ...[]mongo.Index) error {
+	return s.db.UpsertCollectionIndexMany(indexMany...)
+}
+
+func (s *MongoDocStore) DropIndex(ctx context.Context, collection, name string) error {
+	_, err := s.db.Collection(collection).Indexes().DropOne(ctx, name)
+	if err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoDocStore) Find(ctx context.Context, collection string, filter bson.M, docs interface{}, opt ...*options.FindOptions) error {
+	return s.db.Find(ctx, collection, filter, docs, opt...)
+}
+
+func (s *MongoDocStore) FindOne(ctx context.Context, collection string, filter bson.M, doc interface{}) (bool, error) {
+	return s.db.FindOne(ctx, collection, filter, doc)
+}
+
+func (s *MongoDocStore) FindCount(ctx context.Context, collection string, filter bson.M, docs interface{}, opt *options.FindOptions) (int64, error) {
+	return s.db.FindCount(ctx, collection, filter, docs, opt, nil)
+}
+
+func (s *MongoDocStore) CountDocuments(ctx context.Context, collection string, filter bson.M) (int64, error) {
+	return s.db.Collection(collection).CountDocuments(ctx, filter)
+}
+
+func (s *MongoDocStore) InsertOne(ctx context.Context, collection string, doc interface{}) error {
+	_, err := s.db.Collection(collection).InsertOne(ctx, doc)
+	return err
+}
+
+func (s *MongoDocStore) UpdateOne(ctx context.Context, collection string, filter, update bson.M, upsert bool) (int64, error) {
+	ret, err := s.db.Collection(collection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(upsert))
+	if err != nil {
+		return 0, err
+	}
+	return ret.MatchedCount, nil
+}
+
+func (s *MongoDocStore) UpdateMany(ctx context.Context, collection string, filter, update bson.M) error {
+	_, err := s.db.Collection(collection).UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (s *MongoDocStore) DeleteOne(ctx context.Context, collection string, filter bson.M) error {
+	_, err := s.db.Collection(collection).DeleteOne(ctx, filter)
+	return err
+}
+
+// Close mongo client shared with dao, closed by dao
+func (s *MongoDocStore) Close() error {
+	return nil
+}
@@ -0,0 +1,466 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LocalDocStore admin documents in bolt file, collection as bucket, key is _id.
+// admin collections are small, query by scan
+type LocalDocStore struct {
+	db *bolt.DB
+
+	mutex  sync.RWMutex
+	unique map[string][][]string // collection unique index keys
+}
+
+func NewLocalDocStore(dir, dbName string) (*LocalDocStore, error) {
+	if dir == "" || dbName == "" || strings.ContainsAny(dbName, `/\.`) {
+		return nil, fmt.Errorf("invalid local document storage '%s' '%s'", dir, dbName)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := openBolt(filepath.Join(dir, dbName+".db"))
+	if err != nil {
+		return nil, err
+	}
+	return &LocalDocStore{db: db, unique: map[string][][]string{}}, nil
+}
+
+// UpsertCollectionIndexMany create collection, unique index checked when written
+func (s *LocalDocStore) UpsertCollectionIndexMany(indexMany ...[]mongo.Index) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, indexes := range indexMany {
+			for _, index := range indexes {
+				if _, err := tx.CreateBucketIfNotExists([]byte(index.Collection)); err != nil {
+					return err
+				}
+				if !index.Unique {
+					continue
+				}
+				keys := make([]string, 0, len(index.Keys))
+				for _, k := range index.Keys {
+					keys = append(keys, k.Key)
+				}
+				s.unique[index.Collection] = append(s.unique[index.Collection], keys)
+			}
+		}
+		return nil
+	})
+}
+
+// DropIndex unique index only in memory, declared by index many when opened
+func (s *LocalDocStore) DropIndex(_ context.Context, _, _ string) error {
+	return nil
+}
+
+type localDoc struct {
+	key []byte
+	doc bson.M
+}
+
+// scan matched documents of collection
+func scanDocs(tx *bolt.Tx, collection string, filter bson.M) ([]localDoc, error) {
+	b := tx.Bucket([]byte(collection))
+	if b == nil {
+		return nil, nil
+	}
+	m := newMatcher(filter)
+	docs := make([]localDoc, 0)
+	err := b.ForEach(func(k, v []byte) error {
+		doc := bson.M{}
+		if err := bson.Unmarshal(v, &doc); err != nil {
+			return err
+		}
+		ok, err := m.Match(doc)
+		if err != nil || !ok {
+			return err
+		}
+		docs = append(docs, localDoc{key: k, doc: doc})
+		return nil
+	})
+	return docs, err
+}
+
+func (s *LocalDocStore) find(collection string, filter bson.M, opt *options.FindOptions) ([]localDoc, int64, error) {
+	var docs []localDoc
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		docs, err = scanDocs(tx, collection, filter)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	count := int64(len(docs))
+	if opt == nil {
+		return docs, count, nil
+	}
+	if opt.Sort != nil {
+		keys, err := sortKeys(opt.Sort)
+		if err != nil {
+			return nil, 0, err
+		}
+		sort.SliceStable(docs, func(i, j int) bool {
+			for _, k := range keys {
+				n, ok := compare(lookup(docs[i].doc, k.Key), lookup(docs[j].doc, k.Key))
+				if !ok || n == 0 {
+					continue
+				}
+				if k.Value.(int) < 0 {
+					return n > 0
+				}
+				return n < 0
+			}
+			return false
+		})
+	}
+	if opt.Skip != nil && *opt.Skip > 0 {
+		if *opt.Skip >= int64(len(docs)) {
+			docs = nil
+		} else {
+			docs = docs[*opt.Skip:]
+		}
+	}
+	if opt.Limit != nil && *opt.Limit > 0 && *opt.Limit < int64(len(docs)) {
+		docs = docs[:*opt.Limit]
+	}
+	return docs, count, nil
+}
+
+// sortKeys sort option as ordered keys, value 1 or -1
+func sortKeys(v interface{}) (bson.D, error) {
+	var d bson.D
+	switch s := v.(type) {
+	case bson.D:
+		d = s
+	case bson.M:
+		for k, val := range s {
+			d = append(d, bson.E{Key: k, Value: val})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sort type %T", v)
+	}
+	keys := make(bson.D, 0, len(d))
+	for _, e := range d {
+		n, ok := toFloat(e.Value)
+		if !ok {
+			return nil, fmt.Errorf("sort %s value required number", e.Key)
+		}
+		dir := 1
+		if n < 0 {
+			dir = -1
+		}
+		keys = append(keys, bson.E{Key: e.Key, Value: dir})
+	}
+	return keys, nil
+}
+
+// decodeDocs documents decoded into slice pointer
+func decodeDocs(docs []localDoc, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("docs required slice pointer")
+	}
+	slice := rv.Elem()
+	slice.SetLen(0)
+	elemType := slice.Type().Elem()
+	for _, d := range docs {
+		raw, err := bson.Marshal(d.doc)
+		if err != nil {
+			return err
+		}
+		var elem reflect.Value
+		if elemType.Kind() == reflect.Ptr {
+			elem = reflect.New(elemType.Elem())
+			err = bson.Unmarshal(raw, elem.Interface())
+		} else {
+			elem = reflect.New(elemType)
+			err = bson.Unmarshal(raw, elem.Interface())
+			elem = elem.Elem()
+		}
+		if err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	return nil
+}
+
+func (s *LocalDocStore) Find(_ context.Context, collection string, filter bson.M, docs interface{}, opt ...*options.FindOptions) error {
+	var o *options.FindOptions
+	if len(opt) > 0 {
+		o = options.MergeFindOptions(opt...)
+	}
+	list, _, err := s.find(collection, filter, o)
+	if err != nil {
+		return err
+	}
+	return decodeDocs(list, docs)
+}
+
+func (s *LocalDocStore) FindOne(_ context.Context, collection string, filter bson.M, doc interface{}) (bool, error) {
+	list, _, err := s.find(collection, filter, options.Find().SetLimit(1))
+	if err != nil || len(list) == 0 {
+		return false, err
+	}
+	raw, err := bson.Marshal(list[0].doc)
+	if err != nil {
+		return false, err
+	}
+	return true, bson.Unmarshal(raw, doc)
+}
+
+func (s *LocalDocStore) FindCount(_ context.Context, collection string, filter bson.M, docs interface{}, opt *options.FindOptions) (int64, error) {
+	list, c, err := s.find(collection, filter, opt)
+	if err != nil {
+		return 0, err
+	}
+	return c, decodeDocs(list, docs)
+}
+
+func (s *LocalDocStore) CountDocuments(_ context.Context, collection string, filter bson.M) (int64, error) {
+	_, c, err := s.find(collection, filter, nil)
+	return c, err
+}
+
+func (s *LocalDocStore) InsertOne(_ context.Context, collection string, doc interface{}) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	m := bson.M{}
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.put(tx, collection, m)
+	})
+}
+
+// put document, _id generated if empty, unique index checked
+func (s *LocalDocStore) put(tx *bolt.Tx, collection string, doc bson.M) error {
+	id, ok := doc["_id"].(primitive.ObjectID)
+	if !ok || id.IsZero() {
+		if _, exists := doc["_id"]; exists && !ok {
+			return fmt.Errorf("_id required ObjectID")
+		}
+		id = primitive.NewObjectID()
+		doc["_id"] = id
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(collection))
+	if err != nil {
+		return err
+	}
+	s.mutex.RLock()
+	unique := s.unique[collection]
+	s.mutex.RUnlock()
+	for _, keys := range unique {
+		err := b.ForEach(func(k, v []byte) error {
+			if string(k) == string(id[:]) {
+				return nil
+			}
+			other := bson.M{}
+			if err := bson.Unmarshal(v, &other); err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if !equal(lookup(doc, key), lookup(other, key)) {
+					return nil
+				}
+			}
+			return fmt.Errorf("E11000 duplicate key error collection: %s index: %s", collection, strings.Join(keys, "_"))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return b.Put(id[:], raw)
+}
+
+func (s *LocalDocStore) UpdateOne(_ context.Context, collection string, filter, update bson.M, upsert bool) (int64, error) {
+	matched := int64(0)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		docs, err := scanDocs(tx, collection, filter)
+		if err != nil {
+			return err
+		}
+		if len(docs) > 0 {
+			matched = 1
+			if err := applyUpdate(docs[0].doc, update, false); err != nil {
+				return err
+			}
+			return s.put(tx, collection, docs[0].doc)
+		}
+		if !upsert {
+			return nil
+		}
+		doc := bson.M{}
+		for k, v := range filter {
+			if strings.HasPrefix(k, "$") {
+				continue
+			}
+			if _, ok := v.(bson.M); ok {
+				continue
+			}
+			if err := setPath(doc, k, v); err != nil {
+				return err
+			}
+		}
+		if err := applyUpdate(doc, update, true); err != nil {
+			return err
+		}
+		return s.put(tx, collection, doc)
+	})
+	return matched, err
+}
+
+func (s *LocalDocStore) UpdateMany(_ context.Context, collection string, filter, update bson.M) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		docs, err := scanDocs(tx, collection, filter)
+		if err != nil {
+			return err
+		}
+		for _, d := range docs {
+			if err := applyUpdate(d.doc, update, false); err != nil {
+				return err
+			}
+			if err := s.put(tx, collection, d.doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *LocalDocStore) DeleteOne(_ context.Context, collection string, filter bson.M) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		docs, err := scanDocs(tx, collection, filter)
+		if err != nil || len(docs) == 0 {
+			return err
+		}
+		return tx.Bucket([]byte(collection)).Delete(docs[0].key)
+	})
+}
+
+func (s *LocalDocStore) Close() error {
+	return closeBolt(s.db)
+}
+
+// applyUpdate $set $inc, $setOnInsert only inserted
+func applyUpdate(doc, update bson.M, insert bool) error {
+	for op, v := range update {
+		fields, ok := v.(bson.M)
+		if !ok {
+			return fmt.Errorf("%s required document", op)
+		}
+		switch op {
+		case "$set":
+		case "$setOnInsert":
+			if !insert {
+				continue
+			}
+		case "$inc":
+			for k, n := range fields {
+				sum, err := increase(lookup(doc, k), n)
+				if err != nil {
+					return fmt.Errorf("$inc %s %v", k, err)
+				}
+				if err := setPath(doc, k, sum); err != nil {
+					return err
+				}
+			}
+			continue
+		default:
+			return fmt.Errorf("unsupported update operator %s", op)
+		}
+		for k, val := range fields {
+			raw, err := toBsonValue(val)
+			if err != nil {
+				return err
+			}
+			if err := setPath(doc, k, raw); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// toBsonValue value as decoded from bson, struct and time stored same as mongo
+func toBsonValue(v interface{}) (interface{}, error) {
+	raw, err := bson.Marshal(bson.M{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	m := bson.M{}
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m["v"], nil
+}
+
+// increase number kept integer same as mongo, int32 overflow as int64
+func increase(cur, n interface{}) (interface{}, error) {
+	if cur == nil {
+		return n, nil
+	}
+	x, ok := toFloat(cur)
+	y, ok2 := toFloat(n)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("required number")
+	}
+	ck, nk := reflect.ValueOf(cur).Kind(), reflect.ValueOf(n).Kind()
+	if ck == reflect.Float32 || ck == reflect.Float64 || nk == reflect.Float32 || nk == reflect.Float64 {
+		return x + y, nil
+	}
+	sum := int64(x) + int64(y)
+	if ck == reflect.Int32 && nk == reflect.Int32 && sum >= math.MinInt32 && sum <= math.MaxInt32 {
+		return int32(sum), nil
+	}
+	return sum, nil
+}
+
+// setPath set dotted path value, embedded document created if not exists
+func setPath(doc bson.M, key string, v interface{}) error {
+	parts := strings.Split(key, ".")
+	cur := doc
+	for _, k := range parts[:len(parts)-1] {
+		switch next := cur[k].(type) {
+		case bson.M:
+			cur = next
+		case bson.D:
+			m := next.Map()
+			cur[k] = m
+			cur = m
+		case nil:
+			m := bson.M{}
+			cur[k] = m
+			cur = m
+		default:
+			return fmt.Errorf("%s not document", key)
+		}
+	}
+	cur[parts[len(parts)-1]] = v
+	return nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func newTestDocStore(t *testing.T) *LocalDocStore {
+	s, err := NewLocalDocStore(t.TempDir(), "qelog_admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.UpsertCollectionIndexMany(model.ModuleIndexMany()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLocalDocStore_CRUD(t *testing.T) {
+	s := newTestDocStore(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		m := &model.Module{Name: fmt.Sprintf("module%d", i), Bucket: fmt.Sprintf("b%d", i), DaySpan: i, UpdatedAt: time.Now()}
+		if err := s.InsertOne(ctx, model.CNModule, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.InsertOne(ctx, model.CNModule, &model.Module{Name: "module1", Bucket: "other"}); err == nil {
+		t.Fatal("unique name should conflict")
+	}
+
+	docs := make([]*model.Module, 0)
+	opt := options.Find().SetSort(bson.M{"day_span": -1}).SetSkip(1).SetLimit(2)
+	c, err := s.FindCount(ctx, model.CNModule, bson.M{"name": primitive.Regex{Pattern: "MODULE", Options: "i"}}, &docs, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 5 || len(docs) != 2 || docs[0].Name != "module3" || docs[1].Name != "module2" {
+		t.Fatalf("find count %d %v", c, docs)
+	}
+
+	m := &model.Module{}
+	if ok, err := s.FindOne(ctx, model.CNModule, bson.M{"name": "module2"}, m); err != nil || !ok {
+		t.Fatal("module2 not found", err)
+	}
+	// optimistic lock by updated_at, time compared by millisecond
+	filter := bson.M{"_id": m.ID, "updated_at": m.UpdatedAt}
+	update := bson.M{"$set": bson.M{"desc": "updated", "updated_at": time.Now()}}
+	if matched, err := s.UpdateOne(ctx, model.CNModule, filter, update, false); err != nil || matched != 1 {
+		t.Fatal("update not matched", err)
+	}
+	if matched, err := s.UpdateOne(ctx, model.CNModule, filter, update, false); err != nil || matched != 0 {
+		t.Fatal("updated_at changed, should not matched", err)
+	}
+
+	if err := s.DeleteOne(ctx, model.CNModule, bson.M{"_id": m.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.CountDocuments(ctx, model.CNModule, bson.M{}); c != 4 {
+		t.Fatalf("after delete count %d", c)
+	}
+}
+
+func TestLocalDocStore_UpsertInc(t *testing.T) {
+	s := newTestDocStore(t)
+	ctx := context.Background()
+	date := time.Now().Truncate(time.Hour)
+	filter := bson.M{"module_name": "example", "created_date": date}
+	for i := 0; i < 3; i++ {
+		update := bson.M{
+			"$inc": bson.M{
+				"number":               int32(2),
+				"sections.10.sum":      int32(2),
+				"sections.10.levels.1": int32(1),
+			},
+			"$setOnInsert": bson.M{"size": int64(100)},
+		}
+		if _, err := s.UpdateOne(ctx, model.CNModuleMetrics, filter, update, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs := make([]*model.ModuleMetrics, 0)
+	if err := s.Find(ctx, model.CNModuleMetrics, filter, &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("upsert should one document, got %d", len(docs))
+	}
+	v := docs[0]
+	if v.Number != 6 || v.Size != 100 || v.Sections[10].Sum != 6 || v.Sections[10].Levels[1] != 3 || !v.CreatedDate.Equal(date) {
+		t.Fatalf("metrics %+v", v)
+	}
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matcher evaluate mongo query filter on document, regexp compiled once
+type matcher struct {
+	filter bson.M
+	regexp map[string]*regexp.Regexp
+}
+
+func newMatcher(filter bson.M) *matcher {
+	return &matcher{filter: filter, regexp: map[string]*regexp.Regexp{}}
+}
+
+func (m *matcher) Match(doc bson.M) (bool, error) {
+	return m.match(m.filter, doc)
+}
+
+func (m *matcher) match(filter bson.M, doc bson.M) (bool, error) {
+	for k, v := range filter {
+		ok, err := m.matchKey(k, v, doc)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (m *matcher) matchKey(key string, cond interface{}, doc bson.M) (bool, error) {
+	switch key {
+	case "$and", "$or", "$nor":
+		list, ok := toArray(cond)
+		if !ok {
+			return false, fmt.Errorf("%s required array", key)
+		}
+		for _, v := range list {
+			sub, ok := v.(bson.M)
+			if !ok {
+				return false, fmt.Errorf("%s element required document", key)
+			}
+			ok, err := m.match(sub, doc)
+			if err != nil {
+				return false, err
+			}
+			if key == "$and" && !ok {
+				return false, nil
+			}
+			if key == "$or" && ok {
+				return true, nil
+			}
+			if key == "$nor" && ok {
+				return false, nil
+			}
+		}
+		return key != "$or", nil
+	case "$text":
+		c, ok := cond.(bson.M)
+		if !ok {
+			return false, fmt.Errorf("$text required document")
+		}
+		search, _ := c["$search"].(string)
+		return textMatch(search, doc), nil
+	}
+	return m.matchValue(lookup(doc, key), cond)
+}
+
+// lookup dotted path value of document
+func lookup(doc bson.M, key string) interface{} {
+	if v, ok := doc[key]; ok || !strings.Contains(key, ".") {
+		return v
+	}
+	var cur interface{} = doc
+	for _, k := range strings.Split(key, ".") {
+		switch d := cur.(type) {
+		case bson.M:
+			cur = d[k]
+		case bson.D:
+			cur = d.Map()[k]
+		default:
+			return nil
+		}
+	}
+	return cur
+}
+
+func (m *matcher) matchValue(val interface{}, cond interface{}) (bool, error) {
+	switch c := cond.(type) {
+	case primitive.Regex:
+		return m.regexMatch(val, c)
+	case bson.M:
+		for op, arg := range c {
+			ok, err := m.matchOperator(val, op, arg)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return equal(val, cond), nil
+}
+
+func (m *matcher) matchOperator(val interface{}, op string, arg interface{}) (bool, error) {
+	switch op {
+	case "$eq":
+		return equal(val, arg), nil
+	case "$ne":
+		return !equal(val, arg), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		n, ok := compare(val, arg)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "$gt":
+			return n > 0, nil
+		case "$gte":
+			return n >= 0, nil
+		case "$lt":
+			return n < 0, nil
+		}
+		return n <= 0, nil
+	case "$in", "$nin":
+		list, ok := toArray(arg)
+		if !ok {
+			return false, fmt.Errorf("%s required array", op)
+		}
+		in := false
+		for _, v := range list {
+			if equal(val, v) {
+				in = true
+				break
+			}
+		}
+		return in == (op == "$in"), nil
+	case "$not":
+		ok, err := m.matchValue(val, arg)
+		return !ok, err
+	}
+	return false, fmt.Errorf("unsupported operator %s", op)
+}
+
+func (m *matcher) regexMatch(val interface{}, r primitive.Regex) (bool, error) {
+	s, ok := val.(string)
+	if !ok {
+		return false, nil
+	}
+	key := r.Options + "/" + r.Pattern
+	re, ok := m.regexp[key]
+	if !ok {
+		pattern := r.Pattern
+		if strings.Contains(r.Options, "i") {
+			pattern = "(?i)" + pattern
+		}
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, err
+		}
+		m.regexp[key] = re
+	}
+	return re.MatchString(s), nil
+}
+
+// textMatch like mongo text search without stemming: any term contained in short or full message.
+// "phrase" must be contained, term is only used when no phrase. -term must not be contained
+func textMatch(search string, doc bson.M) bool {
+	s, _ := doc["s"].(string)
+	f, _ := doc["f"].(string)
+	text := strings.ToLower(s + "\n" + f)
+	search = strings.ToLower(search)
+
+	phrases := 0
+	for {
+		i := strings.Index(search, `"`)
+		if i < 0 {
+			break
+		}
+		j := strings.Index(search[i+1:], `"`)
+		if j < 0 {
+			break
+		}
+		if !strings.Contains(text, search[i+1:i+1+j]) {
+			return false
+		}
+		phrases++
+		search = search[:i] + " " + search[i+2+j:]
+	}
+	hit := false
+	for _, term := range strings.Fields(search) {
+		if strings.HasPrefix(term, "-") {
+			if len(term) > 1 && strings.Contains(text, term[1:]) {
+				return false
+			}
+			continue
+		}
+		if strings.Contains(text, term) {
+			hit = true
+		}
+	}
+	return phrases > 0 || hit
+}
+
+func toArray(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case bson.A:
+		return a, true
+	case []interface{}:
+		return a, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+func equal(a, b interface{}) bool {
+	n, ok := compare(a, b)
+	return ok && n == 0
+}
+
+// compare same kind value, number compare by value, ok false not comparable
+func compare(a, b interface{}) (int, bool) {
+	a, b = normalize(a), normalize(b)
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case primitive.ObjectID:
+		y, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(x[:], y[:]), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok || x != y {
+			return 0, false
+		}
+		return 0, true
+	case nil:
+		return 0, b == nil
+	}
+	return 0, false
+}
+
+// normalize time as bson datetime, compared by millisecond
+func normalize(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return primitive.NewDateTimeFromTime(t)
+	}
+	return v
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	doc := bson.M{"m": "example", "l": int32(2), "ts": int64(100), "s": "Connect Timeout", "f": "dial tcp 127.0.0.1", "c1": "order"}
+	tests := []struct {
+		filter bson.M
+		match  bool
+	}{
+		{bson.M{"m": "example", "l": 2}, true},
+		{bson.M{"ts": bson.M{"$gte": 100, "$lt": 101}}, true},
+		{bson.M{"ts": bson.M{"$gt": int64(100)}}, false},
+		{bson.M{"l": bson.M{"$in": bson.A{1, 2}}}, true},
+		{bson.M{"l": bson.M{"$nin": bson.A{1, 2}}}, false},
+		{bson.M{"c2": bson.M{"$ne": "x"}}, true},
+		{bson.M{"s": primitive.Regex{Pattern: "^connect", Options: "i"}}, true},
+		{bson.M{"s": bson.M{"$not": primitive.Regex{Pattern: "timeout", Options: "i"}}}, false},
+		{bson.M{"$or": bson.A{bson.M{"l": 1}, bson.M{"c1": "order"}}}, true},
+		{bson.M{"$and": bson.A{bson.M{"l": 2}, bson.M{"c1": "pay"}}}, false},
+		{bson.M{"$nor": bson.A{bson.M{"l": 1}}}, true},
+		{bson.M{"$text": bson.M{"$search": "timeout"}}, true},
+		{bson.M{"$text": bson.M{"$search": "refused"}}, false},
+	}
+	for i, v := range tests {
+		ok, err := newMatcher(v.filter).Match(doc)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if ok != v.match {
+			t.Fatalf("%d filter %v want %v", i, v.filter, v.match)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/qelog/pkg/model"
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	bucketData  = []byte("d")
+	bucketTrace = []byte("t")
+)
+
+// LocalStore embedded logging storage for small single node deployment and testing without mongo.
+// database as bolt file in dir, collection as bucket, logging key ordered by (ts, _id), trace id as secondary index.
+// filter evaluated in process, time range and cursor limit scan range
+type LocalStore struct {
+	dir   string
+	mutex sync.Mutex
+	dbs   map[string]*bolt.DB
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("local storage dir required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, dbs: map[string]*bolt.DB{}}, nil
+}
+
+func (s *LocalStore) db(dbName string) (*bolt.DB, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if db, ok := s.dbs[dbName]; ok {
+		return db, nil
+	}
+	if dbName == "" || strings.ContainsAny(dbName, `/\.`) {
+		return nil, fmt.Errorf("invalid database name '%s'", dbName)
+	}
+	db, err := openBolt(filepath.Join(s.dir, dbName+".db"))
+	if err != nil {
+		return nil, err
+	}
+	s.dbs[dbName] = db
+	return db, nil
+}
+
+// loggingKey ts sign bit flipped, negative ts ordered before positive
+func loggingKey(ts int64, id primitive.ObjectID) []byte {
+	k := make([]byte, 8+len(id))
+	binary.BigEndian.PutUint64(k[:8], uint64(ts)^(1<<63))
+	copy(k[8:], id[:])
+	return k
+}
+
+func encodeLogging(v interface{}) (*model.Logging, []byte, error) {
+	l, err := toLogging(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := bson.Marshal(l)
+	return l, raw, err
+}
+
+// CreateManyLogging same id and ts overwritten, written idempotent
+func (s *LocalStore) CreateManyLogging(_ context.Context, dbName, cName string, docs []interface{}) error {
+	db, err := s.db(dbName)
+	if err != nil {
+		return errc.WithStack(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		data, trace, err := createBuckets(tx, cName)
+		if err != nil {
+			return err
+		}
+		for _, v := range docs {
+			l, raw, err := encodeLogging(v)
+			if err != nil {
+				return err
+			}
+			key := loggingKey(l.TimeSec, l.ID)
+			if err := data.Put(key, raw); err != nil {
+				return err
+			}
+			if l.TraceID != "" {
+				if err := trace.Put(append([]byte(l.TraceID+"\x00"), key...), []byte{}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return errc.WithStack(err)
+}
+
+func createBuckets(tx *bolt.Tx, cName string) (data, trace *bolt.Bucket, err error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(cName))
+	if err != nil {
+		return nil, nil, err
+	}
+	if data, err = b.CreateBucketIfNotExists(bucketData); err != nil {
+		return nil, nil, err
+	}
+	if trace, err = b.CreateBucketIfNotExists(bucketTrace); err != nil {
+		return nil, nil, err
+	}
+	return data, trace, nil
+}
+
+// CreateLoggingIndex data ordered by key, trace index maintained when written, full text search by scan
+func (s *LocalStore) CreateLoggingIndex(_ context.Context, dbName, cName string, _ bool) error {
+	db, err := s.db(dbName)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		_, _, err := createBuckets(tx, cName)
+		return err
+	})
+}
+
+func (s *LocalStore) CreateLoggingTextIndex(context.Context, string, string) error {
+	return nil
+}
+
+func (s *LocalStore) DropLoggingTextIndex(context.Context, string, string) error {
+	return nil
+}
+
+func (s *LocalStore) FindLogging(_ context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, page model.PageReq) (int64, []*model.Logging, error) {
+	db, err := s.db(dbName)
+	if err != nil {
+		return 0, nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	count := int64(0)
+	docs := make([]*model.Logging, 0, page.Limit)
+	err = db.View(func(tx *bolt.Tx) error {
+		err := scan(tx, cName, filter, nil, func(*model.Logging) bool {
+			count++
+			return count < MaxCount
+		})
+		if err != nil {
+			return err
+		}
+		if cursor != nil {
+			return scan(tx, cName, filter, cursor, func(l *model.Logging) bool {
+				docs = append(docs, l)
+				return int64(len(docs)) < page.Limit
+			})
+		}
+		skip := (page.Page - 1) * page.Limit
+		return scan(tx, cName, filter, nil, func(l *model.Logging) bool {
+			if skip > 0 {
+				skip--
+				return true
+			}
+			docs = append(docs, l)
+			return int64(len(docs)) < page.Limit
+		})
+	})
+	if err != nil {
+		return 0, nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	if cursor != nil && cursor.Prev {
+		reverse(docs)
+	}
+	return count, docs, nil
+}
+
+func (s *LocalStore) FindLoggingAfter(_ context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, limit int64) ([]*model.Logging, error) {
+	db, err := s.db(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	docs := make([]*model.Logging, 0, limit)
+	err = db.View(func(tx *bolt.Tx) error {
+		return scan(tx, cName, filter, cursor, func(l *model.Logging) bool {
+			docs = append(docs, l)
+			return int64(len(docs)) < limit
+		})
+	})
+	if err != nil {
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return docs, nil
+}
+
+// scan matched logging by cursor sort, default ts desc. fn returns false stop scan
+func scan(tx *bolt.Tx, cName string, filter bson.M, cursor *model.LoggingCursor, fn func(l *model.Logging) bool) error {
+	b := tx.Bucket([]byte(cName))
+	if b == nil {
+		return nil
+	}
+	data := b.Bucket(bucketData)
+	if data == nil {
+		return nil
+	}
+	lo, hi := tsKeyRange(filter)
+	asc := cursor != nil && cursor.Prev
+	if cursor != nil {
+		k := loggingKey(cursor.TsSec, cursor.ID)
+		if asc && (lo == nil || bytes.Compare(k, lo) > 0) {
+			lo = k
+		}
+		if !asc && (hi == nil || bytes.Compare(k, hi) < 0) {
+			hi = k
+		}
+	}
+	m := newMatcher(withCursor(filter, cursor))
+
+	visit := func(v []byte) (bool, error) {
+		doc := bson.M{}
+		if err := bson.Unmarshal(v, &doc); err != nil {
+			return false, err
+		}
+		ok, err := m.Match(doc)
+		if err != nil || !ok {
+			return true, err
+		}
+		l := &model.Logging{}
+		if err := bson.Unmarshal(v, l); err != nil {
+			return false, err
+		}
+		return fn(l), nil
+	}
+
+	c := data.Cursor()
+	if asc {
+		k, v := c.First()
+		if lo != nil {
+			k, v = c.Seek(lo)
+		}
+		for ; k != nil && (hi == nil || bytes.Compare(k, hi) < 0); k, v = c.Next() {
+			next, err := visit(v)
+			if err != nil || !next {
+				return err
+			}
+		}
+		return nil
+	}
+	var k, v []byte
+	if hi == nil {
+		k, v = c.Last()
+	} else if k, v = c.Seek(hi); k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	for ; k != nil && (lo == nil || bytes.Compare(k, lo) >= 0); k, v = c.Prev() {
+		next, err := visit(v)
+		if err != nil || !next {
+			return err
+		}
+	}
+	return nil
+}
+
+// tsKeyRange key range [lo, hi) by ts condition, nil not limited
+func tsKeyRange(filter bson.M) (lo, hi []byte) {
+	cond, ok := filter["ts"].(bson.M)
+	if !ok {
+		return nil, nil
+	}
+	for op, v := range cond {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() < reflect.Int || rv.Kind() > reflect.Int64 {
+			continue
+		}
+		ts := rv.Int()
+		switch op {
+		case "$gte":
+			lo = loggingKey(ts, primitive.ObjectID{})
+		case "$gt":
+			lo = loggingKey(ts+1, primitive.ObjectID{})
+		case "$lt":
+			hi = loggingKey(ts, primitive.ObjectID{})
+		case "$lte":
+			hi = loggingKey(ts+1, primitive.ObjectID{})
+		}
+	}
+	return lo, hi
+}
+
+func (s *LocalStore) FindLoggingByTraceID(_ context.Context, dbName string, cNames []string, moduleName, traceID string) ([]*model.Logging, error) {
+	db, err := s.db(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	docs := make([]*model.Logging, 0)
+	prefix := []byte(traceID + "\x00")
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, cName := range cNames {
+			b := tx.Bucket([]byte(cName))
+			if b == nil || b.Bucket(bucketTrace) == nil {
+				continue
+			}
+			data := b.Bucket(bucketData)
+			c := b.Bucket(bucketTrace).Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				v := data.Get(k[len(prefix):])
+				if v == nil {
+					continue
+				}
+				l := &model.Logging{}
+				if err := bson.Unmarshal(v, l); err != nil {
+					return err
+				}
+				if l.Module == moduleName {
+					docs = append(docs, l)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return docs, nil
+}
+
+func (s *LocalStore) ListCollectionNames(_ context.Context, dbName string, prefix ...string) ([]string, error) {
+	db, err := s.db(dbName)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if len(prefix) == 0 || strings.HasPrefix(string(name), prefix[0]) {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+	return names, err
+}
+
+func (s *LocalStore) DropLoggingCollection(_ context.Context, dbName, cName string) error {
+	db, err := s.db(dbName)
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(cName))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	return nil
+}
+
+// CollStats storage size is bolt file size, shared by all collection in database
+func (s *LocalStore) CollStats(_ context.Context, dbName, cName string) (*CollStats, error) {
+	db, err := s.db(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	stats := &CollStats{}
+	err = db.View(func(tx *bolt.Tx) error {
+		stats.StorageSize = tx.Size()
+		b := tx.Bucket([]byte(cName))
+		if b == nil || b.Bucket(bucketData) == nil {
+			return nil
+		}
+		return b.Bucket(bucketData).ForEach(func(_, v []byte) error {
+			stats.Count++
+			stats.Size += int64(len(v))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return stats, nil
+}
+
+func (s *LocalStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var err error
+	for name, db := range s.dbs {
+		if e := closeBolt(db); e != nil {
+			err = e
+		}
+		delete(s.dbs, name)
+	}
+	return err
+}
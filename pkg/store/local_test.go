@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bbdshow/qelog/pkg/model"
+	"github.com/bbdshow/qelog/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testDB   = "qelog_receiver"
+	testColl = "logging_1_1"
+)
+
+// newTestStore 10 logging ts 100...109, even level 1 odd level 2, trace by ts/5
+func newTestStore(t *testing.T) *LocalStore {
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := context.Background()
+	if err := s.CreateLoggingIndex(ctx, testDB, testColl, false); err != nil {
+		t.Fatal(err)
+	}
+	docs := make([]interface{}, 0, 10)
+	for i := 0; i < 10; i++ {
+		docs = append(docs, &model.Logging{
+			ID:      primitive.NewObjectID(),
+			Module:  "example",
+			Level:   types.Level(1 + i%2),
+			Short:   fmt.Sprintf("short message %d", i),
+			Full:    fmt.Sprintf("{\"index\":%d}", i),
+			TraceID: fmt.Sprintf("trace%d", i/5),
+			TimeSec: int64(100 + i),
+		})
+	}
+	if err := s.CreateManyLogging(ctx, testDB, testColl, docs); err != nil {
+		t.Fatal(err)
+	}
+	// redelivered logging ignored
+	if err := s.CreateManyLogging(ctx, testDB, testColl, docs[:2]); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func timeSecs(docs []*model.Logging) []int64 {
+	ts := make([]int64, 0, len(docs))
+	for _, v := range docs {
+		ts = append(ts, v.TimeSec)
+	}
+	return ts
+}
+
+func assertTimeSecs(t *testing.T, docs []*model.Logging, want ...int64) {
+	t.Helper()
+	got := timeSecs(docs)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestLocalStore_FindLogging(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	filter := bson.M{"m": "example", "ts": bson.M{"$gte": int64(102), "$lt": int64(108)}}
+
+	c, docs, err := s.FindLogging(ctx, testDB, testColl, filter, nil, model.PageReq{Page: 1, Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 6 {
+		t.Fatalf("want count 6, got %d", c)
+	}
+	assertTimeSecs(t, docs, 107, 106, 105, 104)
+
+	_, docs, err = s.FindLogging(ctx, testDB, testColl, filter, nil, model.PageReq{Page: 2, Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTimeSecs(t, docs, 103, 102)
+
+	filter["l"] = int32(2)
+	filter["s"] = primitive.Regex{Pattern: "MESSAGE [57]", Options: "i"}
+	c, docs, err = s.FindLogging(ctx, testDB, testColl, filter, nil, model.PageReq{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 2 {
+		t.Fatalf("want count 2, got %d", c)
+	}
+	assertTimeSecs(t, docs, 107, 105)
+
+	_, docs, err = s.FindLogging(ctx, testDB, "not_exists", filter, nil, model.PageReq{Page: 1, Limit: 10})
+	if err != nil || len(docs) != 0 {
+		t.Fatal("not exists collection should empty", err)
+	}
+}
+
+func TestLocalStore_Cursor(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	filter := bson.M{"m": "example", "ts": bson.M{"$gte": int64(100), "$lt": int64(110)}}
+	page := model.PageReq{Page: 1, Limit: 3}
+
+	_, first, err := s.FindLogging(ctx, testDB, testColl, filter, nil, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTimeSecs(t, first, 109, 108, 107)
+
+	c, next, err := s.FindLogging(ctx, testDB, testColl, filter, model.NewLoggingCursor(first[2], false), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 10 {
+		t.Fatalf("cursor count still total, got %d", c)
+	}
+	assertTimeSecs(t, next, 106, 105, 104)
+
+	_, prev, err := s.FindLogging(ctx, testDB, testColl, filter, model.NewLoggingCursor(next[0], true), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTimeSecs(t, prev, 109, 108, 107)
+
+	// tail order by cursor sort, ts asc
+	after, err := s.FindLoggingAfter(ctx, testDB, testColl, filter, model.NewLoggingCursor(next[0], true), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTimeSecs(t, after, 107, 108)
+}
+
+func TestLocalStore_TraceAndDrop(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docs, err := s.FindLoggingByTraceID(ctx, testDB, []string{testColl, "not_exists"}, "example", "trace1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTimeSecs(t, docs, 105, 106, 107, 108, 109)
+	docs, err = s.FindLoggingByTraceID(ctx, testDB, []string{testColl}, "other", "trace1")
+	if err != nil || len(docs) != 0 {
+		t.Fatal("other module trace should empty", err)
+	}
+
+	if err := s.CreateLoggingIndex(ctx, testDB, "logging_1_2", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateLoggingIndex(ctx, testDB, "other_1_1", false); err != nil {
+		t.Fatal(err)
+	}
+	names, err := s.ListCollectionNames(ctx, testDB, "logging_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[logging_1_1 logging_1_2]" {
+		t.Fatalf("list collection %v", names)
+	}
+
+	if err := s.DropLoggingCollection(ctx, testDB, testColl); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DropLoggingCollection(ctx, testDB, testColl); err != nil {
+		t.Fatal("not exists collection drop ignore", err)
+	}
+	names, _ = s.ListCollectionNames(ctx, testDB, "logging_")
+	if fmt.Sprint(names) != "[logging_1_2]" {
+		t.Fatalf("list collection after drop %v", names)
+	}
+}
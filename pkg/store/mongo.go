@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"github.com/bbdshow/bkit/errc"
+	"github.com/bbdshow/bkit/logs"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// MongoStore logging shard as mongo collection
+type MongoStore struct {
+	groups *mongo.Groups
+}
+
+func NewMongoStore(groups *mongo.Groups) *MongoStore {
+	return &MongoStore{groups: groups}
+}
+
+func (s *MongoStore) CreateManyLogging(ctx context.Context, dbName, cName string, docs []interface{}) error {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return errc.WithStack(err)
+	}
+	// unordered, duplicate logging not stop others written.
+	// logging id assigned before written when redelivered by queue, duplicate key means already written
+	_, err = inst.Collection(cName).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && isDuplicateKeyOnly(err) {
+		return nil
+	}
+	return errc.WithStack(err)
+}
+
+func isDuplicateKeyOnly(err error) bool {
+	var bwe mongoDriver.BulkWriteException
+	if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+		return false
+	}
+	for _, v := range bwe.WriteErrors {
+		if v.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MongoStore) CreateLoggingIndex(ctx context.Context, dbName, cName string, fullText bool) error {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return err
+	}
+	if err := inst.UpsertCollectionIndexMany(model.LoggingIndexMany(cName)); err != nil {
+		return err
+	}
+	if fullText {
+		return s.CreateLoggingTextIndex(ctx, dbName, cName)
+	}
+	return nil
+}
+
+// CreateLoggingTextIndex text index not tokenize by language, because log content mixed language
+func (s *MongoStore) CreateLoggingTextIndex(ctx context.Context, dbName, cName string) error {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return err
+	}
+	index := mongoDriver.IndexModel{
+		Keys: bson.D{{Key: "s", Value: "text"}, {Key: "f", Value: "text"}},
+		Options: options.Index().SetName(model.LoggingTextIndexName).SetBackground(true).
+			SetDefaultLanguage("none").SetWeights(bson.M{"s": 2, "f": 1}),
+	}
+	_, err = inst.Collection(cName).Indexes().CreateOne(ctx, index)
+	return errc.WithStack(err)
+}
+
+func (s *MongoStore) DropLoggingTextIndex(ctx context.Context, dbName, cName string) error {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return err
+	}
+	_, err = inst.Collection(cName).Indexes().DropOne(ctx, model.LoggingTextIndexName)
+	if err != nil && !isIndexNotFound(err) {
+		return errc.WithStack(err)
+	}
+	return nil
+}
+
+func isIndexNotFound(err error) bool {
+	var cmdErr mongoDriver.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Name == "IndexNotFound" ||
+		cmdErr.Code == 26 || cmdErr.Name == "NamespaceNotFound")
+}
+
+func (s *MongoStore) FindLogging(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, page model.PageReq) (int64, []*model.Logging, error) {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return 0, nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	// async count, max number or max time 15s
+	countResp := make(chan int64, 1)
+	go func() {
+		cctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+		opt := options.Count().SetLimit(MaxCount)
+		c, err := inst.Collection(cName).CountDocuments(cctx, filter, opt)
+		if err != nil {
+			logs.Qezap.Error("FindLoggingCount", zap.Error(err))
+		}
+		countResp <- c
+	}()
+
+	sort := bson.D{{Key: "ts", Value: -1}, {Key: "_id", Value: -1}}
+	if cursor != nil {
+		sort = cursor.Sort()
+	}
+	docs := make([]*model.Logging, 0, page.Limit)
+	opt := page.SetPage(options.Find()).SetSort(sort)
+	err = inst.Find(ctx, cName, withCursor(filter, cursor), &docs, opt)
+	if err != nil {
+		if strings.Contains(err.Error(), "text index required") {
+			return 0, docs, errc.ErrParamInvalid.MultiMsg("collection full-text index not created, enabled after this collection created")
+		}
+		return 0, docs, errc.ErrInternalErr.MultiErr(err)
+	}
+	if cursor != nil && cursor.Prev {
+		reverse(docs)
+	}
+
+	c := <-countResp
+	if c <= 0 {
+		c = int64(len(docs))
+	}
+	return c, docs, nil
+}
+
+func reverse(docs []*model.Logging) {
+	for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+		docs[i], docs[j] = docs[j], docs[i]
+	}
+}
+
+func (s *MongoStore) FindLoggingAfter(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, limit int64) ([]*model.Logging, error) {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	docs := make([]*model.Logging, 0, limit)
+	opt := options.Find().SetSort(cursor.Sort()).SetLimit(limit)
+	if err := inst.Find(ctx, cName, withCursor(filter, cursor), &docs, opt); err != nil {
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return docs, nil
+}
+
+func (s *MongoStore) FindLoggingByTraceID(ctx context.Context, dbName string, cNames []string, moduleName, traceID string) ([]*model.Logging, error) {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	allDocs := make([]*model.Logging, 0)
+	for _, cName := range cNames {
+		filter := bson.M{
+			"m":  moduleName,
+			"ti": traceID,
+		}
+		// asc logging by time
+		opt := options.Find().SetSort(bson.M{"ts": 1})
+		docs := make([]*model.Logging, 0)
+		if err := inst.Find(ctx, cName, filter, &docs, opt); err != nil {
+			return nil, errc.ErrInternalErr.MultiErr(err)
+		}
+		allDocs = append(allDocs, docs...)
+	}
+	return allDocs, nil
+}
+
+func (s *MongoStore) ListCollectionNames(ctx context.Context, dbName string, prefix ...string) ([]string, error) {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return nil, err
+	}
+	return inst.ListCollectionNames(ctx, prefix...)
+}
+
+func (s *MongoStore) DropLoggingCollection(ctx context.Context, dbName, cName string) error {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return errc.ErrParamInvalid.MultiErr(err)
+	}
+	if err := inst.Collection(cName).Drop(ctx); err != nil {
+		return errc.ErrInternalErr.MultiErr(err)
+	}
+	return nil
+}
+
+func (s *MongoStore) CollStats(ctx context.Context, dbName, cName string) (*CollStats, error) {
+	inst, err := s.groups.GetInstance(dbName)
+	if err != nil {
+		return nil, errc.ErrParamInvalid.MultiErr(err)
+	}
+	stats, err := mongo.NewCommand(inst).CollStats(ctx, cName)
+	if err != nil {
+		return nil, errc.ErrInternalErr.MultiErr(err)
+	}
+	return &CollStats{Count: stats.Count, Size: stats.Size, StorageSize: stats.StorageSize}, nil
+}
+
+// Close mongo client shared with dao, closed by dao
+func (s *MongoStore) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bbdshow/bkit/db/mongo"
+	"github.com/bbdshow/qelog/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TypeMongo = "mongo"
+	TypeLocal = "local"
+	// TypeClickHouse module storage type, module logging in clickhouse database
+	TypeClickHouse = "clickhouse"
+)
+
+// MaxCount count logging max number, too many counts meaningless, and poor performance
+const MaxCount = 50000
+
+// LogStore logging storage backend, logging partitioned by shard collection in database.
+// filter is mongo query filter, backend not mongo evaluate operators used by logging query:
+// $and $or $nor $not $ne $gt $gte $lt $lte $in $nin $text and regex
+type LogStore interface {
+	CreateManyLogging(ctx context.Context, dbName, cName string, docs []interface{}) error
+	// CreateLoggingIndex create collection and index, fullText create text index
+	CreateLoggingIndex(ctx context.Context, dbName, cName string, fullText bool) error
+	CreateLoggingTextIndex(ctx context.Context, dbName, cName string) error
+	// DropLoggingTextIndex index not exists ignore
+	DropLoggingTextIndex(ctx context.Context, dbName, cName string) error
+
+	// FindLogging order by ts desc, _id desc. cursor not nil, find logging after cursor, count still total of filter
+	FindLogging(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, page model.PageReq) (int64, []*model.Logging, error)
+	// FindLoggingAfter without count, logging order by cursor sort
+	FindLoggingAfter(ctx context.Context, dbName, cName string, filter bson.M, cursor *model.LoggingCursor, limit int64) ([]*model.Logging, error)
+	// FindLoggingByTraceID order by ts asc
+	FindLoggingByTraceID(ctx context.Context, dbName string, cNames []string, moduleName, traceID string) ([]*model.Logging, error)
+
+	ListCollectionNames(ctx context.Context, dbName string, prefix ...string) ([]string, error)
+	DropLoggingCollection(ctx context.Context, dbName, cName string) error
+	CollStats(ctx context.Context, dbName, cName string) (*CollStats, error)
+	Close() error
+}
+
+// CollStats logging collection storage cost, size uncompressed, storage size on disk
+type CollStats struct {
+	Count       int64
+	Size        int64
+	StorageSize int64
+}
+
+func withCursor(filter bson.M, cursor *model.LoggingCursor) bson.M {
+	if cursor == nil {
+		return filter
+	}
+	v := bson.M{"$or": cursor.Filter()}
+	for k, val := range filter {
+		v[k] = val
+	}
+	return v
+}
+
+// toLogging written document as logging, id assigned if empty
+func toLogging(v interface{}) (*model.Logging, error) {
+	l := &model.Logging{}
+	if doc, ok := v.(*model.Logging); ok {
+		*l = *doc
+	} else {
+		raw, err := bson.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(raw, l); err != nil {
+			return nil, err
+		}
+	}
+	if l.ID.IsZero() {
+		l.ID = primitive.NewObjectID()
+	}
+	return l, nil
+}
+
+func errUnsupported(typ string) error {
+	return fmt.Errorf("unsupported storage type '%s'", typ)
+}
+
+// New storage by type, mongo groups used by mongo type
+func New(typ, dir string, groups *mongo.Groups) (LogStore, error) {
+	switch typ {
+	case "", TypeMongo:
+		return NewMongoStore(groups), nil
+	case TypeLocal:
+		return NewLocalStore(dir)
+	}
+	return nil, errUnsupported(typ)
+}
@@ -8,11 +8,12 @@ const (
 	Single          ServerMode = "single" // 默认单节点部署
 	ClusterAdmin    ServerMode = "cluster_admin"
 	ClusterReceiver ServerMode = "cluster_receiver"
+	Embedded        ServerMode = "embedded" // single node without mongo, all data in local storage
 )
 
 // GetFlagOrOSEnvServerMode flag or os env server mode
 func GetFlagOrOSEnvServerMode(mode ServerMode) ServerMode {
-	if mode == Single || mode == ClusterAdmin || mode == ClusterReceiver {
+	if mode == Single || mode == ClusterAdmin || mode == ClusterReceiver || mode == Embedded {
 		return mode
 	}
 
@@ -22,6 +23,8 @@ func GetFlagOrOSEnvServerMode(mode ServerMode) ServerMode {
 		return ClusterAdmin
 	case ClusterReceiver:
 		return ClusterReceiver
+	case Embedded:
+		return Embedded
 	}
 	return Single
 }
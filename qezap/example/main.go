@@ -25,8 +25,9 @@ func main() {
 	local.SetEnabledLevel(zapcore.InfoLevel)
 	local.Debug("Debug", zap.String("val", "this msg,not written to file"))
 
-	// support remote storage
-	multi := qezap.New(qezap.WithAddrsAndModuleName([]string{"127.0.0.1:31082"}, "demo"))
+	// support remote storage, access token copy from admin manager module list
+	multi := qezap.New(qezap.WithAddrsAndModuleName([]string{"127.0.0.1:31082"}, "demo"),
+		qezap.WithAccessToken("module access token"))
 	defer multi.Close()
 
 	multi.Info("local fs and remote storage will be written")
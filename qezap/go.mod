@@ -3,18 +3,19 @@ module github.com/bbdshow/qelog/qezap
 go 1.17
 
 require (
-	github.com/bbdshow/qelog/api v1.1.0
+	github.com/bbdshow/qelog/api v1.2.0
 	go.uber.org/multierr v1.5.0
 	go.uber.org/zap v1.16.0
 	google.golang.org/grpc v1.34.1
+	google.golang.org/protobuf v1.25.0
 )
 
 require (
 	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/klauspost/compress v1.9.5 // indirect
 	go.uber.org/atomic v1.6.0 // indirect
 	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
 	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
-	google.golang.org/protobuf v1.25.0 // indirect
 )
@@ -48,7 +48,8 @@ func (r *localResolver) start() {
 	addr := r.addressStore[r.target.Endpoint]
 	address := make([]resolver.Address, len(addr))
 	for i, s := range addr {
-		address[i] = resolver.Address{Addr: s}
+		// ServerName used to TLS handshake verify server certificate
+		address[i] = resolver.Address{Addr: s, ServerName: s}
 	}
 	r.cc.UpdateState(resolver.State{Addresses: address})
 }
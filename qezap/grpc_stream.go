@@ -0,0 +1,164 @@
+package qezap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/bbdshow/qelog/api/receiverpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ackCodeSuccess     = 0
+	ackCodeInternalErr = 1
+)
+
+var (
+	errStreamUnimplemented = errors.New("server not support push stream")
+)
+
+// pushStream keep PushStream rpc open, packets send continuously, wait ack by packet id
+type pushStream struct {
+	stream receiverpb.Receiver_PushStreamClient
+	cancel context.CancelFunc
+
+	// grpc stream send not concurrent safe
+	sendMutex sync.Mutex
+
+	mutex   sync.Mutex
+	pending map[string][]chan *receiverpb.PacketAck
+	done    chan struct{}
+	err     error
+}
+
+func newPushStream(cli receiverpb.ReceiverClient) (*pushStream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := cli.PushStream(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s := &pushStream{
+		stream:  stream,
+		cancel:  cancel,
+		pending: make(map[string][]chan *receiverpb.PacketAck),
+		done:    make(chan struct{}),
+	}
+	go s.bgRecvAck()
+	return s, nil
+}
+
+// Push send packet and wait ack, ack code internal error or stream broken returns ErrUnavailable
+func (s *pushStream) Push(ctx context.Context, in *receiverpb.Packet) error {
+	ackC := make(chan *receiverpb.PacketAck, 1)
+	s.mutex.Lock()
+	if s.IsDone() {
+		s.mutex.Unlock()
+		return s.doneErr()
+	}
+	s.pending[in.Id] = append(s.pending[in.Id], ackC)
+	s.mutex.Unlock()
+	defer s.removePending(in.Id, ackC)
+
+	s.sendMutex.Lock()
+	err := s.stream.Send(in)
+	s.sendMutex.Unlock()
+	// io.EOF stream aborted, real error returned by Recv
+	if err != nil && err != io.EOF {
+		s.close(err)
+		return s.doneErr()
+	}
+
+	select {
+	case ack := <-ackC:
+		switch ack.Code {
+		case ackCodeSuccess:
+			return nil
+		case ackCodeInternalErr:
+			log.Printf("Pusher:grpc stream ack %s\n", ack.String())
+			return ErrUnavailable
+		}
+		return fmt.Errorf("response error %s", ack.String())
+	case <-s.done:
+		return s.doneErr()
+	case <-ctx.Done():
+		return ErrUnavailable
+	}
+}
+
+func (s *pushStream) bgRecvAck() {
+	for {
+		ack, err := s.stream.Recv()
+		if err != nil {
+			s.close(err)
+			return
+		}
+		s.mutex.Lock()
+		if cs := s.pending[ack.Id]; len(cs) > 0 {
+			cs[0] <- ack
+			s.pending[ack.Id] = cs[1:]
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *pushStream) removePending(id string, ackC chan *receiverpb.PacketAck) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cs := s.pending[id]
+	for i, c := range cs {
+		if c == ackC {
+			cs = append(cs[:i], cs[i+1:]...)
+			break
+		}
+	}
+	if len(cs) == 0 {
+		delete(s.pending, id)
+		return
+	}
+	s.pending[id] = cs
+}
+
+// IsDone stream broken, should open new stream
+func (s *pushStream) IsDone() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *pushStream) doneErr() error {
+	if status.Code(s.err) == codes.Unimplemented {
+		return errStreamUnimplemented
+	}
+	// any error, the server is considered unavailable
+	log.Printf("Pusher:grpc stream %s\n", s.err)
+	return ErrUnavailable
+}
+
+func (s *pushStream) close(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.IsDone() {
+		return
+	}
+	s.err = err
+	close(s.done)
+	s.cancel()
+}
+
+// Close half close stream, release resource
+func (s *pushStream) Close() error {
+	s.sendMutex.Lock()
+	err := s.stream.CloseSend()
+	s.sendMutex.Unlock()
+	s.close(io.EOF)
+	return err
+}
@@ -1,17 +1,21 @@
 package qezap
 
 import (
+	"crypto/tls"
 	"fmt"
 	"path"
+	"strings"
 	"time"
 
+	"github.com/bbdshow/qelog/api/receiverpb"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type (
-	Transport string
-	Mode      string
+	Transport   string
+	Mode        string
+	Compression string
 )
 
 const (
@@ -21,6 +25,11 @@ const (
 
 	ModeRelease Mode = "RELEASE"
 	ModeDebug   Mode = "DEBUG"
+
+	CompressionNone   Compression = "NONE"
+	CompressionGzip   Compression = "GZIP"
+	CompressionSnappy Compression = "SNAPPY"
+	CompressionZstd   Compression = "ZSTD"
 )
 
 type LocalOption struct {
@@ -52,25 +61,79 @@ type remoteOption struct {
 	Addrs []string
 	// qelog admin register module name, equal to access token.
 	ModuleName string
+	// module access token, admin manager generate and rotate, used to permission verify
+	AccessToken string
+	// TLS config, RootCAs verify receiver server certificate. nil and client certificate empty, disable TLS
+	TLSConfig *tls.Config
+	// client certificate pem file, receiver server require mutual TLS
+	ClientCertFile string
+	ClientKeyFile  string
 	// remote push max concurrent. if concurrent setting, data will be written backup file, bg retry send.
 	// concurrent decision I/O max transfer. MAX=(MaxPacketSize*MaxConcurrent) default: 50
 	MaxConcurrent int
 	// send packet max size.  grpc client default body size max 4MB, but here default setting 32KB.
 	// this setting 32KB + sync.Pool impl, can reduce memory overhead and friendly GC
 	MaxPacketSize int
-	// writeTimeout default 5s, if timeout, will be written backup file
+	// writeTimeout default 5s, if timeout, will be written wal
 	WriteTimeout time.Duration
-	// back fs filename
-	BackupFilename string
+	// packet data compression, HTTP transport only support GZIP, other algorithm use GZIP instead, unknown use NONE.
+	// receiver server version must support compression. default: NONE
+	Compression Compression
+	// write ahead log directory, packet push failed will be written, bg replay send
+	WALDir string
+	// wal single segment file max size. default: 8MB
+	WALSegmentSize int64
+	// wal all segment files max size, if exceeded, packet will be discarded, 0 unlimited. default: 1GB
+	WALMaxSize int64
+	// wal replay max concurrent. default: 5
+	WALReplayConcurrent int
+}
+
+// tlsConfig returns nil if TLS disabled, client certificate loaded into TLSConfig copy
+func (o *remoteOption) tlsConfig() (*tls.Config, error) {
+	if o.TLSConfig == nil && o.ClientCertFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{}
+	if o.TLSConfig != nil {
+		cfg = o.TLSConfig.Clone()
+	}
+	if o.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate %v", err)
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+	return cfg, nil
+}
+
+// fallbackCompression unsupported compression not fail logger, unknown fall back to NONE,
+// HTTP transport only GZIP supported, SNAPPY ZSTD fall back to GZIP. returns warning if fell back
+func (o *remoteOption) fallbackCompression() error {
+	c := o.Compression
+	if _, ok := receiverpb.Compression_value[string(c)]; !ok {
+		o.Compression = CompressionNone
+		return fmt.Errorf("compression '%s' unsupported, NONE instead", c)
+	}
+	if o.Transport == TransportHTTP && c != CompressionNone && c != CompressionGzip {
+		o.Compression = CompressionGzip
+		return fmt.Errorf("HTTP transport compression '%s' unsupported, GZIP instead", c)
+	}
+	return nil
 }
 
 func defaultRemoteOption() *remoteOption {
 	return &remoteOption{
-		Transport:      TransportGRPC,
-		MaxConcurrent:  50,
-		MaxPacketSize:  32 << 10,
-		WriteTimeout:   5 * time.Second,
-		BackupFilename: "./log/backup/bak.logger.log",
+		Transport:           TransportGRPC,
+		MaxConcurrent:       50,
+		MaxPacketSize:       32 << 10,
+		WriteTimeout:        5 * time.Second,
+		Compression:         CompressionNone,
+		WALDir:              "./log/wal/logger",
+		WALSegmentSize:      8 << 20,
+		WALMaxSize:          1 << 30,
+		WALReplayConcurrent: 5,
 	}
 }
 
@@ -115,7 +178,7 @@ func WithFilename(filename string) Option {
 	return newSetOption(func(o *options) {
 		dir, file := path.Split(filename)
 		o.Local.Filename = filename
-		o.Remote.BackupFilename = path.Join(dir, "backup", fmt.Sprintf("bak.%s", file))
+		o.Remote.WALDir = path.Join(dir, "wal", strings.TrimSuffix(file, path.Ext(file)))
 	})
 }
 
@@ -205,3 +268,57 @@ func WithRemoteWriteTimeout(timeout time.Duration) Option {
 		o.Remote.WriteTimeout = timeout
 	})
 }
+
+// WithAccessToken module access token, admin manager module list can copy it
+func WithAccessToken(token string) Option {
+	return newSetOption(func(o *options) {
+		o.Remote.AccessToken = token
+	})
+}
+
+// WithTLSConfig enable TLS transport, HTTP address must be https
+func WithTLSConfig(cfg *tls.Config) Option {
+	return newSetOption(func(o *options) {
+		o.Remote.TLSConfig = cfg
+	})
+}
+
+// WithClientCertificate enable TLS transport, client certificate pem file, receiver server require mutual TLS
+func WithClientCertificate(certFile, keyFile string) Option {
+	return newSetOption(func(o *options) {
+		o.Remote.ClientCertFile = certFile
+		o.Remote.ClientKeyFile = keyFile
+	})
+}
+
+// WithRemoteCompression setting logger remote packet compression, NONE GZIP SNAPPY ZSTD
+func WithRemoteCompression(c Compression) Option {
+	return newSetOption(func(o *options) {
+		o.Remote.Compression = c
+	})
+}
+
+// WithWALSize setting remote write ahead log segment file size and total size limit, unit byte
+func WithWALSize(segmentSize, maxSize uint64) Option {
+	return newSetOption(func(o *options) {
+		if segmentSize > 0 {
+			o.Remote.WALSegmentSize = int64(segmentSize)
+		}
+		o.Remote.WALMaxSize = int64(maxSize)
+	})
+}
+
+// WithWALReplayConcurrent setting remote write ahead log replay max concurrent
+func WithWALReplayConcurrent(n uint) Option {
+	return newSetOption(func(o *options) {
+		if n <= 0 {
+			n = 1
+		}
+		o.Remote.WALReplayConcurrent = int(n)
+	})
+}
+
+// pb compression checked by remoteOption fallbackCompression
+func (c Compression) pb() receiverpb.Compression {
+	return receiverpb.Compression(receiverpb.Compression_value[string(c)])
+}
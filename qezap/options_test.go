@@ -1,6 +1,7 @@
 package qezap
 
 import (
+	"crypto/tls"
 	"testing"
 	"time"
 
@@ -25,6 +26,14 @@ func TestOption(t *testing.T) {
 		MaxConcurrent uint
 		MaxPacketSize uint
 		Timeout       time.Duration
+		WALSegment    uint64
+		WALMax        uint64
+		WALConcurrent uint
+		Compression   Compression
+		AccessToken   string
+		TLSConfig     *tls.Config
+		CertFile      string
+		KeyFile       string
 	}{
 		{
 			Filename:      "./log/x.log",
@@ -40,6 +49,10 @@ func TestOption(t *testing.T) {
 			MaxConcurrent: 0,
 			MaxPacketSize: 0,
 			Timeout:       0,
+			WALSegment:    0,
+			WALMax:        0,
+			WALConcurrent: 0,
+			Compression:   CompressionNone,
 		},
 		{
 			Filename:      "./log/remote.log",
@@ -55,6 +68,14 @@ func TestOption(t *testing.T) {
 			MaxConcurrent: 5,
 			MaxPacketSize: 5000,
 			Timeout:       3 * time.Second,
+			WALSegment:    1 << 20,
+			WALMax:        64 << 20,
+			WALConcurrent: 3,
+			Compression:   CompressionZstd,
+			AccessToken:   "testing-token",
+			TLSConfig:     &tls.Config{ServerName: "qelog"},
+			CertFile:      "./client.crt",
+			KeyFile:       "./client.key",
 		},
 	}
 
@@ -73,6 +94,12 @@ func TestOption(t *testing.T) {
 			WithRemoteConcurrent(v.MaxConcurrent),
 			WithRemotePacketSize(v.MaxPacketSize),
 			WithRemoteWriteTimeout(v.Timeout),
+			WithWALSize(v.WALSegment, v.WALMax),
+			WithWALReplayConcurrent(v.WALConcurrent),
+			WithRemoteCompression(v.Compression),
+			WithAccessToken(v.AccessToken),
+			WithTLSConfig(v.TLSConfig),
+			WithClientCertificate(v.CertFile, v.KeyFile),
 		}
 
 		for _, v := range opts {
@@ -118,7 +145,44 @@ func TestOption(t *testing.T) {
 			if int(v.MaxConcurrent) != opt.Remote.MaxConcurrent {
 				t.Fatalf("case %d: opt %v", i, opt.Remote)
 			}
+			if int64(v.WALSegment) != opt.Remote.WALSegmentSize || int64(v.WALMax) != opt.Remote.WALMaxSize {
+				t.Fatalf("case %d: opt %v", i, opt.Remote)
+			}
+			if int(v.WALConcurrent) != opt.Remote.WALReplayConcurrent {
+				t.Fatalf("case %d: opt %v", i, opt.Remote)
+			}
+			if v.Compression != opt.Remote.Compression || v.AccessToken != opt.Remote.AccessToken {
+				t.Fatalf("case %d: opt %v", i, opt.Remote)
+			}
+			if v.TLSConfig != opt.Remote.TLSConfig || v.CertFile != opt.Remote.ClientCertFile || v.KeyFile != opt.Remote.ClientKeyFile {
+				t.Fatalf("case %d: opt %v", i, opt.Remote)
+			}
 		}
 
 	}
 }
+
+func TestRemoteOptionFallbackCompression(t *testing.T) {
+	for i, v := range []struct {
+		Transport Transport
+		In        Compression
+		Want      Compression
+		Warn      bool
+	}{
+		{TransportGRPC, CompressionNone, CompressionNone, false},
+		{TransportGRPC, CompressionZstd, CompressionZstd, false},
+		{TransportGRPC, "", CompressionNone, true},
+		{TransportGRPC, "LZ4", CompressionNone, true},
+		{TransportHTTP, CompressionGzip, CompressionGzip, false},
+		{TransportHTTP, CompressionSnappy, CompressionGzip, true},
+		{TransportHTTP, "gzip", CompressionNone, true},
+	} {
+		opt := defaultOptions()
+		WithTransport(v.Transport).apply(opt)
+		WithRemoteCompression(v.In).apply(opt)
+		err := opt.Remote.fallbackCompression()
+		if (err != nil) != v.Warn || opt.Remote.Compression != v.Want {
+			t.Fatalf("case %d: compression %s warning %v", i, opt.Remote.Compression, err)
+		}
+	}
+}
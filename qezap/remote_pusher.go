@@ -9,11 +9,15 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/resolver"
 
+	"github.com/bbdshow/qelog/api"
 	"github.com/bbdshow/qelog/api/receiverpb"
 	"google.golang.org/grpc"
 )
@@ -29,12 +33,24 @@ type Pusher interface {
 }
 
 type gRRCPush struct {
-	cli   receiverpb.ReceiverClient
-	conn  *grpc.ClientConn
-	cChan chan struct{}
+	cli         receiverpb.ReceiverClient
+	conn        *grpc.ClientConn
+	cChan       chan struct{}
+	compression receiverpb.Compression
+
+	// one stream each address, round_robin balancer pick connection when stream open.
+	// if server not support stream, fall back to unary rpc, stream retried after streamRetryInterval
+	streamMutex      sync.Mutex
+	streams          []*pushStream
+	streamNext       uint32
+	streamDisabledAt int64
 }
 
-func newGRPCPush(addrs []string, concurrent int) (*gRRCPush, error) {
+// server not support stream maybe upgraded, stream retried after interval
+const streamRetryInterval = 5 * time.Minute
+
+func newGRPCPush(opt *remoteOption) (*gRRCPush, error) {
+	addrs, concurrent := opt.Addrs, opt.MaxConcurrent
 	if len(addrs) == 0 {
 		return nil, fmt.Errorf("addrs required")
 	}
@@ -44,20 +60,34 @@ func newGRPCPush(addrs []string, concurrent int) (*gRRCPush, error) {
 
 	resolver.Register(NewLocalResolverBuilder(addrs))
 
+	tlsCfg, err := opt.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"LoadBalancingPolicy": "%s"}`, roundrobin.Name)),
+	}
+	if tlsCfg != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+	if opt.AccessToken != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(accessTokenCredentials(opt.AccessToken)))
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	// warning: disable permission verify
-	conn, err := grpc.DialContext(ctx, DialLocalServiceName,
-		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"LoadBalancingPolicy": "%s"}`, roundrobin.Name)),
-		grpc.WithInsecure())
+	conn, err := grpc.DialContext(ctx, DialLocalServiceName, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
 
 	gp := &gRRCPush{
-		cli:   receiverpb.NewReceiverClient(conn),
-		conn:  conn,
-		cChan: make(chan struct{}, concurrent),
+		cli:         receiverpb.NewReceiverClient(conn),
+		conn:        conn,
+		cChan:       make(chan struct{}, concurrent),
+		compression: opt.Compression.pb(),
+		streams:     make([]*pushStream, len(addrs)),
 	}
 
 	return gp, nil
@@ -69,6 +99,20 @@ func (gp *gRRCPush) PushPacket(ctx context.Context, in *receiverpb.Packet) error
 		<-gp.cChan
 	}()
 
+	in, err := compressPacket(gp.compression, in)
+	if err != nil {
+		return err
+	}
+
+	if gp.streamEnabled() {
+		err := gp.pushStream(ctx, in)
+		if err != errStreamUnimplemented {
+			return err
+		}
+		atomic.StoreInt64(&gp.streamDisabledAt, time.Now().UnixNano())
+		log.Printf("Pusher:grpc server not support stream, fall back to unary\n")
+	}
+
 	resp, err := gp.cli.PushPacket(ctx, in)
 	if err != nil {
 		// any error, the server is considered unavailable
@@ -82,34 +126,106 @@ func (gp *gRRCPush) PushPacket(ctx context.Context, in *receiverpb.Packet) error
 	return nil
 }
 
+// streamEnabled stream never disabled, or disabled before retry interval
+func (gp *gRRCPush) streamEnabled() bool {
+	at := atomic.LoadInt64(&gp.streamDisabledAt)
+	return at == 0 || time.Since(time.Unix(0, at)) > streamRetryInterval
+}
+
+func (gp *gRRCPush) pushStream(ctx context.Context, in *receiverpb.Packet) error {
+	gp.streamMutex.Lock()
+	i := gp.streamNext % uint32(len(gp.streams))
+	gp.streamNext++
+	s := gp.streams[i]
+	if s == nil || s.IsDone() {
+		var err error
+		s, err = newPushStream(gp.cli)
+		if err != nil {
+			gp.streamMutex.Unlock()
+			log.Printf("Pusher:grpc open stream %s\n", err)
+			return ErrUnavailable
+		}
+		gp.streams[i] = s
+	}
+	gp.streamMutex.Unlock()
+
+	return s.Push(ctx, in)
+}
+
 func (gp *gRRCPush) Concurrent() int {
 	return len(gp.cChan)
 }
 
 func (gp *gRRCPush) Close() error {
+	gp.streamMutex.Lock()
+	for _, s := range gp.streams {
+		if s != nil {
+			_ = s.Close()
+		}
+	}
+	gp.streamMutex.Unlock()
 	if gp.conn != nil {
 		return gp.conn.Close()
 	}
 	return nil
 }
 
+// accessTokenCredentials every rpc carry module access token by metadata
+type accessTokenCredentials string
+
+func (t accessTokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{api.AccessTokenKey: string(t)}, nil
+}
+
+// RequireTransportSecurity token allowed to send by insecure connection
+func (t accessTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// compressPacket returns new packet with compressed data, source packet data may be reused by pool
+func compressPacket(c receiverpb.Compression, in *receiverpb.Packet) (*receiverpb.Packet, error) {
+	if c == receiverpb.Compression_NONE || len(in.Data) == 0 {
+		return in, nil
+	}
+	data, err := receiverpb.Compress(c, in.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &receiverpb.Packet{Id: in.Id, Module: in.Module, Data: data, Compression: c}, nil
+}
+
 type httpPush struct {
 	addr   string
 	client *http.Client
+	gzip   bool
+	token  string
 
 	cChan chan struct{}
 }
 
-func newHttpPush(addr []string, concurrent int) (*httpPush, error) {
+func newHttpPush(opt *remoteOption) (*httpPush, error) {
+	addr, concurrent := opt.Addrs, opt.MaxConcurrent
 	if len(addr) == 0 {
 		return nil, fmt.Errorf("addr required")
 	}
 	if concurrent <= 0 {
 		concurrent = 5
 	}
+	tlsCfg, err := opt.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{}
+	if tlsCfg != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsCfg
+		client.Transport = transport
+	}
 	hp := &httpPush{
 		addr:   addr[0],
-		client: &http.Client{},
+		client: client,
+		gzip:   opt.Compression.pb() != receiverpb.Compression_NONE,
+		token:  opt.AccessToken,
 		cChan:  make(chan struct{}, concurrent),
 	}
 
@@ -149,12 +265,23 @@ func (hp *httpPush) push(ctx context.Context, body interface{}) error {
 	if err != nil {
 		return err
 	}
+	if hp.gzip {
+		if byt, err = receiverpb.Compress(receiverpb.Compression_GZIP, byt); err != nil {
+			return err
+		}
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", hp.addr, bytes.NewReader(byt))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if hp.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if hp.token != "" {
+		req.Header.Set(api.AccessTokenKey, hp.token)
+	}
 	resp, err := hp.client.Do(req)
 	if err != nil {
 		log.Printf("Pusher:http %s\n", err)
@@ -185,7 +312,8 @@ type mockPush struct {
 	cChan chan struct{}
 }
 
-func newMockPush(addrs []string, concurrent int) (*mockPush, error) {
+func newMockPush(opt *remoteOption) (*mockPush, error) {
+	addrs, concurrent := opt.Addrs, opt.MaxConcurrent
 	if len(addrs) == 0 {
 		return nil, fmt.Errorf("addrs required")
 	}
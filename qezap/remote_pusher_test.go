@@ -0,0 +1,368 @@
+package qezap
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bbdshow/qelog/api"
+	"github.com/bbdshow/qelog/api/receiverpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// testReceiver impl receiverpb.ReceiverServer, count packets received by unary and stream
+type testReceiver struct {
+	unary  int32
+	stream int32
+}
+
+func (r *testReceiver) PushPacket(_ context.Context, _ *receiverpb.Packet) (*receiverpb.BaseResp, error) {
+	atomic.AddInt32(&r.unary, 1)
+	return &receiverpb.BaseResp{Code: 0, Message: "success"}, nil
+}
+
+func (r *testReceiver) PushStream(stream receiverpb.Receiver_PushStreamServer) error {
+	for {
+		in, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		atomic.AddInt32(&r.stream, 1)
+		if err := stream.Send(&receiverpb.PacketAck{Id: in.Id, Code: 0, Message: "success"}); err != nil {
+			return err
+		}
+	}
+}
+
+// testUnaryReceiver server not support stream, like old version receiver
+type testUnaryReceiver struct {
+	receiverpb.UnimplementedReceiverServer
+	unary int32
+}
+
+func (r *testUnaryReceiver) PushPacket(_ context.Context, _ *receiverpb.Packet) (*receiverpb.BaseResp, error) {
+	atomic.AddInt32(&r.unary, 1)
+	return &receiverpb.BaseResp{Code: 0, Message: "success"}, nil
+}
+
+func testGRPCServer(t *testing.T, srv receiverpb.ReceiverServer) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	receiverpb.RegisterReceiverServer(s, srv)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	return lis.Addr().String(), s.Stop
+}
+
+func testRemoteOption(addr string) *remoteOption {
+	opt := defaultRemoteOption()
+	opt.Addrs = []string{addr}
+	opt.MaxConcurrent = 5
+	return opt
+}
+
+func testNewGRPCPush(t *testing.T, opt *remoteOption) *gRRCPush {
+	gp, err := newGRPCPush(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gp
+}
+
+func testPushPackets(t *testing.T, gp *gRRCPush, n int) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			in := &receiverpb.Packet{Id: id(), Module: "testing", Data: []byte("packet" + strconv.Itoa(i))}
+			if err := gp.PushPacket(ctx, in); err != nil {
+				t.Errorf("case %d: push %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGRPCPush_Stream(t *testing.T) {
+	srv := &testReceiver{}
+	addr, stop := testGRPCServer(t, srv)
+	defer stop()
+
+	gp := testNewGRPCPush(t, testRemoteOption(addr))
+	defer gp.Close()
+
+	testPushPackets(t, gp, 20)
+	if atomic.LoadInt32(&srv.stream) != 20 || atomic.LoadInt32(&srv.unary) != 0 {
+		t.Fatalf("stream %d unary %d, want all packets by stream", srv.stream, srv.unary)
+	}
+}
+
+func TestGRPCPush_FallbackUnary(t *testing.T) {
+	srv := &testUnaryReceiver{}
+	addr, stop := testGRPCServer(t, srv)
+	defer stop()
+
+	gp := testNewGRPCPush(t, testRemoteOption(addr))
+	defer gp.Close()
+
+	testPushPackets(t, gp, 10)
+	if gp.streamEnabled() {
+		t.Fatal("stream should be disabled")
+	}
+	if atomic.LoadInt32(&srv.unary) != 10 {
+		t.Fatalf("unary %d, want 10", srv.unary)
+	}
+
+	// retry interval passed, stream tried again
+	atomic.StoreInt64(&gp.streamDisabledAt, time.Now().Add(-streamRetryInterval-time.Second).UnixNano())
+	if !gp.streamEnabled() {
+		t.Fatal("stream should be retried")
+	}
+	testPushPackets(t, gp, 1)
+	if gp.streamEnabled() || atomic.LoadInt32(&srv.unary) != 11 {
+		t.Fatalf("stream retried not disabled again, unary %d", srv.unary)
+	}
+}
+
+// testDecompressReceiver decompress packet data, count packets data not match
+type testDecompressReceiver struct {
+	receiverpb.UnimplementedReceiverServer
+	compressed int32
+	invalid    int32
+}
+
+func (r *testDecompressReceiver) PushPacket(_ context.Context, in *receiverpb.Packet) (*receiverpb.BaseResp, error) {
+	if in.Compression != receiverpb.Compression_NONE {
+		atomic.AddInt32(&r.compressed, 1)
+	}
+	data, err := receiverpb.Decompress(in.Compression, in.Data)
+	if err != nil || !strings.HasPrefix(string(data), "packet") {
+		atomic.AddInt32(&r.invalid, 1)
+	}
+	return &receiverpb.BaseResp{Code: 0, Message: "success"}, nil
+}
+
+func TestGRPCPush_Compression(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionSnappy, CompressionZstd} {
+		srv := &testDecompressReceiver{}
+		addr, stop := testGRPCServer(t, srv)
+
+		opt := testRemoteOption(addr)
+		opt.Compression = c
+		gp := testNewGRPCPush(t, opt)
+		testPushPackets(t, gp, 10)
+		_ = gp.Close()
+		stop()
+
+		if atomic.LoadInt32(&srv.compressed) != 10 || atomic.LoadInt32(&srv.invalid) != 0 {
+			t.Fatalf("%s: compressed %d invalid %d", c, srv.compressed, srv.invalid)
+		}
+	}
+}
+
+// testTokenReceiver count packets carry token metadata
+type testTokenReceiver struct {
+	receiverpb.UnimplementedReceiverServer
+	token  string
+	passed int32
+}
+
+func (r *testTokenReceiver) PushPacket(ctx context.Context, _ *receiverpb.Packet) (*receiverpb.BaseResp, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(api.AccessTokenKey); len(v) > 0 && v[0] == r.token {
+		atomic.AddInt32(&r.passed, 1)
+	}
+	return &receiverpb.BaseResp{Code: 0, Message: "success"}, nil
+}
+
+func TestGRPCPush_AccessToken(t *testing.T) {
+	srv := &testTokenReceiver{token: "testing-token"}
+	addr, stop := testGRPCServer(t, srv)
+	defer stop()
+
+	opt := testRemoteOption(addr)
+	opt.AccessToken = srv.token
+	gp := testNewGRPCPush(t, opt)
+	defer gp.Close()
+
+	testPushPackets(t, gp, 10)
+	if atomic.LoadInt32(&srv.passed) != 10 {
+		t.Fatalf("passed %d, want 10", srv.passed)
+	}
+}
+
+// testCertificates generate self-signed CA, server certificate and client certificate pem file written in dir
+func testCertificates(t *testing.T, dir string) (*x509.CertPool, tls.Certificate, string, string) {
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	caKey := newKey()
+	caTpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "qelog testing ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTpl, caTpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key := newKey()
+		tpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "127.0.0.1"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	serverCert, err := tls.X509KeyPair(issue(2, x509.ExtKeyUsageServerAuth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM := issue(3, x509.ExtKeyUsageClientAuth)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return pool, serverCert, certFile, keyFile
+}
+
+func TestGRPCPush_MutualTLS(t *testing.T) {
+	dir := "./log/tls/grpc"
+	defer os.RemoveAll(dir)
+	pool, serverCert, certFile, keyFile := testCertificates(t, dir)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	srv := &testReceiver{}
+	receiverpb.RegisterReceiverServer(s, srv)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	defer s.Stop()
+
+	opt := testRemoteOption(lis.Addr().String())
+	opt.TLSConfig = &tls.Config{RootCAs: pool}
+	opt.ClientCertFile, opt.ClientKeyFile = certFile, keyFile
+	gp := testNewGRPCPush(t, opt)
+	defer gp.Close()
+
+	testPushPackets(t, gp, 10)
+	if atomic.LoadInt32(&srv.stream) != 10 {
+		t.Fatalf("stream %d, want 10", srv.stream)
+	}
+}
+
+func TestHttpPush_MutualTLS(t *testing.T) {
+	dir := "./log/tls/http"
+	defer os.RemoveAll(dir)
+	pool, serverCert, certFile, keyFile := testCertificates(t, dir)
+
+	var received int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	opt := testRemoteOption(ts.URL)
+	opt.TLSConfig = &tls.Config{RootCAs: pool}
+	hp, err := newHttpPush(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &receiverpb.Packet{Id: id(), Module: "testing", Data: []byte("packet")}
+	// without client certificate, handshake failed
+	if err := hp.PushPacket(context.Background(), in); err != ErrUnavailable {
+		t.Fatalf("should be %v, but %v", ErrUnavailable, err)
+	}
+	_ = hp.Close()
+
+	opt.ClientCertFile, opt.ClientKeyFile = certFile, keyFile
+	hp, err = newHttpPush(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hp.Close()
+	if err := hp.PushPacket(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&received) != 1 {
+		t.Fatalf("received %d, want 1", received)
+	}
+}
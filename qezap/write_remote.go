@@ -2,7 +2,6 @@ package qezap
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
@@ -10,6 +9,7 @@ import (
 	"time"
 
 	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/bbdshow/qelog/api/receiverpb"
 )
@@ -24,8 +24,8 @@ type WriteRemote struct {
 
 	packet *Packet
 
-	// write backup file
-	wBak *writeBackup
+	// write ahead log, packet push failed written, bg replay
+	wal *writeAheadLog
 
 	isClose int32
 	exit    chan struct{}
@@ -37,13 +37,20 @@ func newWriteRemote(opt *remoteOption) *WriteRemote {
 		opt:  opt,
 		exit: make(chan struct{}),
 	}
-	w.wBak = newWriteBackup(w.opt.BackupFilename)
+	if err := w.opt.fallbackCompression(); err != nil {
+		log.Printf("Writer:%v\n", err)
+	}
+	wal, err := newWriteAheadLog(w.opt.WALDir, w.opt.WALSegmentSize, w.opt.WALMaxSize)
+	if err != nil {
+		log.Printf("Writer:init wal %v\n", err)
+	}
+	w.wal = wal
 	w.packet = newPacket(w.opt.ModuleName, w.opt.MaxPacketSize)
 
 	w.once.Do(func() {
 		go w.initPusher()
 		go w.bgTimeArrivalSendPacket()
-		go w.bgRetrySendPacket()
+		go w.bgReplayPacket()
 	})
 	return w
 }
@@ -71,9 +78,9 @@ func (w *WriteRemote) push(data *DataPacket) {
 		w.packet.PoolPutDataPacket(data)
 		return
 	}
-	// if pusher exception, write to back up file
+	// if pusher exception, write to wal
 	if w.pusher == nil || w.pusher.Concurrent() >= w.opt.MaxConcurrent {
-		_ = w.backup(data.Data())
+		w.backup(data.Data())
 		w.packet.PoolPutDataPacket(data)
 		return
 	}
@@ -88,8 +95,8 @@ func (w *WriteRemote) push(data *DataPacket) {
 
 		if err := w.pusher.PushPacket(ctx, data.Data()); err != nil {
 			if err == ErrUnavailable {
-				// when ErrUnavailable, should be written backup file, waiting retry.
-				_ = w.backup(data.Data())
+				// when ErrUnavailable, should be written wal, waiting replay.
+				w.backup(data.Data())
 			}
 			log.Printf("Writer:remote push packet %s\n", err.Error())
 		}
@@ -98,16 +105,16 @@ func (w *WriteRemote) push(data *DataPacket) {
 }
 
 func (w *WriteRemote) initPusher() {
-	initPusher := func(trans Transport, addrs []string, c int) (Pusher, error) {
-		switch trans {
+	initPusher := func(opt *remoteOption) (Pusher, error) {
+		switch opt.Transport {
 		case TransportHTTP:
-			return newHttpPush(addrs, c)
+			return newHttpPush(opt)
 		case TransportGRPC:
-			return newGRPCPush(addrs, c)
+			return newGRPCPush(opt)
 		case TransportMock:
-			return newMockPush(addrs, c)
+			return newMockPush(opt)
 		default:
-			return nil, fmt.Errorf("init %s transport pusher invalid", trans)
+			return nil, fmt.Errorf("init %s transport pusher invalid", opt.Transport)
 		}
 	}
 
@@ -117,10 +124,15 @@ func (w *WriteRemote) initPusher() {
 			return
 		}
 		var err error
-		w.pusher, err = initPusher(w.opt.Transport, w.opt.Addrs, w.opt.MaxConcurrent)
+		w.pusher, err = initPusher(w.opt)
 		if err != nil {
 			log.Printf("Writer:init %s pusher %v\n", w.opt.Transport, err)
-			<-tick.C
+			select {
+			case <-tick.C:
+			case <-w.exit:
+				tick.Stop()
+				return
+			}
 			continue
 		}
 
@@ -130,25 +142,18 @@ func (w *WriteRemote) initPusher() {
 	}
 }
 
-type bakPacket struct {
-	ID     string `json:"id"`
-	Module string `json:"module"`
-	Data   string `json:"data"`
-}
-
-func (w *WriteRemote) backup(in *receiverpb.Packet) error {
-	bp := bakPacket{
-		ID:     in.Id,
-		Module: in.Module,
-		Data:   string(in.Data),
+func (w *WriteRemote) backup(in *receiverpb.Packet) {
+	if w.wal == nil {
+		log.Printf("Writer:wal unavailable, discard packet %s\n", in.Id)
+		return
+	}
+	byt, err := proto.Marshal(in)
+	if err == nil {
+		err = w.wal.Append(byt)
 	}
-
-	byt, err := json.Marshal(bp)
 	if err != nil {
-		return err
+		log.Printf("Writer:wal append packet %s %v\n", in.Id, err)
 	}
-	_, err = w.wBak.WriteBakPacket(byt)
-	return err
 }
 
 // when interval time arrival, even if then packet not full, it also should send
@@ -170,46 +175,92 @@ func (w *WriteRemote) bgTimeArrivalSendPacket() {
 	}
 }
 
-// when packet send failed, interval retry data from back up file
-func (w *WriteRemote) bgRetrySendPacket() {
+// when packet send failed, interval replay packet from wal concurrently
+func (w *WriteRemote) bgReplayPacket() {
+	if w.wal == nil {
+		return
+	}
+	sem := make(chan struct{}, w.opt.WALReplayConcurrent)
 	tick := time.NewTicker(200 * time.Millisecond)
 	for {
 		select {
 		case <-tick.C:
-			byt, err := w.wBak.ReadBakPacket()
-			if err != nil {
-				log.Printf("Writer:packet retry ReadBakPacket %v\n", err)
-			}
-			if len(byt) > 0 {
-				bp := &bakPacket{}
-				if err := json.Unmarshal(byt, bp); err != nil {
-					log.Printf("Writer:packet retry Unmarshal %v\n", err)
-					break
-				}
-				v := &receiverpb.Packet{
-					Id:     bp.ID,
-					Module: bp.Module,
-					Data:   []byte(bp.Data),
-				}
-			loop:
-				// read data must send success, because back up file have been offset.
-				// warning: if main process exception, back up file have content, packets are sent repeatedly, Packet.ID used for idempotent.
-				if w.pusher != nil {
-					err := w.pusher.PushPacket(context.Background(), v)
-					if err == nil {
-						break
-					}
-					log.Printf("Writer:packet retry PushPacket %v\n", err)
-				}
-				time.Sleep(time.Second)
-				goto loop
-			}
+			w.replay(sem)
+		case <-w.exit:
+			tick.Stop()
+			return
+		}
+	}
+}
+
+func (w *WriteRemote) replay(sem chan struct{}) {
+	for w.pusher != nil {
+		rec, err := w.wal.Next()
+		if err != nil {
+			log.Printf("Writer:wal replay read %v\n", err)
+			return
+		}
+		if rec == nil {
+			return
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-w.exit:
+			// not commit, replay after restart
+			return
+		}
+		go func(rec *walRecord) {
+			defer func() {
+				<-sem
+			}()
+			w.replayRecord(rec)
+		}(rec)
+	}
+}
+
+// replayRecord push until success, commit after ack.
+// warning: if main process exception between ack and commit, packet is sent repeatedly, Packet.ID used for idempotent.
+func (w *WriteRemote) replayRecord(rec *walRecord) {
+	in := &receiverpb.Packet{}
+	if err := proto.Unmarshal(rec.Data, in); err != nil {
+		log.Printf("Writer:wal replay unmarshal %v\n", err)
+		_ = w.wal.Commit(rec)
+		return
+	}
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), w.replayTimeout())
+		err := w.pusher.PushPacket(ctx, in)
+		cancel()
+		if err == nil {
+			break
+		}
+		if err != ErrUnavailable {
+			// server rejected, retry meaningless
+			log.Printf("Writer:wal replay discard packet %s %v\n", in.Id, err)
+			break
+		}
+		select {
+		case <-time.After(backoff):
 		case <-w.exit:
 			return
 		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+	if err := w.wal.Commit(rec); err != nil {
+		log.Printf("Writer:wal commit %v\n", err)
 	}
 }
 
+func (w *WriteRemote) replayTimeout() time.Duration {
+	if w.opt.WriteTimeout > 0 {
+		return w.opt.WriteTimeout
+	}
+	return 30 * time.Second
+}
+
 // Sync write final content
 func (w *WriteRemote) Sync() error {
 	w.mutex.Lock()
@@ -220,24 +271,30 @@ func (w *WriteRemote) Sync() error {
 		w.packet.SetCanPush(d)
 		w.push(d)
 	}
-	// send pusher memory data
-	wait := make(chan struct{}, 1)
-	go func() {
-		for {
-			if w.pusher != nil && w.pusher.Concurrent() == 0 {
-				time.Sleep(10 * time.Millisecond)
-				wait <- struct{}{}
-				return
-			}
-			time.Sleep(50 * time.Millisecond)
-		}
-	}()
+	w.waitPushed(w.replayTimeout())
 
-	select {
-	case <-wait:
+	if w.wal != nil {
+		return w.wal.Sync()
 	}
+	return nil
+}
 
-	return w.wBak.Close()
+// waitPushed wait pusher memory data sent, pusher not created packet already written wal
+func (w *WriteRemote) waitPushed(timeout time.Duration) {
+	if w.pusher == nil {
+		return
+	}
+	deadline := time.After(timeout)
+	for w.pusher.Concurrent() > 0 {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			log.Printf("Writer:sync wait pushed timeout %s\n", timeout)
+			return
+		}
+	}
+	// pushed failed packet written wal after concurrent released
+	time.Sleep(10 * time.Millisecond)
 }
 
 func (w *WriteRemote) Close() error {
@@ -250,5 +307,8 @@ func (w *WriteRemote) Close() error {
 	}
 
 	close(w.exit)
+	if w.wal != nil {
+		err = multierr.Append(err, w.wal.Close())
+	}
 	return err
 }
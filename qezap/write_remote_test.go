@@ -130,6 +130,37 @@ func TestWriter_Close(t *testing.T) {
 	time.Sleep(time.Second)
 }
 
+func TestWriter_CloseNotBlocked(t *testing.T) {
+	for i, fn := range []func(opt *remoteOption){
+		// unsupported compression fall back, pusher created
+		func(opt *remoteOption) { opt.Compression = "LZ4" },
+		// pusher never created
+		func(opt *remoteOption) { opt.Addrs = nil },
+	} {
+		opt := defaultRemoteOption()
+		opt.Addrs = []string{"127.0.0.1:31082"}
+		opt.Transport = TransportMock
+		opt.WALDir = t.TempDir()
+		fn(opt)
+		w := newWriteRemote(opt)
+		if _, err := w.Write([]byte("close not blocked")); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- w.Close()
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("case %d: %v", i, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("case %d: close blocked", i)
+		}
+	}
+}
+
 func TestWriter_RetrySendPacket(t *testing.T) {
 	w := testNewWriteRemote(t)
 
@@ -0,0 +1,460 @@
+package qezap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	walSegmentExt      = ".wal"
+	walCheckpointName  = "checkpoint"
+	walFrameHeaderSize = 8
+)
+
+var (
+	ErrWALFull   = errors.New("wal total size limit exceeded")
+	ErrWALClosed = errors.New("wal is closed")
+)
+
+// walPos record position in wal, segment sequence + offset in segment file
+type walPos struct {
+	Seg uint64 `json:"seg"`
+	Off int64  `json:"off"`
+}
+
+func (p walPos) less(o walPos) bool {
+	if p.Seg == o.Seg {
+		return p.Off < o.Off
+	}
+	return p.Seg < o.Seg
+}
+
+// walRecord a packet read from wal, Commit it after pusher ack
+type walRecord struct {
+	start walPos
+	end   walPos
+	Data  []byte
+}
+
+// walCheckpoint persisted commit offset.
+// Commit all record before Commit position, Acked are records after Commit position,
+// they were acked out of order by concurrent replay.
+type walCheckpoint struct {
+	Commit walPos   `json:"commit"`
+	Acked  []walPos `json:"acked"`
+}
+
+// walInflight record has been read, waiting ack
+type walInflight struct {
+	start walPos
+	end   walPos
+	acked bool
+}
+
+type walSegment struct {
+	seq  uint64
+	size int64
+}
+
+// writeAheadLog segmented write ahead log, used to retry remote packet.
+// packet is appended to fixed size segment file, replay read in order and commit after ack,
+// commit offset is persisted to checkpoint file, acked packet never replayed after restart.
+// segment will be deleted when all packets in it are committed.
+type writeAheadLog struct {
+	mutex sync.Mutex
+
+	dir         string
+	segmentSize int64
+	maxSize     int64
+
+	// segments sorted by seq, the last one is active written segment
+	segments []*walSegment
+	active   *os.File
+	size     int64
+
+	// replay read state
+	next     walPos
+	reader   *os.File
+	readerAt uint64
+	inflight []*walInflight
+	commit   walPos
+	// acked out of order, loaded from checkpoint, skip when replay
+	skip map[walPos]struct{}
+
+	isClose bool
+}
+
+func newWriteAheadLog(dir string, segmentSize, maxSize int64) (*writeAheadLog, error) {
+	w := &writeAheadLog{
+		dir:         dir,
+		segmentSize: segmentSize,
+		maxSize:     maxSize,
+		segments:    make([]*walSegment, 0),
+		inflight:    make([]*walInflight, 0),
+		skip:        map[walPos]struct{}{},
+	}
+	if err := os.MkdirAll(dir, os.ModePerm|os.ModeDir); err != nil {
+		return nil, err
+	}
+	if err := w.loadSegments(); err != nil {
+		return nil, err
+	}
+	if err := w.loadCheckpoint(); err != nil {
+		return nil, err
+	}
+	// always write new segment after restart, the latest segment maybe have torn frame
+	if err := w.openSegment(w.nextSeq()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *writeAheadLog) segmentFilename(seq uint64) string {
+	return path.Join(w.dir, fmt.Sprintf("%020d%s", seq, walSegmentExt))
+}
+
+func (w *writeAheadLog) checkpointFilename() string {
+	return path.Join(w.dir, walCheckpointName)
+}
+
+func (w *writeAheadLog) loadSegments() error {
+	fs, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), walSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), walSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if f.Size() == 0 {
+			_ = os.Remove(path.Join(w.dir, f.Name()))
+			continue
+		}
+		w.segments = append(w.segments, &walSegment{seq: seq, size: f.Size()})
+		w.size += f.Size()
+	}
+	sort.Slice(w.segments, func(i, j int) bool {
+		return w.segments[i].seq < w.segments[j].seq
+	})
+	return nil
+}
+
+func (w *writeAheadLog) loadCheckpoint() error {
+	w.commit = w.firstPos()
+	byt, err := ioutil.ReadFile(w.checkpointFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.next = w.commit
+			return nil
+		}
+		return err
+	}
+	ckpt := walCheckpoint{}
+	if err := json.Unmarshal(byt, &ckpt); err != nil {
+		// broken checkpoint, replay from first segment, Packet.ID used for idempotent
+		w.next = w.commit
+		return nil
+	}
+	if w.commit.less(ckpt.Commit) {
+		w.commit = ckpt.Commit
+	}
+	for _, v := range ckpt.Acked {
+		if w.commit.less(v) {
+			w.skip[v] = struct{}{}
+		}
+	}
+	w.next = w.commit
+	return nil
+}
+
+func (w *writeAheadLog) firstPos() walPos {
+	if len(w.segments) > 0 {
+		return walPos{Seg: w.segments[0].seq}
+	}
+	return walPos{}
+}
+
+func (w *writeAheadLog) nextSeq() uint64 {
+	if len(w.segments) > 0 {
+		return w.segments[len(w.segments)-1].seq + 1
+	}
+	// all segments have been deleted, keep sequence increasing
+	return w.commit.Seg + 1
+}
+
+func (w *writeAheadLog) openSegment(seq uint64) error {
+	f, err := os.OpenFile(w.segmentFilename(seq), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if w.active != nil {
+		_ = w.active.Close()
+	}
+	w.active = f
+	w.segments = append(w.segments, &walSegment{seq: seq})
+	if len(w.segments) == 1 {
+		w.commit = walPos{Seg: seq}
+		w.next = w.commit
+		w.skip = map[walPos]struct{}{}
+	}
+	return nil
+}
+
+func (w *writeAheadLog) activeSegment() *walSegment {
+	return w.segments[len(w.segments)-1]
+}
+
+// Append write packet data to active segment. frame: [4 byte length][4 byte crc32][data]
+func (w *writeAheadLog) Append(b []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.isClose {
+		return ErrWALClosed
+	}
+
+	frameSize := int64(walFrameHeaderSize + len(b))
+	if w.maxSize > 0 && w.size+frameSize > w.maxSize {
+		return ErrWALFull
+	}
+	seg := w.activeSegment()
+	if seg.size > 0 && seg.size+frameSize > w.segmentSize {
+		// seal current segment, switch next
+		if err := w.openSegment(seg.seq + 1); err != nil {
+			return err
+		}
+		seg = w.activeSegment()
+	}
+
+	frame := make([]byte, frameSize)
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(b)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(b))
+	copy(frame[walFrameHeaderSize:], b)
+	n, err := w.active.Write(frame)
+	seg.size += int64(n)
+	w.size += int64(n)
+	if err != nil && n > 0 {
+		// torn frame, seal this segment, replay will skip the broken tail
+		_ = w.openSegment(seg.seq + 1)
+	}
+	return err
+}
+
+// Next read next uncommitted record in order, returns nil if all records have been read.
+func (w *writeAheadLog) Next() (*walRecord, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.isClose {
+		return nil, ErrWALClosed
+	}
+	for {
+		seg := w.segment(w.next.Seg)
+		if seg == nil {
+			return nil, nil
+		}
+		if w.next.Off >= seg.size {
+			if seg == w.activeSegment() {
+				// waiting write
+				return nil, nil
+			}
+			// sealed segment read over, switch next segment
+			w.sealRead(seg)
+			continue
+		}
+
+		data, n, err := w.readFrame(seg, w.next.Off)
+		if err != nil {
+			if seg == w.activeSegment() {
+				return nil, err
+			}
+			// torn or broken sealed segment, skip rest data
+			w.sealRead(seg)
+			continue
+		}
+		start := w.next
+		w.next.Off += n
+		if _, ok := w.skip[start]; ok {
+			delete(w.skip, start)
+			w.inflight = append(w.inflight, &walInflight{start: start, end: w.next, acked: true})
+			continue
+		}
+		rec := &walRecord{start: start, end: w.next, Data: data}
+		w.inflight = append(w.inflight, &walInflight{start: start, end: w.next})
+		return rec, nil
+	}
+}
+
+// sealRead all records of sealed segment have been read, a marker move to next segment
+func (w *writeAheadLog) sealRead(seg *walSegment) {
+	start := w.next
+	w.next = walPos{Seg: seg.seq + 1}
+	if nextSeg := w.segmentAfter(seg.seq); nextSeg != nil {
+		w.next.Seg = nextSeg.seq
+	}
+	w.inflight = append(w.inflight, &walInflight{start: start, end: w.next, acked: true})
+	w.advanceCommit()
+}
+
+func (w *writeAheadLog) readFrame(seg *walSegment, off int64) ([]byte, int64, error) {
+	if w.reader == nil || w.readerAt != seg.seq {
+		if w.reader != nil {
+			_ = w.reader.Close()
+			w.reader = nil
+		}
+		f, err := os.Open(w.segmentFilename(seg.seq))
+		if err != nil {
+			return nil, 0, err
+		}
+		w.reader = f
+		w.readerAt = seg.seq
+	}
+	if _, err := w.reader.Seek(off, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	r := bufio.NewReader(io.LimitReader(w.reader, seg.size-off))
+	header := make([]byte, walFrameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if length > seg.size-off-walFrameHeaderSize {
+		return nil, 0, fmt.Errorf("wal frame length %d out of range", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, fmt.Errorf("wal frame crc32 mismatch")
+	}
+	return data, walFrameHeaderSize + length, nil
+}
+
+func (w *writeAheadLog) segment(seq uint64) *walSegment {
+	for _, v := range w.segments {
+		if v.seq == seq {
+			return v
+		}
+	}
+	return nil
+}
+
+func (w *writeAheadLog) segmentAfter(seq uint64) *walSegment {
+	for _, v := range w.segments {
+		if v.seq > seq {
+			return v
+		}
+	}
+	return nil
+}
+
+// Commit record have been acked, persist checkpoint
+func (w *writeAheadLog) Commit(rec *walRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.isClose {
+		return ErrWALClosed
+	}
+	for _, v := range w.inflight {
+		if v.start == rec.start {
+			v.acked = true
+			break
+		}
+	}
+	w.advanceCommit()
+	return w.saveCheckpoint()
+}
+
+// advanceCommit move commit offset over contiguous acked records, delete committed segments
+func (w *writeAheadLog) advanceCommit() {
+	i := 0
+	for ; i < len(w.inflight) && w.inflight[i].acked; i++ {
+		w.commit = w.inflight[i].end
+	}
+	w.inflight = w.inflight[i:]
+
+	segments := w.segments[:0]
+	for _, seg := range w.segments {
+		if seg.seq < w.commit.Seg && seg != w.activeSegment() {
+			if w.reader != nil && w.readerAt == seg.seq {
+				_ = w.reader.Close()
+				w.reader = nil
+			}
+			_ = os.Remove(w.segmentFilename(seg.seq))
+			w.size -= seg.size
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	w.segments = segments
+}
+
+func (w *writeAheadLog) saveCheckpoint() error {
+	ckpt := walCheckpoint{Commit: w.commit, Acked: make([]walPos, 0)}
+	for _, v := range w.inflight {
+		if v.acked {
+			ckpt.Acked = append(ckpt.Acked, v.start)
+		}
+	}
+	byt, err := json.Marshal(ckpt)
+	if err != nil {
+		return err
+	}
+	tmp := w.checkpointFilename() + ".tmp"
+	if err := ioutil.WriteFile(tmp, byt, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.checkpointFilename())
+}
+
+// Size wal total size of segment files
+func (w *writeAheadLog) Size() int64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.size
+}
+
+// Sync flush active segment to disk
+func (w *writeAheadLog) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.isClose || w.active == nil {
+		return nil
+	}
+	return w.active.Sync()
+}
+
+// Close release file handle, records read but not committed will be replayed after restart
+func (w *writeAheadLog) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.isClose {
+		return nil
+	}
+	w.isClose = true
+	if w.reader != nil {
+		_ = w.reader.Close()
+		w.reader = nil
+	}
+	if w.active != nil {
+		err := w.active.Close()
+		w.active = nil
+		return err
+	}
+	return nil
+}
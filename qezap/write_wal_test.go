@@ -0,0 +1,127 @@
+package qezap
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func testNewWriteAheadLog(t *testing.T, dir string, segmentSize, maxSize int64) *writeAheadLog {
+	w, err := newWriteAheadLog(dir, segmentSize, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestWriteAheadLog_AppendNextCommit(t *testing.T) {
+	dir := "./log/wal/append"
+	_ = os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	// small segment, every two packets switch next segment
+	w := testNewWriteAheadLog(t, dir, 32, 0)
+	for i := 0; i < 5; i++ {
+		if err := w.Append([]byte("packet" + strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(w.segments) != 3 {
+		t.Fatalf("segments %d, want 3", len(w.segments))
+	}
+
+	for i := 0; i < 5; i++ {
+		rec, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec == nil {
+			t.Fatalf("case %d: record nil", i)
+		}
+		if string(rec.Data) != "packet"+strconv.Itoa(i) {
+			t.Fatalf("case %d: data not equal %s", i, string(rec.Data))
+		}
+		if err := w.Commit(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != nil {
+		t.Fatalf("all record read, but found %s", string(rec.Data))
+	}
+	// committed sealed segments have been deleted
+	if len(w.segments) != 1 {
+		t.Fatalf("segments %d, want 1", len(w.segments))
+	}
+	_ = w.Close()
+}
+
+func TestWriteAheadLog_Restart(t *testing.T) {
+	dir := "./log/wal/restart"
+	_ = os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	w := testNewWriteAheadLog(t, dir, 1<<20, 0)
+	for i := 0; i < 3; i++ {
+		if err := w.Append([]byte("packet" + strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recs := make([]*walRecord, 0)
+	for i := 0; i < 3; i++ {
+		rec, err := w.Next()
+		if err != nil || rec == nil {
+			t.Fatalf("case %d: next %v", i, err)
+		}
+		recs = append(recs, rec)
+	}
+	// concurrent replay, ack out of order, packet1 not acked
+	if err := w.Commit(recs[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(recs[0]); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	w = testNewWriteAheadLog(t, dir, 1<<20, 0)
+	rec, err := w.Next()
+	if err != nil || rec == nil {
+		t.Fatalf("restart next %v", err)
+	}
+	if string(rec.Data) != "packet1" {
+		t.Fatalf("acked packet replayed %s", string(rec.Data))
+	}
+	if err := w.Commit(rec); err != nil {
+		t.Fatal(err)
+	}
+	rec, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != nil {
+		t.Fatalf("acked packet replayed %s", string(rec.Data))
+	}
+	_ = w.Close()
+}
+
+func TestWriteAheadLog_MaxSize(t *testing.T) {
+	dir := "./log/wal/maxsize"
+	_ = os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	w := testNewWriteAheadLog(t, dir, 1<<20, 20)
+	if err := w.Append([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Append([]byte("world")); err != ErrWALFull {
+		t.Fatalf("should be %v, but %v", ErrWALFull, err)
+	}
+	_ = w.Close()
+	if err := w.Append([]byte("closed")); err != ErrWALClosed {
+		t.Fatalf("should be %v, but %v", ErrWALClosed, err)
+	}
+}